@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jongio/azd-core/env"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+// getAzdEnvironmentValues reads an azd environment's values without touching the process environment.
+var getAzdEnvironmentValues = env.GetAzdEnvironmentValues
+
+// AzdEnvironmentScope returns the values of the azd environment named name, or
+// of the one azd exec was started with when name is empty, for
+// executor.Config.AzdEnvironment. They are only read when the process
+// environment holds references that need them, so other runs start no azd process.
+func AzdEnvironmentScope(ctx context.Context, name string) ([]string, error) {
+	if !executor.NeedsAzdEnvironment(os.Environ()) {
+		return nil, nil
+	}
+	if name == "" {
+		name = os.Getenv("AZURE_ENV_NAME")
+	}
+	if name == "" {
+		return nil, nil
+	}
+	values, err := getAzdEnvironmentValues(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment '%s': %w", name, err)
+	}
+	return executor.AzdEnvironmentEnv(name, values), nil
+}
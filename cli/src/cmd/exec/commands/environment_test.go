@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestAzdEnvironmentScope(t *testing.T) {
+	oldGet := getAzdEnvironmentValues
+	t.Cleanup(func() { getAzdEnvironmentValues = oldGet })
+	var loaded []string
+	getAzdEnvironmentValues = func(_ context.Context, name string) (map[string]string, error) {
+		loaded = append(loaded, name)
+		return map[string]string{"TOKEN": "env://CI_TOKEN"}, nil
+	}
+
+	t.Setenv("AZURE_ENV_NAME", "dev")
+	t.Setenv("TOKEN", "plain")
+	if scope, err := AzdEnvironmentScope(context.Background(), ""); err != nil || scope != nil || len(loaded) != 0 {
+		t.Fatalf("without references: scope %v, error %v, loaded %v; want no azd call", scope, err, loaded)
+	}
+
+	t.Setenv("TOKEN", "env://CI_TOKEN")
+	scope, err := AzdEnvironmentScope(context.Background(), "")
+	if err != nil || !slices.Equal(loaded, []string{"dev"}) {
+		t.Fatalf("AzdEnvironmentScope() error %v, loaded %v; want the environment azd selected", err, loaded)
+	}
+	if want := []string{"AZURE_ENV_NAME=dev", "TOKEN=env://CI_TOKEN"}; !slices.Equal(scope, want) {
+		t.Errorf("AzdEnvironmentScope() = %v, want %v", scope, want)
+	}
+
+	if _, err := AzdEnvironmentScope(context.Background(), "staging"); err != nil || loaded[len(loaded)-1] != "staging" {
+		t.Errorf("AzdEnvironmentScope(staging) error %v, loaded %v; want the named environment", err, loaded)
+	}
+}
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/history"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
+	"github.com/spf13/cobra"
+)
+
+type rerunExecutor interface {
+	Execute(ctx context.Context, scriptPath string) error
+	LastResult() *executor.Result
+}
+
+var newRerunExecutor = func(config executor.Config) (rerunExecutor, error) {
+	return executor.New(config)
+}
+
+// NewHistoryCommand creates the history command that lists the runs recorded
+// in the project's execution history.
+func NewHistoryCommand() *cobra.Command {
+	var (
+		status string
+		limit  int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List the scripts recently run in this project",
+		Long: `List the scripts azd exec ran in this project, newest first, with their
+environment, exit code and duration. Use the ID with 'azd exec rerun' to run one again.
+
+Runs are recorded in .azure/` + history.FileName + ` at the project root. Inline scripts
+are recorded only by hash, and --env values only when they are secret references.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if status != "" && status != history.StatusSucceeded && status != history.StatusFailed {
+				return fmt.Errorf("--status must be %q or %q", history.StatusSucceeded, history.StatusFailed)
+			}
+			if limit < 0 {
+				return fmt.Errorf("--limit cannot be negative")
+			}
+			root, err := projectRoot()
+			if err != nil {
+				return err
+			}
+			records, err := history.Load(root)
+			if err != nil {
+				return err
+			}
+
+			environment := selectedEnvironment(cmd)
+			runs := []history.Record{}
+			for _, rec := range slices.Backward(records) {
+				if (environment == "" || rec.Environment == environment) && (status == "" || rec.Status == status) {
+					runs = append(runs, rec)
+				}
+				if limit > 0 && len(runs) == limit {
+					break
+				}
+			}
+
+			return cliout.Print(runs, func() {
+				if len(runs) == 0 {
+					cliout.Info("No runs recorded in %s", root)
+					return
+				}
+				rows := make([]cliout.TableRow, 0, len(runs))
+				for _, rec := range runs {
+					rows = append(rows, cliout.TableRow{
+						"ID":          strconv.Itoa(rec.ID),
+						"Time":        rec.Time.Local().Format("2006-01-02 15:04:05"),
+						"Environment": rec.Environment,
+						"Script":      historyScriptText(root, rec),
+						"Exit":        strconv.Itoa(rec.ExitCode),
+						"Duration":    (time.Duration(rec.DurationMs) * time.Millisecond).String(),
+					})
+				}
+				cliout.Table([]string{"ID", "Time", "Environment", "Script", "Exit", "Duration"}, rows)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&status, "status", "", "Only show runs with this status: succeeded or failed")
+	cmd.Flags().IntVar(&limit, "limit", 20, "Maximum number of runs to show; 0 shows all")
+
+	return cmd
+}
+
+// NewRerunCommand creates the rerun command that runs a recorded script again
+// with the settings it was first run with.
+func NewRerunCommand() *cobra.Command {
+	var requireTrusted bool
+
+	cmd := &cobra.Command{
+		Use:   "rerun <id>",
+		Short: "Run a script from the execution history again",
+		Long: `Run a script from 'azd exec history' again, with the same shell, arguments,
+working directory, options and azd environment. Select another environment with -e.
+
+Inline scripts, scripts read from stdin and runs whose arguments were not kept with
+--record-args cannot be rerun, because what they ran is not recorded. --env values that were not secret references are not recorded either,
+so they are left out.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.Atoi(args[0])
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid run ID %q", args[0])
+			}
+			root, err := projectRoot()
+			if err != nil {
+				return err
+			}
+			rec, err := history.Find(root, id)
+			if err != nil {
+				return err
+			}
+			if err := rec.Rerunnable(); err != nil {
+				return err
+			}
+
+			config := rec.Config()
+			config.Trust = TrustMode(requireTrusted)
+			// Captured output is only shown in the JSON result.
+			config.CaptureOutput = config.CaptureOutput && cliout.IsJSON()
+			environment := rec.Environment
+			selected := selectedEnvironment(cmd)
+			if selected != "" {
+				environment = selected
+			}
+			switch {
+			case rec.Options.CleanEnv:
+				if environment == "" {
+					return fmt.Errorf("run %d used --clean-env, which requires an azd environment; select one with -e", rec.ID)
+				}
+				inherited, err := executor.CleanEnviron(os.Environ(), rec.Options.PassEnv)
+				if err != nil {
+					return err
+				}
+				values, err := getAzdEnvironmentValues(cmd.Context(), environment)
+				if err != nil {
+					return fmt.Errorf("failed to load environment '%s': %w", environment, err)
+				}
+				config.AzdEnvironment = executor.AzdEnvironmentEnv(environment, values)
+				config.Environ = executor.MergeEnv(inherited, config.AzdEnvironment)
+			case selected != "":
+				// -e has already been loaded into the process environment.
+				if config.AzdEnvironment, err = AzdEnvironmentScope(cmd.Context(), selected); err != nil {
+					return err
+				}
+			case environment != "":
+				values, err := getAzdEnvironmentValues(cmd.Context(), environment)
+				if err != nil {
+					return fmt.Errorf("failed to load environment '%s': %w", environment, err)
+				}
+				config.AzdEnvironment = executor.AzdEnvironmentEnv(environment, values)
+				config.Environ = executor.MergeEnv(os.Environ(), config.AzdEnvironment)
+			}
+
+			for _, name := range rec.Options.OmittedEnv {
+				rerunNotice(cliout.Warning, "--env %s was not recorded and is not set for this run", name)
+			}
+			rerunNotice(cliout.Info, "Rerunning #%d: %s", rec.ID, historyScriptText(root, rec))
+
+			exec, err := newRerunExecutor(config)
+			if err != nil {
+				return fmt.Errorf("invalid configuration for run %d: %w", rec.ID, err)
+			}
+			runErr := exec.Execute(cmd.Context(), rec.Script)
+
+			result := exec.LastResult()
+			if result != nil {
+				again := history.NewRecord(config, rec.Script, environment, result)
+				again.Options.CleanEnv, again.Options.PassEnv = rec.Options.CleanEnv, rec.Options.PassEnv
+				again.Args = rec.Args
+				if _, err := history.Append(root, again); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to record the run in the execution history: %v\n", err)
+				}
+				if cliout.IsJSON() {
+					if err := cliout.PrintJSON(result); err != nil {
+						return err
+					}
+				}
+			}
+			return runErr
+		},
+	}
+
+	cmd.Flags().BoolVar(&requireTrusted, "require-trusted", false, "Fail instead of asking for confirmation when the script file is not trusted or has changed (for CI)")
+
+	return cmd
+}
+
+// projectRoot returns the root of the project containing the current directory.
+func projectRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	root, err := project.FindRoot(cwd)
+	if errors.Is(err, project.ErrNoProject) {
+		return "", fmt.Errorf("the execution history is kept per project: %w", err)
+	}
+	return root, err
+}
+
+// selectedEnvironment returns the azd environment given with -e, if the flag was set.
+func selectedEnvironment(cmd *cobra.Command) string {
+	if f := cmd.Flags().Lookup("environment"); f != nil && f.Changed {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// historyScriptText describes a recorded script for text output: its path
+// relative to the project root, or the start of its hash for inline scripts.
+func historyScriptText(root string, rec history.Record) string {
+	switch {
+	case rec.InlineHash != "":
+		_, hash, _ := strings.Cut(rec.InlineHash, ":")
+		return "inline (" + hash[:min(12, len(hash))] + ")"
+	case rec.Script == history.StdinScript:
+		return "stdin"
+	}
+	if rel, err := filepath.Rel(root, rec.Script); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return rec.Script
+}
+
+// rerunNotice prints a message with print, or to stderr with JSON output so
+// that stdout holds only the result.
+func rerunNotice(print func(string, ...interface{}), format string, args ...any) {
+	if cliout.IsJSON() {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+		return
+	}
+	print(format, args...)
+}
@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// rerunRecorder is a rerun executor that records the configuration and script it ran.
+type rerunRecorder struct {
+	config executor.Config
+	script string
+}
+
+func (r *rerunRecorder) Execute(_ context.Context, scriptPath string) error {
+	r.script = scriptPath
+	return nil
+}
+
+func (r *rerunRecorder) LastResult() *executor.Result {
+	return &executor.Result{Shell: "bash", ExitCode: 0, DurationMs: 10}
+}
+
+// setupHistory creates a project with the given records and makes it the current directory.
+func setupHistory(t *testing.T, records ...history.Record) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "azure.yaml"), []byte("name: app\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if _, err := history.Append(root, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(root)
+	return root
+}
+
+// withEnvironmentFlag adds cmd to a root command with azd's persistent -e flag.
+func withEnvironmentFlag(cmd *cobra.Command) *cobra.Command {
+	root := &cobra.Command{Use: "exec"}
+	root.PersistentFlags().StringP("environment", "e", "", "")
+	root.AddCommand(cmd)
+	return root
+}
+
+func TestHistoryCommand(t *testing.T) {
+	setupHistory(t,
+		history.Record{Environment: "dev", Script: "/app/a.sh", Status: history.StatusSucceeded},
+		history.Record{Environment: "test", Script: "/app/a.sh", Status: history.StatusFailed, ExitCode: 1},
+		history.Record{Environment: "dev", InlineHash: "sha256:0123456789abcdef", Status: history.StatusFailed, ExitCode: 2},
+	)
+
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{args: []string{"history"}},
+		{args: []string{"history", "--status", "failed", "-e", "dev", "--limit", "1"}},
+		{args: []string{"history", "--status", "broken"}, wantErr: "--status must be"},
+	}
+	for _, tt := range tests {
+		cmd := withEnvironmentFlag(NewHistoryCommand())
+		cmd.SetArgs(tt.args)
+		err := cmd.Execute()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("Execute(%v) failed: %v", tt.args, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Execute(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRerunCommand(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "smoke.sh")
+	root := setupHistory(t, history.Record{
+		Environment: "staging",
+		Script:      script,
+		Args:        []string{"--quick"},
+		Status:      history.StatusFailed,
+		Options: history.Options{
+			Shell:      "bash",
+			Retries:    2,
+			Env:        []string{"TOKEN=akv://kv/token"},
+			OmittedEnv: []string{"DEBUG"},
+		},
+	})
+
+	oldNew, oldGet := newRerunExecutor, getAzdEnvironmentValues
+	t.Cleanup(func() { newRerunExecutor, getAzdEnvironmentValues = oldNew, oldGet })
+	recorder := &rerunRecorder{}
+	newRerunExecutor = func(config executor.Config) (rerunExecutor, error) {
+		recorder.config = config
+		return recorder, nil
+	}
+	getAzdEnvironmentValues = func(_ context.Context, name string) (map[string]string, error) {
+		return map[string]string{"WEB_URL": "https://" + name + ".example"}, nil
+	}
+
+	cmd := withEnvironmentFlag(NewRerunCommand())
+	cmd.SetArgs([]string{"rerun", "1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if recorder.script != script {
+		t.Errorf("ran %q, want %q", recorder.script, script)
+	}
+	got := recorder.config
+	if got.Shell != "bash" || got.Retries != 2 || !reflect.DeepEqual(got.Args, []string{"--quick"}) || !reflect.DeepEqual(got.EnvOverrides, []string{"TOKEN=akv://kv/token"}) {
+		t.Errorf("unexpected rebuilt config: %+v", got)
+	}
+	if !slices.Contains(got.Environ, "WEB_URL=https://staging.example") || !slices.Contains(got.Environ, "AZURE_ENV_NAME=staging") {
+		t.Error("expected the recorded azd environment in the script environment")
+	}
+
+	if got.Trust != executor.TrustPrompt {
+		t.Errorf("Trust = %q, want %q", got.Trust, executor.TrustPrompt)
+	}
+
+	records, err := history.Load(root)
+	if err != nil || len(records) != 2 || records[1].Environment != "staging" || records[1].Status != history.StatusSucceeded {
+		t.Errorf("expected the rerun to be recorded, got %+v, %v", records, err)
+	}
+}
+
+func TestRerunCommand_RequireTrusted(t *testing.T) {
+	setupHistory(t, history.Record{Script: filepath.Join(t.TempDir(), "smoke.sh"), Status: history.StatusSucceeded})
+
+	oldNew := newRerunExecutor
+	t.Cleanup(func() { newRerunExecutor = oldNew })
+	recorder := &rerunRecorder{}
+	newRerunExecutor = func(config executor.Config) (rerunExecutor, error) {
+		recorder.config = config
+		return recorder, nil
+	}
+
+	cmd := NewRerunCommand()
+	cmd.SetArgs([]string{"--require-trusted", "1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if recorder.config.Trust != executor.TrustRequire {
+		t.Errorf("Trust = %q, want %q", recorder.config.Trust, executor.TrustRequire)
+	}
+}
+
+func TestRerunCommand_CleanEnv(t *testing.T) {
+	setupHistory(t, history.Record{
+		Environment: "staging",
+		Script:      filepath.Join(t.TempDir(), "smoke.sh"),
+		Status:      history.StatusSucceeded,
+		Options:     history.Options{CleanEnv: true, PassEnv: []string{"RERUN_TEST_*"}},
+	})
+	t.Setenv("RERUN_TEST_KEEP", "1")
+	t.Setenv("RERUN_DROP", "1")
+
+	oldNew, oldGet := newRerunExecutor, getAzdEnvironmentValues
+	t.Cleanup(func() { newRerunExecutor, getAzdEnvironmentValues = oldNew, oldGet })
+	recorder := &rerunRecorder{}
+	newRerunExecutor = func(config executor.Config) (rerunExecutor, error) {
+		recorder.config = config
+		return recorder, nil
+	}
+	getAzdEnvironmentValues = func(_ context.Context, name string) (map[string]string, error) {
+		return map[string]string{"WEB_URL": "https://" + name + ".example"}, nil
+	}
+
+	cmd := withEnvironmentFlag(NewRerunCommand())
+	cmd.SetArgs([]string{"rerun", "1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	environ := recorder.config.Environ
+	if !slices.Contains(environ, "WEB_URL=https://staging.example") || !slices.Contains(environ, "RERUN_TEST_KEEP=1") {
+		t.Errorf("expected the azd environment and --pass-env variables, got %v", environ)
+	}
+	if slices.Contains(environ, "RERUN_DROP=1") {
+		t.Error("expected other inherited variables to be left out of a --clean-env rerun")
+	}
+}
+
+func TestRerunCommand_Errors(t *testing.T) {
+	setupHistory(t, history.Record{InlineHash: "sha256:0123", Status: history.StatusSucceeded})
+
+	for args, want := range map[string]string{
+		"abc": "invalid run ID",
+		"7":   "no run with this ID",
+		"1":   "inline script",
+	} {
+		cmd := NewRerunCommand()
+		cmd.SetArgs([]string{args})
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("rerun %s: error = %v, want %q", args, err, want)
+		}
+	}
+}
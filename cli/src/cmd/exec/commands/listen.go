@@ -2,12 +2,170 @@
 package commands
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/azure/azure-dev/cli/azd/pkg/azdext"
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
 	"github.com/spf13/cobra"
 )
 
+// Variables set for hook scripts in addition to the azd environment.
+const (
+	hookEventEnvVar   = "AZD_EXEC_EVENT"
+	hookServiceEnvVar = "AZD_EXEC_SERVICE"
+)
+
+// hookRegistrar is the part of *azdext.ExtensionHost that subscribes to lifecycle events.
+type hookRegistrar interface {
+	WithProjectEventHandler(eventName string, handler azdext.ProjectEventHandler) *azdext.ExtensionHost
+	WithServiceEventHandler(eventName string, handler azdext.ServiceEventHandler, options *azdext.ServiceEventOptions) *azdext.ExtensionHost
+}
+
+// hookEnvironment returns the values of the current azd environment as KEY=VALUE pairs.
+type hookEnvironment func(ctx context.Context) ([]string, error)
+
+// hookService identifies the service a service event was raised for.
+type hookService struct {
+	name string
+	dir  string
+}
+
 // NewListenCommand creates the listen command that starts the azd extension host.
 // This command is invoked by azd to establish lifecycle event communication via gRPC.
+// It runs the hooks declared in the project configuration when azd raises their events.
 func NewListenCommand() *cobra.Command {
-	return azdext.NewListenCommand(nil)
+	return azdext.NewListenCommand(func(host *azdext.ExtensionHost) {
+		registerHooks(host, azdEnvironment(host.Client()))
+	})
+}
+
+// registerHooks subscribes to every event hooks can be declared for. The project
+// configuration is read when an event is raised, so hooks can be edited without
+// restarting azd.
+func registerHooks(host hookRegistrar, environment hookEnvironment) {
+	for _, event := range project.ProjectHookEvents {
+		host.WithProjectEventHandler(event, func(ctx context.Context, args *azdext.ProjectEventArgs) error {
+			return runHooks(ctx, event, args.Project.GetPath(), nil, environment)
+		})
+	}
+	for _, event := range project.ServiceHookEvents {
+		host.WithServiceEventHandler(event, func(ctx context.Context, args *azdext.ServiceEventArgs) error {
+			root := args.Project.GetPath()
+			service := &hookService{name: args.Service.GetName()}
+			if rel := args.Service.GetRelativePath(); rel != "" && root != "" {
+				service.dir = filepath.Join(root, rel)
+			}
+			return runHooks(ctx, event, root, service, environment)
+		}, nil)
+	}
+}
+
+// runHooks runs the hooks declared for event in the project at root, one after
+// another, stopping at the first failure unless the hook continues on error.
+// For service events only the hooks that apply to service run.
+func runHooks(ctx context.Context, event, root string, service *hookService, environment hookEnvironment) error {
+	if root == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+		root = cwd
+	}
+	cfg, err := project.Load(root)
+	if errors.Is(err, project.ErrNoProject) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var hooks []project.Hook
+	for _, hook := range cfg.Hooks[event] {
+		if service == nil || hook.AppliesTo(service.name) {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	// Read the environment per event: provisioning adds outputs that later hooks need.
+	azdValues, err := environment(ctx)
+	if err != nil {
+		return err
+	}
+	environ := executor.MergeEnv(os.Environ(), azdValues)
+
+	eventVars := []string{hookEventEnvVar + "=" + event}
+	label := event
+	if service != nil {
+		eventVars = append(eventVars, hookServiceEnvVar+"="+service.name)
+		label = event + " (" + service.name + ")"
+	}
+
+	for i, hook := range hooks {
+		workingDir := hook.WorkingDir
+		if workingDir == "" && hook.IsInline() {
+			// Inline hooks run where azd runs its own hooks: the service or project directory.
+			workingDir = cfg.Root
+			if service != nil && service.dir != "" {
+				workingDir = service.dir
+			}
+		}
+
+		cliout.Info("Running %s hook: %s", label, taskCommandSummary(cfg.Root, taskInfo{Script: hook.Script, Run: hook.Run}))
+		exec, err := newTaskExecutor(executor.Config{
+			Shell:          hook.Shell,
+			Args:           hook.Args,
+			WorkingDir:     workingDir,
+			Environ:        environ,
+			AzdEnvironment: azdValues,
+			EnvOverrides:   append(append([]string{}, eventVars...), hook.EnvOverrides()...),
+			// azd runs hooks without a terminal to confirm them on.
+			Trust: executor.TrustRequire,
+		})
+		if err == nil {
+			if hook.IsInline() {
+				err = exec.ExecuteInline(ctx, hook.Run)
+			} else {
+				err = exec.Execute(ctx, hook.Script)
+			}
+		}
+		if err != nil {
+			if hook.ContinueOnError {
+				cliout.Warning("%s hook #%d failed, continuing: %v", label, i+1, err)
+				continue
+			}
+			return fmt.Errorf("%s hook #%d failed: %w", label, i+1, err)
+		}
+	}
+	return nil
+}
+
+// azdEnvironment returns a hookEnvironment that reads the current azd
+// environment through the azd client, with AZURE_ENV_NAME set to its name.
+func azdEnvironment(client *azdext.AzdClient) hookEnvironment {
+	return func(ctx context.Context) ([]string, error) {
+		current, err := client.Environment().GetCurrent(ctx, &azdext.EmptyRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the current azd environment: %w", err)
+		}
+		name := current.GetEnvironment().GetName()
+		resp, err := client.Environment().GetValues(ctx, &azdext.GetEnvironmentRequest{Name: name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load environment '%s': %w", name, err)
+		}
+
+		values := make(map[string]string, len(resp.GetKeyValues()))
+		for _, kv := range resp.GetKeyValues() {
+			values[kv.GetKey()] = kv.GetValue()
+		}
+		return executor.AzdEnvironmentEnv(name, values), nil
+	}
 }
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/azdext"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+// fakeHookHost records the event handlers registered by registerHooks.
+type fakeHookHost struct {
+	project map[string]azdext.ProjectEventHandler
+	service map[string]azdext.ServiceEventHandler
+}
+
+func (h *fakeHookHost) WithProjectEventHandler(eventName string, handler azdext.ProjectEventHandler) *azdext.ExtensionHost {
+	h.project[eventName] = handler
+	return nil
+}
+
+func (h *fakeHookHost) WithServiceEventHandler(eventName string, handler azdext.ServiceEventHandler, _ *azdext.ServiceEventOptions) *azdext.ExtensionHost {
+	h.service[eventName] = handler
+	return nil
+}
+
+// hookRun is one hook script run through the stubbed task executor.
+type hookRun struct {
+	config executor.Config
+	script string
+	inline string
+}
+
+// hookRecorder is a task executor that records runs and fails inline scripts named "fail".
+type hookRecorder struct {
+	config executor.Config
+	runs   *[]hookRun
+}
+
+func (r *hookRecorder) Execute(_ context.Context, scriptPath string) error {
+	*r.runs = append(*r.runs, hookRun{config: r.config, script: scriptPath})
+	return nil
+}
+
+func (r *hookRecorder) ExecuteInline(_ context.Context, scriptContent string) error {
+	*r.runs = append(*r.runs, hookRun{config: r.config, inline: scriptContent})
+	if scriptContent == "fail" {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+// setupHooks writes configuration to a new project, registers hooks against a
+// fake host, and records the hook runs.
+func setupHooks(t *testing.T, config string) (string, *fakeHookHost, *[]hookRun, *int) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "azure.yaml"), []byte("name: app\nexec:\n"+config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldNew := newTaskExecutor
+	t.Cleanup(func() { newTaskExecutor = oldNew })
+	runs := &[]hookRun{}
+	newTaskExecutor = func(config executor.Config) (taskExecutor, error) {
+		return &hookRecorder{config: config, runs: runs}, nil
+	}
+
+	envCalls := new(int)
+	host := &fakeHookHost{project: map[string]azdext.ProjectEventHandler{}, service: map[string]azdext.ServiceEventHandler{}}
+	registerHooks(host, func(context.Context) ([]string, error) {
+		*envCalls++
+		return []string{"AZURE_ENV_NAME=dev", "WEB_URL=https://web.example"}, nil
+	})
+	return root, host, runs, envCalls
+}
+
+func TestRegisterHooks_SubscribesToEvents(t *testing.T) {
+	_, host, _, _ := setupHooks(t, "")
+	for _, event := range []string{"preprovision", "postprovision", "predeploy", "postdeploy"} {
+		if host.project[event] == nil {
+			t.Errorf("no project handler for %s", event)
+		}
+	}
+	if host.service["prepackage"] == nil {
+		t.Error("no service handler for prepackage")
+	}
+}
+
+func TestProjectHooks_RunInOrder(t *testing.T) {
+	root, host, runs, _ := setupHooks(t, `  hooks:
+    postdeploy:
+      - script: ./scripts/smoke.sh
+        shell: bash
+        args: [--quick]
+      - run: echo "$WEB_URL"
+        env:
+          API_KEY: akv://myvault/api-key
+`)
+
+	err := host.project["postdeploy"](context.Background(), &azdext.ProjectEventArgs{Project: &azdext.ProjectConfig{Path: root}})
+	if err != nil {
+		t.Fatalf("postdeploy handler error: %v", err)
+	}
+	if len(*runs) != 2 {
+		t.Fatalf("expected 2 hook runs, got %d", len(*runs))
+	}
+
+	smoke := (*runs)[0]
+	if smoke.script != filepath.Join(root, "scripts", "smoke.sh") || smoke.config.Shell != "bash" || !reflect.DeepEqual(smoke.config.Args, []string{"--quick"}) {
+		t.Errorf("unexpected script hook run: %+v", smoke)
+	}
+	if smoke.config.Trust != executor.TrustRequire {
+		t.Errorf("hook Trust = %q, want %q", smoke.config.Trust, executor.TrustRequire)
+	}
+	if !strings.Contains(strings.Join(smoke.config.Environ, "\n"), "WEB_URL=https://web.example") {
+		t.Error("expected the azd environment in the hook environment")
+	}
+
+	echo := (*runs)[1]
+	if echo.inline != `echo "$WEB_URL"` || echo.config.WorkingDir != root {
+		t.Errorf("unexpected inline hook run: %+v", echo)
+	}
+	if want := []string{"AZD_EXEC_EVENT=postdeploy", "API_KEY=akv://myvault/api-key"}; !reflect.DeepEqual(echo.config.EnvOverrides, want) {
+		t.Errorf("EnvOverrides = %v, want %v", echo.config.EnvOverrides, want)
+	}
+}
+
+func TestProjectHooks_StopAtFailure(t *testing.T) {
+	root, host, runs, _ := setupHooks(t, `  hooks:
+    preprovision:
+      - run: fail
+        continueOnError: true
+      - run: fail
+      - run: never
+`)
+
+	err := host.project["preprovision"](context.Background(), &azdext.ProjectEventArgs{Project: &azdext.ProjectConfig{Path: root}})
+	if err == nil || !strings.Contains(err.Error(), "preprovision hook #2 failed") {
+		t.Fatalf("handler error = %v, want failure of hook #2", err)
+	}
+	if len(*runs) != 2 {
+		t.Errorf("expected hooks after the failure to be skipped, got %d runs", len(*runs))
+	}
+}
+
+func TestProjectHooks_NoHooksSkipsEnvironment(t *testing.T) {
+	root, host, runs, envCalls := setupHooks(t, "  tasks:\n    hello:\n      run: echo hi\n")
+
+	if err := host.project["preprovision"](context.Background(), &azdext.ProjectEventArgs{Project: &azdext.ProjectConfig{Path: root}}); err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	if len(*runs) != 0 || *envCalls != 0 {
+		t.Errorf("expected nothing to run, got %d runs and %d environment reads", len(*runs), *envCalls)
+	}
+}
+
+func TestServiceHooks_FilterByService(t *testing.T) {
+	root, host, runs, _ := setupHooks(t, `  hooks:
+    prepackage:
+      - run: npm run build
+        services: [web]
+      - run: echo all
+`)
+
+	for _, name := range []string{"web", "api"} {
+		err := host.service["prepackage"](context.Background(), &azdext.ServiceEventArgs{
+			Project: &azdext.ProjectConfig{Path: root},
+			Service: &azdext.ServiceConfig{Name: name, RelativePath: "src/" + name},
+		})
+		if err != nil {
+			t.Fatalf("prepackage handler error for %s: %v", name, err)
+		}
+	}
+
+	var got []string
+	for _, run := range *runs {
+		got = append(got, run.inline+" in "+filepath.Base(run.config.WorkingDir))
+	}
+	if want := []string{"npm run build in web", "echo all in web", "echo all in api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hook runs = %v, want %v", got, want)
+	}
+	if last := (*runs)[2].config.EnvOverrides; !reflect.DeepEqual(last, []string{"AZD_EXEC_EVENT=prepackage", "AZD_EXEC_SERVICE=api"}) {
+		t.Errorf("EnvOverrides = %v", last)
+	}
+}
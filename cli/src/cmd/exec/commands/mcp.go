@@ -1,20 +1,16 @@
 package commands
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"runtime"
 	"strings"
 	"time"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/azdext"
 	"github.com/jongio/azd-core/azdextutil"
-	"github.com/jongio/azd-core/keyvault"
 	"github.com/jongio/azd-core/security"
-	"github.com/jongio/azd-core/shellutil"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
 	"github.com/jongio/azd-exec/cli/src/internal/version"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -55,7 +51,7 @@ variables and Azure Key Vault secret resolution.
 
 **Tool Categories:**
 - Execution: exec_script, exec_inline - Run scripts/commands with azd environment context
-- Discovery: list_shells - Discover available shells on the system
+- Discovery: list_shells - Discover available shells and interpreters on the system
 - Configuration: get_environment - View current azd environment variables
 
 **Best Practices:**
@@ -80,7 +76,7 @@ variables and Azure Key Vault secret resolution.
 			mcp.Required(),
 		),
 		mcp.WithString("shell",
-			mcp.Description("Shell to use for execution (bash, sh, zsh, pwsh, powershell, cmd). Auto-detected from file extension if not specified."),
+			mcp.Description("Shell or interpreter to use for execution ("+strings.Join(executor.ShellNames(), ", ")+"). Auto-detected from file extension if not specified."),
 		),
 		mcp.WithString("args",
 			mcp.Description("Space-separated arguments to pass to the script."),
@@ -98,12 +94,12 @@ variables and Azure Key Vault secret resolution.
 			mcp.Required(),
 		),
 		mcp.WithString("shell",
-			mcp.Description("Shell to use (bash, sh, zsh, pwsh, powershell, cmd). Defaults to bash on Unix, powershell on Windows."),
+			mcp.Description("Shell or interpreter to use ("+strings.Join(executor.ShellNames(), ", ")+"). Defaults to bash on Unix, powershell on Windows."),
 		),
 	)
 
 	builder.AddTool("list_shells", handleListShells, azdext.MCPToolOptions{
-		Description: "List shells available on the system for script execution.",
+		Description: "List shells, built-in interpreters (python, node, ...) and project interpreter profiles, with each one's path, version, file extensions and whether it is the default shell.",
 		Title:       "List Available Shells",
 		ReadOnly:    true,
 		Idempotent:  true,
@@ -134,10 +130,8 @@ func handleExecScript(ctx context.Context, args azdext.ToolArgs) (*mcp.CallToolR
 	}
 
 	shell := args.OptionalString("shell", "")
-	if shell != "" {
-		if err := azdextutil.ValidateShellName(shell); err != nil {
-			return azdext.MCPErrorResult("Invalid shell: %v", err), nil
-		}
+	if shell != "" && !executor.IsValidShell(shell) {
+		return azdext.MCPErrorResult("Invalid shell: %v", &executor.InvalidShellError{Shell: shell}), nil
 	}
 
 	// Validate script path for security
@@ -151,43 +145,17 @@ func handleExecScript(ctx context.Context, args azdext.ToolArgs) (*mcp.CallToolR
 		return azdext.MCPErrorResult("Invalid script path: %v", err), nil
 	}
 
-	info, statErr := os.Stat(validPath)
-	if statErr != nil {
-		return azdext.MCPErrorResult("Script file not found: %s", scriptPath), nil
-	}
-	if info.IsDir() {
-		return azdext.MCPErrorResult("script_path must be a file, not a directory"), nil
-	}
-
 	// Parse extra args
 	var scriptArgs []string
 	if argsStr := args.OptionalString("args", ""); argsStr != "" {
 		scriptArgs = strings.Fields(argsStr)
 	}
 
-	// Detect shell
-	if shell == "" {
-		shell = shellutil.DetectShell(validPath)
+	scriptExec, err := newMCPExecutor(ctx, shell, scriptArgs)
+	if err != nil {
+		return azdext.MCPErrorResult("Invalid configuration: %v", err), nil
 	}
-
-	// Build and execute command
-	timeout := defaultTimeout
-	execCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	cmdArgs := buildShellArgs(shell, validPath, false, scriptArgs)
-	cmd := exec.CommandContext(execCtx, cmdArgs[0], cmdArgs[1:]...)
-
-	// Resolve Key Vault references in environment variables, matching the
-	// CLI execution path behavior. Continue on error (best-effort).
-	cmd.Env = prepareEnvironmentForMCP(ctx)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	runErr := cmd.Run()
-	return marshalExecResult(stdout.String(), stderr.String(), cmd.ProcessState, runErr), nil
+	return marshalExecResult(scriptExec.RunFile(ctx, validPath)), nil
 }
 
 // --- exec_inline handler ---
@@ -199,65 +167,21 @@ func handleExecInline(ctx context.Context, args azdext.ToolArgs) (*mcp.CallToolR
 	}
 
 	shell := args.OptionalString("shell", "")
-	if shell != "" {
-		if err := azdextutil.ValidateShellName(shell); err != nil {
-			return azdext.MCPErrorResult("Invalid shell: %v", err), nil
-		}
+	if shell != "" && !executor.IsValidShell(shell) {
+		return azdext.MCPErrorResult("Invalid shell: %v", &executor.InvalidShellError{Shell: shell}), nil
 	}
-	if shell == "" {
-		if runtime.GOOS == "windows" {
-			shell = shellutil.ShellPowerShell
-		} else {
-			shell = shellutil.ShellBash
-		}
-	}
-
-	timeout := defaultTimeout
-	execCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	cmdArgs := buildShellArgs(shell, command, true, nil)
-	cmd := exec.CommandContext(execCtx, cmdArgs[0], cmdArgs[1:]...)
-
-	// Resolve Key Vault references in environment variables, matching the
-	// CLI execution path behavior. Continue on error (best-effort).
-	cmd.Env = prepareEnvironmentForMCP(ctx)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
 
-	runErr := cmd.Run()
-	return marshalExecResult(stdout.String(), stderr.String(), cmd.ProcessState, runErr), nil
+	scriptExec, err := newMCPExecutor(ctx, shell, nil)
+	if err != nil {
+		return azdext.MCPErrorResult("Invalid configuration: %v", err), nil
+	}
+	return marshalExecResult(scriptExec.RunInline(ctx, command)), nil
 }
 
 // --- list_shells handler ---
 
-type shellInfo struct {
-	Name      string `json:"name"`
-	Available bool   `json:"available"`
-}
-
-func handleListShells(_ context.Context, _ azdext.ToolArgs) (*mcp.CallToolResult, error) {
-	shells := []string{
-		shellutil.ShellBash,
-		shellutil.ShellSh,
-		shellutil.ShellZsh,
-		shellutil.ShellPwsh,
-		shellutil.ShellPowerShell,
-		shellutil.ShellCmd,
-	}
-
-	results := make([]shellInfo, 0, len(shells))
-	for _, sh := range shells {
-		_, err := exec.LookPath(sh)
-		results = append(results, shellInfo{
-			Name:      sh,
-			Available: err == nil,
-		})
-	}
-
-	return azdext.MCPJSONResult(results), nil
+func handleListShells(ctx context.Context, _ azdext.ToolArgs) (*mcp.CallToolResult, error) {
+	return azdext.MCPJSONResult(listShells(ctx)), nil
 }
 
 // --- get_environment handler ---
@@ -318,93 +242,44 @@ func handleGetEnvironment(_ context.Context, _ azdext.ToolArgs) (*mcp.CallToolRe
 
 // --- Helpers ---
 
-// prepareEnvironmentForMCP resolves Key Vault references in environment variables.
-// This mirrors the CLI execution path (executor.prepareEnvironment) to ensure
-// consistent behavior between CLI and MCP invocations. Operates in best-effort
-// mode: if resolution fails, the original environment is returned unchanged.
-func prepareEnvironmentForMCP(ctx context.Context) []string {
-	envVars := os.Environ()
-
-	// Quick check: skip resolver setup if no Key Vault references are present.
-	hasRef := false
-	for _, envVar := range envVars {
-		if parts := strings.SplitN(envVar, "=", 2); len(parts) == 2 && keyvault.IsKeyVaultReference(parts[1]) {
-			hasRef = true
-			break
-		}
-	}
-	if !hasRef {
-		return envVars
-	}
-
-	resolver, err := keyvault.NewKeyVaultResolver()
+// newMCPExecutor creates an executor for the MCP tools. It runs scripts exactly
+// as the CLI does, but captures their output for the tool result, bounds them by
+// defaultTimeout, and keeps stdout free for the MCP protocol.
+// Key Vault resolution is best-effort, as it is for the CLI by default.
+// Script files must already be trusted: nobody can confirm them, and stdin
+// carries the MCP protocol.
+func newMCPExecutor(ctx context.Context, shell string, scriptArgs []string) (*executor.Executor, error) {
+	azdEnv, err := AzdEnvironmentScope(ctx, "")
 	if err != nil {
-		// Cannot create resolver — fall back to raw environment.
-		fmt.Fprintf(os.Stderr, "Warning: failed to create Key Vault resolver: %v\n", err)
-		return envVars
+		return nil, err
 	}
-
-	resolved, _, err := resolver.ResolveEnvironmentVariables(ctx, envVars, keyvault.ResolveEnvironmentOptions{StopOnError: false})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Key Vault resolution error: %v\n", err)
-		return envVars
-	}
-
-	return resolved
+	return executor.New(executor.Config{
+		Shell:          shell,
+		Args:           scriptArgs,
+		Timeout:        defaultTimeout,
+		AzdEnvironment: azdEnv,
+		CaptureOutput:  true,
+		Stderr:         os.Stderr,
+		Trust:          executor.TrustRequire,
+	})
 }
 
+// execResult is the tool result; it shares its schema with `azd exec --output json`,
+// except that stdout and stderr are always present, as tool clients expect them.
 type execResult struct {
-	Stdout   string `json:"stdout"`
-	Stderr   string `json:"stderr"`
-	ExitCode int    `json:"exitCode"`
-	Error    string `json:"error,omitempty"`
-}
-
-func marshalExecResult(stdout, stderr string, ps *os.ProcessState, err error) *mcp.CallToolResult {
-	result := execResult{
-		Stdout: stdout,
-		Stderr: stderr,
-	}
-	if ps != nil {
-		result.ExitCode = ps.ExitCode()
-	}
-	if err != nil {
-		result.Error = err.Error()
-		if result.ExitCode == 0 {
-			result.ExitCode = -1
-		}
-	}
-	return azdext.MCPJSONResult(result)
+	executor.Result
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
 }
 
-// buildShellArgs constructs command arguments for the given shell.
-func buildShellArgs(shell, scriptOrCmd string, isInline bool, extraArgs []string) []string {
-	shellLower := strings.ToLower(shell)
-	switch shellLower {
-	case "cmd":
-		if isInline {
-			return []string{"cmd", "/c", scriptOrCmd}
-		}
-		args := make([]string, 0, 3+len(extraArgs))
-		args = append(args, "cmd", "/c", scriptOrCmd)
-		args = append(args, extraArgs...)
-		return args
-	case "powershell", "pwsh":
-		if isInline {
-			return []string{shellLower, "-NoProfile", "-Command", scriptOrCmd}
-		}
-		args := make([]string, 0, 4+len(extraArgs))
-		args = append(args, shellLower, "-NoProfile", "-File", scriptOrCmd)
-		args = append(args, extraArgs...)
-		return args
-	default:
-		// bash, sh, zsh
-		if isInline {
-			return []string{shellLower, "-c", scriptOrCmd}
+// marshalExecResult converts the outcome of an executor run into a tool result.
+// Runs that never started (invalid script, failed environment preparation) are tool errors.
+func marshalExecResult(result *executor.Result, err error) *mcp.CallToolResult {
+	if result == nil {
+		if err == nil {
+			return azdext.MCPErrorResult("script did not run")
 		}
-		args := make([]string, 0, 2+len(extraArgs))
-		args = append(args, shellLower, scriptOrCmd)
-		args = append(args, extraArgs...)
-		return args
+		return azdext.MCPErrorResult("%v", err)
 	}
+	return azdext.MCPJSONResult(execResult{Result: *result, Stdout: result.Stdout, Stderr: result.Stderr})
 }
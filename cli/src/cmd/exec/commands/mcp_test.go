@@ -11,109 +11,12 @@ import (
 	"testing"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/azdext"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/trust"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/spf13/cobra"
 )
 
-// ---------------------------------------------------------------------------
-// TestBuildShellArgs
-// ---------------------------------------------------------------------------
-
-func TestBuildShellArgs(t *testing.T) {
-	tests := []struct {
-		name      string
-		shell     string
-		script    string
-		isInline  bool
-		extra     []string
-		wantBin   string
-		wantParts []string
-	}{
-		// bash inline
-		{
-			name: "bash inline", shell: "bash", script: "echo hi", isInline: true,
-			wantBin: "bash", wantParts: []string{"bash", "-c", "echo hi"},
-		},
-		// bash file with extra args
-		{
-			name: "bash file", shell: "bash", script: "/tmp/run.sh", isInline: false, extra: []string{"--flag"},
-			wantBin: "bash", wantParts: []string{"bash", "/tmp/run.sh", "--flag"},
-		},
-		// pwsh inline
-		{
-			name: "pwsh inline", shell: "pwsh", script: "Get-Date", isInline: true,
-			wantBin: "pwsh", wantParts: []string{"pwsh", "-NoProfile", "-Command", "Get-Date"},
-		},
-		// pwsh file
-		{
-			name: "pwsh file", shell: "pwsh", script: "run.ps1", isInline: false, extra: []string{"a", "b"},
-			wantBin: "pwsh", wantParts: []string{"pwsh", "-NoProfile", "-File", "run.ps1", "a", "b"},
-		},
-		// powershell inline
-		{
-			name: "powershell inline", shell: "powershell", script: "Write-Host hello", isInline: true,
-			wantBin: "powershell", wantParts: []string{"powershell", "-NoProfile", "-Command", "Write-Host hello"},
-		},
-		// powershell file
-		{
-			name: "powershell file", shell: "powershell", script: "run.ps1", isInline: false,
-			wantBin: "powershell", wantParts: []string{"powershell", "-NoProfile", "-File", "run.ps1"},
-		},
-		// cmd inline
-		{
-			name: "cmd inline", shell: "cmd", script: "dir", isInline: true,
-			wantBin: "cmd", wantParts: []string{"cmd", "/c", "dir"},
-		},
-		// cmd file with args
-		{
-			name: "cmd file", shell: "cmd", script: "run.bat", isInline: false, extra: []string{"/v"},
-			wantBin: "cmd", wantParts: []string{"cmd", "/c", "run.bat", "/v"},
-		},
-		// sh inline
-		{
-			name: "sh inline", shell: "sh", script: "ls", isInline: true,
-			wantBin: "sh", wantParts: []string{"sh", "-c", "ls"},
-		},
-		// zsh file
-		{
-			name: "zsh file", shell: "zsh", script: "run.zsh", isInline: false, extra: []string{"x"},
-			wantBin: "zsh", wantParts: []string{"zsh", "run.zsh", "x"},
-		},
-		// case insensitivity: BASH → bash
-		{
-			name: "uppercase BASH", shell: "BASH", script: "echo hi", isInline: true,
-			wantBin: "bash", wantParts: []string{"bash", "-c", "echo hi"},
-		},
-		// mixed case: Pwsh → pwsh
-		{
-			name: "mixed case Pwsh", shell: "Pwsh", script: "Get-Date", isInline: true,
-			wantBin: "pwsh", wantParts: []string{"pwsh", "-NoProfile", "-Command", "Get-Date"},
-		},
-		// mixed case CMD → cmd
-		{
-			name: "mixed case CMD", shell: "CMD", script: "dir", isInline: true,
-			wantBin: "cmd", wantParts: []string{"cmd", "/c", "dir"},
-		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			got := buildShellArgs(tc.shell, tc.script, tc.isInline, tc.extra)
-			if got[0] != tc.wantBin {
-				t.Errorf("binary = %q, want %q", got[0], tc.wantBin)
-			}
-			if len(got) != len(tc.wantParts) {
-				t.Fatalf("args length = %d, want %d: %v", len(got), len(tc.wantParts), got)
-			}
-			for i := range tc.wantParts {
-				if got[i] != tc.wantParts[i] {
-					t.Errorf("arg[%d] = %q, want %q", i, got[i], tc.wantParts[i])
-				}
-			}
-		})
-	}
-}
-
 // ---------------------------------------------------------------------------
 // TestHandleGetEnvironment_SecretFiltering
 // ---------------------------------------------------------------------------
@@ -205,13 +108,14 @@ func TestParseTimeout(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestMarshalExecResult(t *testing.T) {
-	t.Run("success with no error", func(t *testing.T) {
-		result := marshalExecResult("hello\n", "", nil, nil)
+	t.Run("finished run", func(t *testing.T) {
+		result := marshalExecResult(&executor.Result{Shell: "bash", Stdout: "hello\n", ExitCode: 2, Error: "script exited with code 2 (shell: bash)"},
+			errors.New("script exited with code 2 (shell: bash)"))
 		if result == nil {
 			t.Fatal("expected non-nil result")
 		}
-		if len(result.Content) == 0 {
-			t.Fatal("expected content")
+		if result.IsError {
+			t.Error("expected a finished run to be a regular result, even when the script failed")
 		}
 		textContent, ok := result.Content[0].(mcp.TextContent)
 		if !ok {
@@ -225,33 +129,29 @@ func TestMarshalExecResult(t *testing.T) {
 		if er.Stdout != "hello\n" {
 			t.Errorf("Stdout = %q, want %q", er.Stdout, "hello\n")
 		}
-		if er.ExitCode != 0 {
-			t.Errorf("ExitCode = %d, want 0", er.ExitCode)
+		if er.ExitCode != 2 {
+			t.Errorf("ExitCode = %d, want 2", er.ExitCode)
+		}
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(textContent.Text), &fields); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
 		}
-		if er.Error != "" {
-			t.Errorf("Error = %q, want empty", er.Error)
+		if _, ok := fields["stderr"]; !ok {
+			t.Errorf("expected stderr to be present even when empty: %s", textContent.Text)
 		}
 	})
 
-	t.Run("with error and nil process state", func(t *testing.T) {
-		result := marshalExecResult("", "oops", nil, errors.New("command failed"))
+	t.Run("run that never started", func(t *testing.T) {
+		result := marshalExecResult(nil, errors.New("script not found: run.sh"))
+		if !result.IsError {
+			t.Fatal("expected IsError=true when the script did not start")
+		}
 		textContent, ok := result.Content[0].(mcp.TextContent)
 		if !ok {
 			t.Fatalf("expected TextContent, got %T", result.Content[0])
 		}
-
-		var er execResult
-		if err := json.Unmarshal([]byte(textContent.Text), &er); err != nil {
-			t.Fatalf("failed to unmarshal: %v", err)
-		}
-		if er.ExitCode != -1 {
-			t.Errorf("ExitCode = %d, want -1 when process state is nil and error present", er.ExitCode)
-		}
-		if er.Error != "command failed" {
-			t.Errorf("Error = %q, want %q", er.Error, "command failed")
-		}
-		if er.Stderr != "oops" {
-			t.Errorf("Stderr = %q, want %q", er.Stderr, "oops")
+		if !strings.Contains(textContent.Text, "script not found") {
+			t.Errorf("expected error text, got %q", textContent.Text)
 		}
 	})
 }
@@ -332,6 +232,7 @@ func TestHandleListShells(t *testing.T) {
 	expectedShells := map[string]bool{
 		"bash": false, "sh": false, "zsh": false,
 		"pwsh": false, "powershell": false, "cmd": false,
+		"python": false, "node": false, "go": false,
 	}
 	for _, s := range shells {
 		if _, ok := expectedShells[s.Name]; ok {
@@ -444,14 +345,42 @@ func TestHandleExecScript_Validation(t *testing.T) {
 		}
 	})
 
+	t.Run("untrusted script returns error result", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("AZD_EXEC_PROJECT_DIR", tmpDir)
+		t.Setenv(trust.EnvVarTrustFile, filepath.Join(tmpDir, "trust.json"))
+
+		scriptPath := filepath.Join(tmpDir, "test.sh")
+		if writeErr := os.WriteFile(scriptPath, []byte("echo hello\n"), 0o600); writeErr != nil {
+			t.Fatalf("WriteFile failed: %v", writeErr)
+		}
+
+		result, err := handleExecScript(context.Background(), makeToolArgs(map[string]interface{}{"script_path": scriptPath}))
+		if err != nil {
+			t.Fatalf("unexpected Go error: %v", err)
+		}
+		if !result.IsError {
+			t.Error("expected IsError=true for a script that is not trusted")
+		}
+	})
+
 	t.Run("valid script executes successfully", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		t.Setenv("AZD_EXEC_PROJECT_DIR", tmpDir)
+		t.Setenv(trust.EnvVarTrustFile, filepath.Join(tmpDir, "trust.json"))
 
 		scriptPath := filepath.Join(tmpDir, "test.ps1")
-		if writeErr := os.WriteFile(scriptPath, []byte("Write-Host 'hello'\n"), 0o600); writeErr != nil {
+		content := []byte("Write-Host 'hello'\n")
+		if writeErr := os.WriteFile(scriptPath, content, 0o600); writeErr != nil {
 			t.Fatalf("WriteFile failed: %v", writeErr)
 		}
+		store, err := trust.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Trust(scriptPath, content); err != nil {
+			t.Fatal(err)
+		}
 
 		args := makeToolArgs(map[string]interface{}{"script_path": scriptPath, "shell": "powershell", "args": "--verbose"})
 		result, err := handleExecScript(context.Background(), args)
@@ -530,3 +459,35 @@ func TestHandleExecInline_Execution(t *testing.T) {
 		t.Logf("Default shell result: exitCode=%d stdout=%q", er.ExitCode, er.Stdout)
 	})
 }
+
+func TestHandleExecInline_MatchesCLIExecution(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	args := makeToolArgs(map[string]interface{}{"command": "echo hello; echo oops >&2; exit 3", "shell": "bash"})
+	result, err := handleExecInline(context.Background(), args)
+	if err != nil {
+		t.Fatalf("unexpected Go error: %v", err)
+	}
+	textContent, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected TextContent, got %T", result.Content[0])
+	}
+	var er execResult
+	if unmarshalErr := json.Unmarshal([]byte(textContent.Text), &er); unmarshalErr != nil {
+		t.Fatalf("failed to unmarshal: %v", unmarshalErr)
+	}
+
+	if er.ExitCode != 3 || er.Error == "" {
+		t.Errorf("expected exit code 3 with an error, got %d / %q", er.ExitCode, er.Error)
+	}
+	if er.Stdout != "hello\n" || er.Stderr != "oops\n" {
+		t.Errorf("unexpected output: stdout=%q stderr=%q", er.Stdout, er.Stderr)
+	}
+	// The command line comes from the executor, exactly as the CLI builds it.
+	want := []string{"bash", "-c", "echo hello; echo oops >&2; exit 3"}
+	if strings.Join(er.Command, "|") != strings.Join(want, "|") {
+		t.Errorf("Command = %v, want %v", er.Command, want)
+	}
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/spf13/cobra"
+)
+
+type secretsInspector interface {
+	SecretReferences() ([]executor.SecretReference, error)
+	CheckSecretReferences(ctx context.Context) ([]executor.SecretCheck, error)
+}
+
+var newSecretsInspector = func(config executor.Config) (secretsInspector, error) {
+	return executor.New(config)
+}
+
+// NewSecretsCommand creates the secrets command that inspects the Key Vault and
+// other secret references in the environment scripts would run with.
+func NewSecretsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Inspect and validate secret references in the environment",
+		Long: `Inspect the Key Vault and other secret references (env://, file://, sops://,
+pass://, appconfig:// and any other registered provider) in the environment scripts
+run with, including the azd environment selected with -e. Secret values are never shown.`,
+	}
+	cmd.AddCommand(newSecretsListCommand(), newSecretsCheckCommand())
+	return cmd
+}
+
+func newSecretsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Short:        "List environment variables that hold secret references",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			azdEnv, err := AzdEnvironmentScope(cmd.Context(), selectedEnvironment(cmd))
+			if err != nil {
+				return err
+			}
+			inspector, err := newSecretsInspector(executor.Config{AzdEnvironment: azdEnv})
+			if err != nil {
+				return err
+			}
+			refs, err := inspector.SecretReferences()
+			if err != nil {
+				return err
+			}
+			if refs == nil {
+				refs = []executor.SecretReference{}
+			}
+
+			return cliout.Print(refs, func() {
+				if len(refs) == 0 {
+					cliout.Info("No secret references in the environment")
+					return
+				}
+				rows := make([]cliout.TableRow, 0, len(refs))
+				for _, ref := range refs {
+					rows = append(rows, cliout.TableRow{
+						"Variable":  ref.Name,
+						"Provider":  ref.Provider,
+						"Reference": secretReferenceText(ref),
+					})
+				}
+				cliout.Table([]string{"Variable", "Provider", "Reference"}, rows)
+			})
+		},
+	}
+}
+
+func newSecretsCheckCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Resolve every secret reference and report which ones fail",
+		Long: `Resolve every Key Vault and other secret reference in the environment without
+running a script, bypassing the secret cache, and report success or failure per variable.
+
+Exits with 0 when every reference resolves (or there are none) and 1 otherwise,
+so it can gate CI pipelines.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			azdEnv, err := AzdEnvironmentScope(cmd.Context(), selectedEnvironment(cmd))
+			if err != nil {
+				return err
+			}
+			inspector, err := newSecretsInspector(executor.Config{AzdEnvironment: azdEnv})
+			if err != nil {
+				return err
+			}
+			checks, err := inspector.CheckSecretReferences(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, check := range checks {
+				if !check.Resolved {
+					failed++
+				}
+			}
+
+			if err := cliout.Print(checks, func() {
+				if len(checks) == 0 {
+					cliout.Info("No secret references in the environment")
+					return
+				}
+				rows := make([]cliout.TableRow, 0, len(checks))
+				for _, check := range checks {
+					status := "ok"
+					if !check.Resolved {
+						status = "failed (" + check.ErrorClass + ")"
+					}
+					rows = append(rows, cliout.TableRow{
+						"Variable":  check.Name,
+						"Reference": secretReferenceText(check.SecretReference),
+						"Status":    status,
+						"Error":     check.Error,
+					})
+				}
+				cliout.Table([]string{"Variable", "Reference", "Status", "Error"}, rows)
+			}); err != nil {
+				return err
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d secret references failed to resolve", failed, len(checks))
+			}
+			return nil
+		},
+	}
+}
+
+// secretReferenceText describes a secret reference for text output: the vault,
+// secret and version of Key Vault references, or else the reference itself.
+func secretReferenceText(ref executor.SecretReference) string {
+	if ref.Vault == "" && ref.Secret == "" {
+		return ref.Reference
+	}
+	version := ref.Version
+	if strings.TrimSpace(version) == "" {
+		version = "latest"
+	}
+	return ref.Vault + "/" + ref.Secret + " (" + version + ")"
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+type fakeSecretsInspector struct {
+	checks []executor.SecretCheck
+}
+
+func (f *fakeSecretsInspector) SecretReferences() ([]executor.SecretReference, error) {
+	refs := make([]executor.SecretReference, 0, len(f.checks))
+	for _, check := range f.checks {
+		refs = append(refs, check.SecretReference)
+	}
+	return refs, nil
+}
+
+func (f *fakeSecretsInspector) CheckSecretReferences(context.Context) ([]executor.SecretCheck, error) {
+	return f.checks, nil
+}
+
+func stubSecretsInspector(t *testing.T, checks ...executor.SecretCheck) {
+	t.Helper()
+	oldNew := newSecretsInspector
+	t.Cleanup(func() { newSecretsInspector = oldNew })
+	newSecretsInspector = func(executor.Config) (secretsInspector, error) {
+		return &fakeSecretsInspector{checks: checks}, nil
+	}
+}
+
+func TestSecretsListCommand(t *testing.T) {
+	t.Setenv("AZD_EXEC_SECRETS_TEST", "@Microsoft.KeyVault(VaultName=kv;SecretName=db;SecretVersion=v1)")
+	t.Setenv("AZD_EXEC_SECRETS_TEST_ENV", "env://AZD_EXEC_SECRETS_TEST_SOURCE")
+
+	cmd := NewSecretsCommand()
+	cmd.SetArgs([]string{"list"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+}
+
+func TestSecretReferenceText(t *testing.T) {
+	tests := []struct {
+		ref  executor.SecretReference
+		want string
+	}{
+		{ref: executor.SecretReference{Provider: "akv", Vault: "kv", Secret: "db"}, want: "kv/db (latest)"},
+		{ref: executor.SecretReference{Provider: "akv", Vault: "kv", Secret: "db", Version: "v1"}, want: "kv/db (v1)"},
+		{ref: executor.SecretReference{Provider: "env", Reference: "env://CI_TOKEN"}, want: "env://CI_TOKEN"},
+	}
+	for _, tt := range tests {
+		if got := secretReferenceText(tt.ref); got != tt.want {
+			t.Errorf("secretReferenceText(%+v) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestSecretsCheckCommand(t *testing.T) {
+	ok := executor.SecretCheck{
+		SecretReference: executor.SecretReference{Name: "DB", Provider: "akv", Vault: "kv", Secret: "db"},
+		Resolved:        true,
+	}
+	missing := executor.SecretCheck{
+		SecretReference: executor.SecretReference{Name: "API_KEY", Provider: "file", Reference: "file://.env.secrets#API_KEY"},
+		ErrorClass:      executor.SecretErrorNotFound,
+		Error:           "no API_KEY in .env.secrets",
+	}
+
+	tests := []struct {
+		name    string
+		checks  []executor.SecretCheck
+		wantErr string
+	}{
+		{name: "no references"},
+		{name: "all resolved", checks: []executor.SecretCheck{ok}},
+		{name: "failure", checks: []executor.SecretCheck{missing, ok}, wantErr: "1 of 2 secret references failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSecretsInspector(t, tt.checks...)
+
+			cmd := NewSecretsCommand()
+			cmd.SetArgs([]string{"check"})
+			err := cmd.Execute()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-core/shellutil"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/spf13/cobra"
+)
+
+// Kinds of entries reported by `azd exec shells` and the list_shells MCP tool.
+const (
+	shellKindShell       = "shell"
+	shellKindInterpreter = "interpreter"
+)
+
+// versionTimeout bounds how long a shell may take to report its version.
+const versionTimeout = 5 * time.Second
+
+// psVersionCommand prints the PowerShell version from $PSVersionTable.
+const psVersionCommand = "$PSVersionTable.PSVersion.ToString()"
+
+// versionPattern matches the first dotted version number in version output,
+// e.g. 5.2.21 in "GNU bash, version 5.2.21(1)-release".
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// shellInfo describes a shell or interpreter that scripts can run with.
+type shellInfo struct {
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Source     string   `json:"source"`
+	Command    []string `json:"command"`
+	Extensions []string `json:"extensions,omitempty"`
+	Available  bool     `json:"available"`
+	Path       string   `json:"path,omitempty"`
+	Version    string   `json:"version,omitempty"`
+	Default    bool     `json:"default"`
+
+	// versionArgs make the executable print its version.
+	versionArgs []string
+}
+
+// listShells returns every built-in shell followed by every registered interpreter,
+// including profiles from the project configuration. Installed built-in
+// executables are asked for their version concurrently. Project profiles are
+// listed without a version: their executables are chosen by the repository and
+// are not run just to list them.
+func listShells(ctx context.Context) []shellInfo {
+	names := executor.ShellNames()
+	defaultShell := executor.DefaultShell()
+	shells := make([]shellInfo, 0, len(names))
+	for _, name := range names {
+		info := shellInfo{
+			Name:        name,
+			Kind:        shellKindShell,
+			Source:      executor.InterpreterSourceBuiltin,
+			Command:     []string{name},
+			Extensions:  executor.ExtensionsForShell(name),
+			Default:     name == defaultShell,
+			versionArgs: shellVersionArgs(name),
+		}
+		if interp, ok := executor.LookupInterpreter(name); ok {
+			info.Kind = shellKindInterpreter
+			info.Source = interp.Source
+			info.Command = interp.Command
+			info.versionArgs = interp.VersionArgs
+			if len(info.versionArgs) == 0 {
+				info.versionArgs = []string{"--version"}
+			}
+			if interp.Source == executor.InterpreterSourceProject {
+				info.versionArgs = nil
+			}
+		}
+		if path, err := exec.LookPath(info.Command[0]); err == nil {
+			info.Available = true
+			info.Path = path
+		}
+		shells = append(shells, info)
+	}
+
+	var wg sync.WaitGroup
+	for i := range shells {
+		if !shells[i].Available || len(shells[i].versionArgs) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(sh *shellInfo) {
+			defer wg.Done()
+			sh.Version = detectVersion(ctx, sh.Path, sh.versionArgs)
+		}(&shells[i])
+	}
+	wg.Wait()
+	return shells
+}
+
+// shellVersionArgs returns the arguments that make a built-in shell print its
+// version, or nil for shells without a version flag.
+func shellVersionArgs(name string) []string {
+	switch name {
+	case shellutil.ShellBash, shellutil.ShellZsh:
+		return []string{"--version"}
+	case shellutil.ShellPwsh, shellutil.ShellPowerShell:
+		return []string{"-NoProfile", "-NonInteractive", "-Command", psVersionCommand}
+	case shellutil.ShellCmd:
+		return []string{"/c", "ver"}
+	}
+	// sh is often dash, which has no way to report its version.
+	return nil
+}
+
+// detectVersion runs path with args and returns the version number it prints,
+// or the first line of its output if there is no recognizable number.
+// Returns an empty string if the command fails.
+func detectVersion(ctx context.Context, path string, args []string) string {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput() // #nosec G204 -- path is a built-in shell or interpreter found on PATH
+	if err != nil {
+		return ""
+	}
+	return parseVersion(string(out))
+}
+
+// parseVersion extracts a version from version command output.
+func parseVersion(output string) string {
+	for line := range strings.Lines(output) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if v := versionPattern.FindString(line); v != "" {
+			return v
+		}
+		return line
+	}
+	return ""
+}
+
+// NewShellsCommand creates the shells command that lists the shells and
+// interpreters scripts can run with.
+func NewShellsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "shells",
+		Short: "List the shells and interpreters scripts can run with",
+		Long: `List the built-in shells, the built-in interpreters and the interpreter
+profiles declared in the project configuration, with where each is installed,
+its version, the extensions that select it, and which shell is the default.
+Project profiles are shown without a version, so listing them never runs a
+program the repository chose.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shells := listShells(cmd.Context())
+			return cliout.Print(shells, func() {
+				rows := make([]cliout.TableRow, 0, len(shells))
+				for _, sh := range shells {
+					path := sh.Path
+					if !sh.Available {
+						path = "not found"
+					}
+					name := sh.Name
+					if sh.Default {
+						name += " (default)"
+					}
+					rows = append(rows, cliout.TableRow{
+						"Name":       name,
+						"Source":     sh.Source,
+						"Version":    sh.Version,
+						"Path":       path,
+						"Extensions": strings.Join(sh.Extensions, " "),
+					})
+				}
+				cliout.Table([]string{"Name", "Source", "Version", "Path", "Extensions"}, rows)
+			})
+		},
+	}
+}
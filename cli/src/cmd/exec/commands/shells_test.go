@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+func TestListShells(t *testing.T) {
+	if err := executor.RegisterInterpreter(executor.Interpreter{
+		Name:       "shells-test-runner",
+		Command:    []string{"shells-test-runner-missing", "run"},
+		FileArgs:   []string{executor.ScriptPlaceholder},
+		Extensions: []string{".shellstest"},
+		Source:     executor.InterpreterSourceProject,
+	}); err != nil {
+		t.Fatalf("RegisterInterpreter() error: %v", err)
+	}
+
+	byName := map[string]shellInfo{}
+	for _, sh := range listShells(context.Background()) {
+		byName[sh.Name] = sh
+	}
+
+	bash, ok := byName["bash"]
+	if !ok || bash.Kind != shellKindShell || bash.Source != executor.InterpreterSourceBuiltin {
+		t.Errorf("bash = %+v, want a built-in shell", bash)
+	}
+	node, ok := byName["node"]
+	if !ok || node.Kind != shellKindInterpreter || node.Source != executor.InterpreterSourceBuiltin {
+		t.Errorf("node = %+v, want a built-in interpreter", node)
+	}
+
+	custom, ok := byName["shells-test-runner"]
+	if !ok {
+		t.Fatal("expected the registered interpreter in the list")
+	}
+	if custom.Kind != shellKindInterpreter || custom.Source != executor.InterpreterSourceProject {
+		t.Errorf("custom = %+v, want a project interpreter", custom)
+	}
+	if len(custom.Command) != 2 || custom.Command[0] != "shells-test-runner-missing" {
+		t.Errorf("Command = %v", custom.Command)
+	}
+	if len(custom.Extensions) != 1 || custom.Extensions[0] != ".shellstest" {
+		t.Errorf("Extensions = %v, want [.shellstest]", custom.Extensions)
+	}
+	if custom.Available || custom.Path != "" || custom.Version != "" {
+		t.Errorf("expected a missing executable to be reported as unavailable, got %+v", custom)
+	}
+
+	var defaults []string
+	for name, sh := range byName {
+		if sh.Default {
+			defaults = append(defaults, name)
+		}
+	}
+	if want := []string{executor.DefaultShell()}; !reflect.DeepEqual(defaults, want) {
+		t.Errorf("default shells = %v, want %v", defaults, want)
+	}
+}
+
+func TestListShells_DetectsInstalledShell(t *testing.T) {
+	path, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	for _, sh := range listShells(context.Background()) {
+		if sh.Name != "bash" {
+			continue
+		}
+		if !sh.Available || sh.Path != path {
+			t.Errorf("Path = %q, want %q", sh.Path, path)
+		}
+		if sh.Version == "" {
+			t.Error("expected a bash version")
+		}
+		if !reflect.DeepEqual(sh.Extensions, []string{".sh"}) {
+			t.Errorf("Extensions = %v, want [.sh]", sh.Extensions)
+		}
+		return
+	}
+	t.Fatal("bash not listed")
+}
+
+func TestListShells_DoesNotRunProjectInterpreters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping shell script interpreter on Windows")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	runner := filepath.Join(dir, "runner")
+	if err := os.WriteFile(runner, []byte("#!/bin/sh\ntouch "+marker+"\necho 1.0.0\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := executor.RegisterInterpreter(executor.Interpreter{
+		Name:     "shells-test-project",
+		Command:  []string{runner},
+		FileArgs: []string{executor.ScriptPlaceholder},
+		Source:   executor.InterpreterSourceProject,
+	}); err != nil {
+		t.Fatalf("RegisterInterpreter() error: %v", err)
+	}
+
+	for _, sh := range listShells(context.Background()) {
+		if sh.Name == "shells-test-project" && (!sh.Available || sh.Version != "") {
+			t.Errorf("project interpreter = %+v, want it available without a version", sh)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("listing shells ran the project interpreter's executable")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: "GNU bash, version 5.2.21(1)-release (x86_64-pc-linux-gnu)\nCopyright", want: "5.2.21"},
+		{output: "7.4.1\r\n", want: "7.4.1"},
+		{output: "\nMicrosoft Windows [Version 10.0.22631.4317]\n", want: "10.0.22631.4317"},
+		{output: "v20.11.1\n", want: "20.11.1"},
+		{output: "go version go1.26.1 linux/amd64\n", want: "1.26.1"},
+		{output: "custom runner nightly\n", want: "custom runner nightly"},
+		{output: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := parseVersion(tt.output); got != tt.want {
+			t.Errorf("parseVersion(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestShellsCommand(t *testing.T) {
+	cmd := NewShellsCommand()
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
+	"github.com/spf13/cobra"
+)
+
+type taskExecutor interface {
+	Execute(ctx context.Context, scriptPath string) error
+	ExecuteInline(ctx context.Context, scriptContent string) error
+}
+
+var newTaskExecutor = func(config executor.Config) (taskExecutor, error) {
+	return executor.New(config)
+}
+
+// loadProject loads project configuration starting from the current directory.
+var loadProject = func() (*project.Config, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return project.Load(cwd)
+}
+
+// NewRunCommand creates the run command that executes a task declared in
+// .azdexec.yaml or the exec: section of azure.yaml.
+func NewRunCommand() *cobra.Command {
+	var (
+		interactive         bool
+		stopOnKeyVaultError bool
+		timeout             time.Duration
+		gracePeriod         time.Duration
+		retries             int
+		retryDelay          time.Duration
+		retryOnExitCodes    []int
+		requireTrusted      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "run <task> [task-args...]",
+		Short: "Run a named task from the project configuration",
+		Long: `Run a task declared in .azdexec.yaml or in the exec: section of azure.yaml.
+
+Arguments after the task name are appended to the task's own args.
+
+Example .azdexec.yaml:
+	tasks:
+	  migrate:
+	    description: Apply database migrations
+	    script: ./scripts/migrate.sh
+	    args: ["--target", "latest"]
+	    env:
+	      DB_PASSWORD: "@Microsoft.KeyVault(VaultName=myvault;SecretName=db-password)"`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadProject()
+			if err != nil {
+				return err
+			}
+			task, err := cfg.Task(args[0])
+			if err != nil {
+				return err
+			}
+
+			workingDir := task.WorkingDir
+			if workingDir == "" && task.IsInline() {
+				// Inline tasks run from the project root so they behave the same from any subdirectory.
+				workingDir = cfg.Root
+			}
+
+			azdEnv, err := AzdEnvironmentScope(cmd.Context(), selectedEnvironment(cmd))
+			if err != nil {
+				return err
+			}
+
+			exec, err := newTaskExecutor(executor.Config{
+				Shell:               task.Shell,
+				Interactive:         interactive,
+				StopOnKeyVaultError: stopOnKeyVaultError,
+				Args:                append(append([]string{}, task.Args...), args[1:]...),
+				Timeout:             timeout,
+				GracePeriod:         gracePeriod,
+				Retries:             retries,
+				RetryDelay:          retryDelay,
+				RetryOnExitCodes:    retryOnExitCodes,
+				WorkingDir:          workingDir,
+				AzdEnvironment:      azdEnv,
+				EnvOverrides:        task.EnvOverrides(),
+				Trust:               TrustMode(requireTrusted),
+			})
+			if err != nil {
+				return fmt.Errorf("invalid configuration for task %q: %w", args[0], err)
+			}
+
+			if task.IsInline() {
+				return exec.ExecuteInline(cmd.Context(), task.Run)
+			}
+			return exec.Execute(cmd.Context(), task.Script)
+		},
+	}
+
+	// Task arguments may look like flags; pass them through untouched.
+	cmd.FParseErrWhitelist.UnknownFlags = true
+	cmd.Flags().SetInterspersed(false)
+
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Run the task in interactive mode")
+	cmd.Flags().BoolVar(&stopOnKeyVaultError, "stop-on-keyvault-error", false, "Fail-fast: stop execution when any Key Vault or other secret reference fails to resolve")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Maximum time the task may run (e.g. 30s, 10m). 0 means no timeout")
+	cmd.Flags().DurationVar(&gracePeriod, "grace-period", executor.DefaultGracePeriod, "Time to wait after interrupting a timed-out or cancelled task before killing it")
+	cmd.Flags().IntVar(&retries, "retries", 0, "Run the task up to this many more times when it fails with a non-zero exit code")
+	cmd.Flags().DurationVar(&retryDelay, "retry-delay", executor.DefaultRetryDelay, "Delay before the first retry; it doubles with every further retry, with random jitter")
+	cmd.Flags().IntSliceVar(&retryOnExitCodes, "retry-on-exit-codes", nil, "Only retry when the task exits with one of these codes, e.g. 1,75 (default any non-zero code)")
+	cmd.Flags().BoolVar(&requireTrusted, "require-trusted", false, "Fail instead of asking for confirmation when the task's script file is not trusted or has changed (for CI)")
+
+	return cmd
+}
+
+// taskInfo is the list output for a single task.
+type taskInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Script      string `json:"script,omitempty"`
+	Run         string `json:"run,omitempty"`
+	Shell       string `json:"shell,omitempty"`
+}
+
+// NewListCommand creates the list command that shows tasks declared in project configuration.
+func NewListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Short:        "List tasks declared in the project configuration",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadProject()
+			if err != nil {
+				return err
+			}
+
+			tasks := make([]taskInfo, 0, len(cfg.Tasks))
+			for _, name := range cfg.TaskNames() {
+				task := cfg.Tasks[name]
+				tasks = append(tasks, taskInfo{
+					Name:        name,
+					Description: task.Description,
+					Script:      task.Script,
+					Run:         task.Run,
+					Shell:       task.Shell,
+				})
+			}
+
+			return cliout.Print(tasks, func() {
+				if len(tasks) == 0 {
+					cliout.Info("No tasks defined in %s", cfg.Root)
+					return
+				}
+				rows := make([]cliout.TableRow, 0, len(tasks))
+				for _, t := range tasks {
+					rows = append(rows, cliout.TableRow{
+						"Task":        t.Name,
+						"Description": t.Description,
+						"Command":     taskCommandSummary(cfg.Root, t),
+					})
+				}
+				cliout.Table([]string{"Task", "Description", "Command"}, rows)
+			})
+		},
+	}
+}
+
+// taskCommandSummary returns a one-line summary of what a task runs.
+func taskCommandSummary(root string, t taskInfo) string {
+	if t.Script != "" {
+		if rel, ok := strings.CutPrefix(t.Script, root+string(os.PathSeparator)); ok {
+			return rel
+		}
+		return t.Script
+	}
+
+	firstLine, _, multiline := strings.Cut(strings.TrimSpace(t.Run), "\n")
+	if multiline {
+		return firstLine + " ..."
+	}
+	return firstLine
+}
@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
+)
+
+type fakeTaskExecutor struct {
+	config        executor.Config
+	executePath   string
+	inlineContent string
+}
+
+func (f *fakeTaskExecutor) Execute(_ context.Context, scriptPath string) error {
+	f.executePath = scriptPath
+	return nil
+}
+
+func (f *fakeTaskExecutor) ExecuteInline(_ context.Context, scriptContent string) error {
+	f.inlineContent = scriptContent
+	return nil
+}
+
+func stubTasks(t *testing.T, cfg *project.Config) *fakeTaskExecutor {
+	t.Helper()
+
+	oldLoad, oldNew := loadProject, newTaskExecutor
+	t.Cleanup(func() { loadProject, newTaskExecutor = oldLoad, oldNew })
+
+	fake := &fakeTaskExecutor{}
+	loadProject = func() (*project.Config, error) { return cfg, nil }
+	newTaskExecutor = func(config executor.Config) (taskExecutor, error) {
+		fake.config = config
+		return fake, nil
+	}
+	return fake
+}
+
+func TestRunCommand_ScriptTask(t *testing.T) {
+	root := t.TempDir()
+	fake := stubTasks(t, &project.Config{
+		Root: root,
+		Tasks: map[string]project.Task{
+			"migrate": {
+				Script: filepath.Join(root, "migrate.sh"),
+				Shell:  "bash",
+				Args:   []string{"--target", "latest"},
+				Env:    map[string]string{"LOG_LEVEL": "debug"},
+			},
+		},
+	})
+
+	cmd := NewRunCommand()
+	cmd.SetArgs([]string{"migrate", "--dry-run"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if fake.executePath != filepath.Join(root, "migrate.sh") {
+		t.Errorf("Execute called with %q", fake.executePath)
+	}
+	if fake.config.Shell != "bash" {
+		t.Errorf("Shell = %q, want bash", fake.config.Shell)
+	}
+	if want := []string{"--target", "latest", "--dry-run"}; !reflect.DeepEqual(fake.config.Args, want) {
+		t.Errorf("Args = %v, want %v", fake.config.Args, want)
+	}
+	if want := []string{"LOG_LEVEL=debug"}; !reflect.DeepEqual(fake.config.EnvOverrides, want) {
+		t.Errorf("EnvOverrides = %v, want %v", fake.config.EnvOverrides, want)
+	}
+	if fake.config.WorkingDir != "" {
+		t.Errorf("WorkingDir = %q, want script default", fake.config.WorkingDir)
+	}
+}
+
+func TestRunCommand_InlineTaskRunsFromProjectRoot(t *testing.T) {
+	root := t.TempDir()
+	fake := stubTasks(t, &project.Config{
+		Root:  root,
+		Tasks: map[string]project.Task{"hello": {Run: "echo hello"}},
+	})
+
+	cmd := NewRunCommand()
+	cmd.SetArgs([]string{"hello"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if fake.inlineContent != "echo hello" {
+		t.Errorf("ExecuteInline called with %q", fake.inlineContent)
+	}
+	if fake.config.WorkingDir != root {
+		t.Errorf("WorkingDir = %q, want %q", fake.config.WorkingDir, root)
+	}
+}
+
+func TestRunCommand_UnknownTask(t *testing.T) {
+	stubTasks(t, &project.Config{Tasks: map[string]project.Task{}})
+
+	cmd := NewRunCommand()
+	cmd.SetArgs([]string{"missing"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for unknown task")
+	}
+}
+
+func TestRunCommand_LoadError(t *testing.T) {
+	oldLoad := loadProject
+	t.Cleanup(func() { loadProject = oldLoad })
+	loadProject = func() (*project.Config, error) { return nil, project.ErrNoProject }
+
+	cmd := NewRunCommand()
+	cmd.SetArgs([]string{"migrate"})
+	if err := cmd.Execute(); !errors.Is(err, project.ErrNoProject) {
+		t.Fatalf("expected ErrNoProject, got %v", err)
+	}
+}
+
+func TestListCommand(t *testing.T) {
+	stubTasks(t, &project.Config{
+		Root: t.TempDir(),
+		Tasks: map[string]project.Task{
+			"seed":    {Run: "echo seed\necho done", Description: "Seed data"},
+			"migrate": {Script: "migrate.sh"},
+		},
+	})
+
+	cmd := NewListCommand()
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+}
+
+func TestTaskCommandSummary(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "proj")
+
+	tests := []struct {
+		name string
+		task taskInfo
+		want string
+	}{
+		{name: "script under root", task: taskInfo{Script: filepath.Join(root, "scripts", "a.sh")}, want: filepath.Join("scripts", "a.sh")},
+		{name: "single line inline", task: taskInfo{Run: "echo hi"}, want: "echo hi"},
+		{name: "multi line inline", task: taskInfo{Run: "echo one\necho two\n"}, want: "echo one ..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskCommandSummary(root, tt.task); got != tt.want {
+				t.Errorf("taskCommandSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/trust"
+	"github.com/spf13/cobra"
+)
+
+// TrustMode returns the trust mode for a --require-trusted flag: script files
+// that are not trusted fail with it, and without it need confirmation, or run
+// with a warning when nobody can confirm them.
+func TrustMode(require bool) executor.TrustMode {
+	if require {
+		return executor.TrustRequire
+	}
+	return executor.TrustPrompt
+}
+
+// NewTrustCommand creates the trust command that manages the script files azd
+// exec runs without asking for confirmation.
+func NewTrustCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trust",
+		Short: "Manage the script files trusted to run without confirmation",
+		Long: `Manage the trust store: script files azd exec runs without asking, keyed by
+absolute path and SHA-256. A script that is not trusted, or has changed since it
+was trusted, is shown and must be confirmed before it runs. With AZD_NO_PROMPT
+or without a terminal it runs with a warning; with --require-trusted it fails.
+
+The store is kept in the user's configuration directory, or in the file named by
+` + trust.EnvVarTrustFile + `.`,
+	}
+	cmd.AddCommand(newTrustAddCommand(), newTrustListCommand(), newTrustRemoveCommand())
+	return cmd
+}
+
+func newTrustAddCommand() *cobra.Command {
+	var sha string
+
+	cmd := &cobra.Command{
+		Use:   "add <script>...",
+		Short: "Trust script files with their current content",
+		Long: `Trust script files with their current content. Review them first: they run
+without confirmation until their content changes.
+
+With --sha256, a single script is trusted only while it has that hash, without
+reading it. CI can pin a reviewed script this way and run it with --require-trusted.`,
+		Example: `  azd exec trust add ./scripts/deploy.sh
+  azd exec trust add ./scripts/deploy.sh --sha256 "$(sha256sum scripts/deploy.sh | cut -d' ' -f1)"`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if sha != "" && len(args) > 1 {
+				return fmt.Errorf("--sha256 pins a single script")
+			}
+			store, err := trust.Open()
+			if err != nil {
+				return err
+			}
+
+			entries := make([]trust.Entry, 0, len(args))
+			for _, path := range args {
+				var entry trust.Entry
+				if sha != "" {
+					entry, err = store.Pin(path, sha)
+				} else {
+					entry, err = trustFile(store, path)
+				}
+				if err != nil {
+					return err
+				}
+				entries = append(entries, entry)
+			}
+
+			return cliout.Print(entries, func() {
+				for _, entry := range entries {
+					cliout.Success("Trusted %s (sha256 %s)", entry.Path, entry.SHA256)
+				}
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&sha, "sha256", "", "Trust the script only while its content has this SHA-256, without reading it")
+
+	return cmd
+}
+
+func newTrustListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Short:        "List trusted script files",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := trust.Open()
+			if err != nil {
+				return err
+			}
+			entries := store.List()
+
+			return cliout.Print(entries, func() {
+				if len(entries) == 0 {
+					cliout.Info("No trusted scripts in %s", store.Path())
+					return
+				}
+				rows := make([]cliout.TableRow, 0, len(entries))
+				for _, entry := range entries {
+					status := "trusted"
+					if content, err := os.ReadFile(entry.Path); err != nil { // #nosec G304 -- path of a trusted script
+						status = "missing"
+					} else if s, _ := store.Check(entry.Path, content); s != trust.Trusted {
+						status = "changed"
+					}
+					rows = append(rows, cliout.TableRow{
+						"Path":    entry.Path,
+						"SHA-256": entry.SHA256[:12],
+						"Trusted": entry.TrustedAt.Local().Format("2006-01-02 15:04"),
+						"Status":  status,
+					})
+				}
+				cliout.Table([]string{"Path", "SHA-256", "Trusted", "Status"}, rows)
+			})
+		},
+	}
+}
+
+func newTrustRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "remove <script>...",
+		Short:        "Stop trusting script files",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := trust.Open()
+			if err != nil {
+				return err
+			}
+
+			removed := []string{}
+			var notTrusted []string
+			for _, path := range args {
+				ok, err := store.Remove(path)
+				if err != nil {
+					return err
+				}
+				if ok {
+					removed = append(removed, path)
+				} else {
+					notTrusted = append(notTrusted, path)
+				}
+			}
+
+			if err := cliout.Print(removed, func() {
+				for _, path := range removed {
+					cliout.Success("No longer trusting %s", path)
+				}
+			}); err != nil {
+				return err
+			}
+			if len(notTrusted) > 0 {
+				return fmt.Errorf("not trusted: %s", strings.Join(notTrusted, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// trustFile trusts the script file at path with its current content.
+func trustFile(store *trust.Store, path string) (trust.Entry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return trust.Entry{}, fmt.Errorf("cannot trust %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return trust.Entry{}, fmt.Errorf("cannot trust %s: it is a directory", path)
+	}
+	content, err := os.ReadFile(path) // #nosec G304 -- the user names the script to trust
+	if err != nil {
+		return trust.Entry{}, fmt.Errorf("cannot trust %s: %w", path, err)
+	}
+	return store.Trust(path, content)
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jongio/azd-exec/cli/src/internal/trust"
+)
+
+func TestTrustCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(trust.EnvVarTrustFile, filepath.Join(dir, "trust.json"))
+	script := filepath.Join(dir, "deploy.sh")
+	content := []byte("echo deploy\n")
+	if err := os.WriteFile(script, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) error {
+		cmd := NewTrustCommand()
+		cmd.SetArgs(args)
+		return cmd.Execute()
+	}
+
+	if err := run("add", script); err != nil {
+		t.Fatalf("trust add failed: %v", err)
+	}
+	if err := run("add", filepath.Join(dir, "ci.sh"), "--sha256", strings.Repeat("ab", 32)); err != nil {
+		t.Fatalf("trust add --sha256 failed: %v", err)
+	}
+	if err := run("list"); err != nil {
+		t.Fatalf("trust list failed: %v", err)
+	}
+
+	store, err := trust.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := store.Check(script, content); status != trust.Trusted {
+		t.Errorf("expected %s to be trusted, got %v", script, status)
+	}
+	if entries := store.List(); len(entries) != 2 {
+		t.Fatalf("expected 2 trusted scripts, got %+v", entries)
+	}
+
+	if err := run("remove", script, filepath.Join(dir, "other.sh")); err == nil || !strings.Contains(err.Error(), "other.sh") {
+		t.Errorf("trust remove error = %v, want one naming other.sh", err)
+	}
+	store, err = trust.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := store.List(); len(entries) != 1 {
+		t.Errorf("expected only the pinned script to stay trusted, got %+v", entries)
+	}
+}
+
+func TestTrustAddCommand_Errors(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(trust.EnvVarTrustFile, filepath.Join(dir, "trust.json"))
+
+	for _, args := range [][]string{
+		{"add", filepath.Join(dir, "missing.sh")},
+		{"add", dir},
+		{"add", "a.sh", "b.sh", "--sha256", strings.Repeat("ab", 32)},
+		{"add", "a.sh", "--sha256", "not-a-hash"},
+	} {
+		cmd := NewTrustCommand()
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("trust %v succeeded, want an error", args)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jongio/azd-core/env"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
+)
+
+// getEnvironmentValues reads an azd environment's values without touching the process environment.
+var getEnvironmentValues = env.GetAzdEnvironmentValues
+
+// listEnvironments returns the azd environments of the project containing the current directory.
+var listEnvironments = func() ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	root, err := project.FindRoot(cwd)
+	if err != nil {
+		return nil, err
+	}
+	return project.Environments(root)
+}
+
+// runEnvironments runs the script once per selected azd environment and prints a summary.
+// Each run starts from the inherited environment with that environment's values applied,
+// so runs never see each other's values, and --env-file, --env and --unset still apply
+// on top as they do with -e. The returned error wraps the first failure.
+func runEnvironments(ctx context.Context, scriptInput string, scriptArgs []string) error {
+	if scriptInput == stdinScriptArg {
+		return fmt.Errorf("reading the script from stdin cannot be combined with --environments or --all-environments")
+	}
+	if interactive && parallel > 1 {
+		return fmt.Errorf("--interactive cannot be combined with --parallel")
+	}
+
+	names, err := selectedEnvironments()
+	if err != nil {
+		return err
+	}
+	inherited, err := inheritedEnvironment(ctx)
+	if err != nil {
+		return err
+	}
+
+	var outputMu sync.Mutex // keeps prefixed lines from different environments intact
+	results := executor.ForEach(ctx, len(names), executor.EachOptions{
+		Parallel:        parallel,
+		ContinueOnError: continueOnError,
+	}, func(ctx context.Context, i int) error {
+		name := names[i]
+		values, err := getEnvironmentValues(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to load environment '%s': %w", name, err)
+		}
+
+		config := baseConfig()
+		config.Args = scriptArgs
+		config.AzdEnvironment = executor.AzdEnvironmentEnv(name, values)
+		config.Environ = executor.MergeEnv(inherited, config.AzdEnvironment)
+		if parallel > 1 {
+			stdout := executor.NewPrefixWriter(os.Stdout, name, &outputMu)
+			stderr := executor.NewPrefixWriter(os.Stderr, name, &outputMu)
+			defer func() {
+				_ = stdout.Flush()
+				_ = stderr.Flush()
+			}()
+			config.Stdout, config.Stderr = stdout, stderr
+		}
+
+		exec, err := newScriptExecutor(config)
+		if err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+		err = dispatchScript(ctx, exec, scriptInput, nil)
+		recordRun(config, scriptInput, name, exec.LastResult())
+		return err
+	})
+
+	firstFailed, failed := -1, 0
+	for i := range results {
+		results[i].Name = names[i]
+		if results[i].Err != nil {
+			if firstFailed < 0 {
+				firstFailed = i
+			}
+			failed++
+		}
+	}
+
+	if err := printSummary(summaryEnvironmentColumn, results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d environments failed; first failure in %s: %w", failed, len(names), names[firstFailed], results[firstFailed].Err)
+	}
+	return nil
+}
+
+// inheritedEnvironment returns the part of the process environment that scripts
+// start from: all of it, or with --clean-env only the essential variables and
+// those matching --pass-env. Either way, the values azd injected from the
+// environment it started azd exec with are left out, so that runs against
+// another environment do not see keys that only exist in that one.
+func inheritedEnvironment(ctx context.Context) ([]string, error) {
+	environ, err := withoutStartupEnvironment(ctx, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	if !cleanEnv {
+		return environ, nil
+	}
+	return executor.CleanEnviron(environ, passEnv)
+}
+
+// withoutStartupEnvironment returns environ without AZURE_ENV_NAME and the
+// variables azd set from the environment it names. A variable whose value
+// differs from the environment's was not set by azd and is kept.
+func withoutStartupEnvironment(ctx context.Context, environ []string) ([]string, error) {
+	name := os.Getenv("AZURE_ENV_NAME")
+	if name == "" {
+		return environ, nil
+	}
+	values, err := getEnvironmentValues(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment '%s': %w", name, err)
+	}
+
+	kept := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		if injected, ok := values[key]; key == "AZURE_ENV_NAME" || (ok && injected == value) {
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	return kept, nil
+}
+
+// cleanEnvironment returns the starting environment of a --clean-env run: the
+// inherited essentials plus azdEnv, the values of the named azd environment, or
+// of the environment azd selected (AZURE_ENV_NAME) when name is empty.
+func cleanEnvironment(ctx context.Context, name string) (environ, azdEnv []string, err error) {
+	if name == "" {
+		name = os.Getenv("AZURE_ENV_NAME")
+	}
+	if name == "" {
+		return nil, nil, fmt.Errorf("--clean-env requires an azd environment; select one with --environment")
+	}
+
+	values, err := getEnvironmentValues(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load environment '%s': %w", name, err)
+	}
+	inherited, err := inheritedEnvironment(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	azdEnv = executor.AzdEnvironmentEnv(name, values)
+	return executor.MergeEnv(inherited, azdEnv), azdEnv, nil
+}
+
+// selectedEnvironments returns the environments named by --environments, or
+// every project environment with --all-environments.
+func selectedEnvironments() ([]string, error) {
+	if allEnvironments {
+		if len(environments) > 0 {
+			return nil, fmt.Errorf("--environments and --all-environments cannot be used together")
+		}
+		names, err := listEnvironments()
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no azd environments found; create one with 'azd env new'")
+		}
+		return names, nil
+	}
+
+	seen := make(map[string]bool, len(environments))
+	names := make([]string, 0, len(environments))
+	for _, name := range environments {
+		if name == "" {
+			return nil, fmt.Errorf("--environments contains an empty environment name")
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
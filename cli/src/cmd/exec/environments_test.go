@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+// envRecordingExecutor records the overrides it was created with and fails for selected environments.
+type envRecordingExecutor struct {
+	fakeExecutor
+	name     string
+	exitCode int
+}
+
+func (f *envRecordingExecutor) ExecuteInline(ctx context.Context, scriptContent string) error {
+	_ = f.fakeExecutor.ExecuteInline(ctx, scriptContent)
+	if f.exitCode != 0 {
+		return &executor.ExecutionError{ExitCode: f.exitCode, Shell: "bash", IsInline: true}
+	}
+	return nil
+}
+
+func stubEnvironments(t *testing.T, values map[string]map[string]string, exitCodes map[string]int) *[]executor.Config {
+	t.Helper()
+	oldNew, oldGet, oldList := newScriptExecutor, getEnvironmentValues, listEnvironments
+	t.Cleanup(func() {
+		newScriptExecutor, getEnvironmentValues, listEnvironments = oldNew, oldGet, oldList
+	})
+
+	getEnvironmentValues = func(_ context.Context, name string) (map[string]string, error) {
+		v, ok := values[name]
+		if !ok {
+			return nil, errors.New("environment not found")
+		}
+		return v, nil
+	}
+	listEnvironments = func() ([]string, error) {
+		names := make([]string, 0, len(values))
+		for _, name := range []string{"dev", "test", "staging"} {
+			if _, ok := values[name]; ok {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+
+	var mu sync.Mutex
+	configs := &[]executor.Config{}
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*configs = append(*configs, cfg)
+		name := ""
+		for _, kv := range cfg.Environ {
+			if v, ok := strings.CutPrefix(kv, "AZURE_ENV_NAME="); ok {
+				name = v
+			}
+		}
+		return &envRecordingExecutor{name: name, exitCode: exitCodes[name]}, nil
+	}
+	return configs
+}
+
+func TestRunE_EnvironmentsRunsOncePerEnvironment(t *testing.T) {
+	configs := stubEnvironments(t, map[string]map[string]string{
+		"dev":  {"API_URL": "https://dev"},
+		"test": {"API_URL": "https://test", "AZURE_ENV_NAME": "ignored"},
+	}, nil)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--environments", "dev,test", "echo $API_URL", "arg1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if len(*configs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(*configs))
+	}
+	wantDev := []string{"API_URL=https://dev", "AZURE_ENV_NAME=dev"}
+	if got := environmentValues((*configs)[0].Environ, "API_URL", "AZURE_ENV_NAME"); !reflect.DeepEqual(got, wantDev) {
+		t.Errorf("dev environment = %v, want %v", got, wantDev)
+	}
+	wantTest := []string{"API_URL=https://test", "AZURE_ENV_NAME=test"}
+	if got := environmentValues((*configs)[1].Environ, "API_URL", "AZURE_ENV_NAME"); !reflect.DeepEqual(got, wantTest) {
+		t.Errorf("test environment = %v, want %v", got, wantTest)
+	}
+	if got := (*configs)[0].Args; !reflect.DeepEqual(got, []string{"arg1"}) {
+		t.Errorf("expected script args [arg1], got %v", got)
+	}
+}
+
+func TestRunE_EnvironmentsOmitStartupEnvironment(t *testing.T) {
+	configs := stubEnvironments(t, map[string]map[string]string{
+		"dev":  {"API_URL": "https://dev", "DEV_ONLY": "dev-value"},
+		"test": {"API_URL": "https://test"},
+	}, nil)
+	// azd started azd exec with dev, whose values it injected into the process environment.
+	t.Setenv("AZURE_ENV_NAME", "dev")
+	t.Setenv("API_URL", "https://dev")
+	t.Setenv("DEV_ONLY", "dev-value")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--environments", "test,dev", "echo $DEV_ONLY"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if len(*configs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(*configs))
+	}
+	wantTest := []string{"API_URL=https://test", "AZURE_ENV_NAME=test"}
+	if got := environmentValues((*configs)[0].Environ, "API_URL", "AZURE_ENV_NAME", "DEV_ONLY"); !reflect.DeepEqual(got, wantTest) {
+		t.Errorf("test environment = %v, want %v without the startup environment's DEV_ONLY", got, wantTest)
+	}
+	wantDev := []string{"API_URL=https://dev", "AZURE_ENV_NAME=dev", "DEV_ONLY=dev-value"}
+	if got := environmentValues((*configs)[1].Environ, "API_URL", "AZURE_ENV_NAME", "DEV_ONLY"); !reflect.DeepEqual(got, wantDev) {
+		t.Errorf("dev environment = %v, want %v", got, wantDev)
+	}
+}
+
+func TestRunE_EnvironmentsStopsAfterFailure(t *testing.T) {
+	configs := stubEnvironments(t, map[string]map[string]string{
+		"dev": {}, "test": {}, "staging": {},
+	}, map[string]int{"test": 7})
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--all-environments", "./smoke"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 environments failed; first failure in test") {
+		t.Fatalf("expected environment failure summary error, got %v", err)
+	}
+	if got := exitCodeForError(err); got != 7 {
+		t.Errorf("exitCodeForError() = %d, want 7", got)
+	}
+	if len(*configs) != 2 {
+		t.Errorf("expected staging to be skipped after test failed, got %d runs", len(*configs))
+	}
+}
+
+func TestRunE_EnvironmentsContinueOnError(t *testing.T) {
+	configs := stubEnvironments(t, map[string]map[string]string{
+		"dev": {}, "test": {},
+	}, map[string]int{"dev": 2})
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--environments", "dev,missing,test", "--parallel", "2", "--continue-on-error", "./smoke"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "2 of 3 environments failed") {
+		t.Fatalf("expected two failures, got %v", err)
+	}
+	if len(*configs) != 2 {
+		t.Errorf("expected dev and test to run, got %d runs", len(*configs))
+	}
+	for _, cfg := range *configs {
+		if cfg.Stdout == nil || cfg.Stderr == nil {
+			t.Error("expected prefixed output writers for parallel runs")
+		}
+	}
+}
+
+func TestRunE_EnvironmentsRejectsInvalidCombinations(t *testing.T) {
+	stubEnvironments(t, map[string]map[string]string{"dev": {}}, nil)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "with each", args: []string{"--each", "--environments", "dev", "a.sh"}, want: "--each cannot be combined"},
+		{name: "both selectors", args: []string{"--all-environments", "--environments", "dev", "a.sh"}, want: "cannot be used together"},
+		{name: "stdin", args: []string{"--environments", "dev", "-"}, want: "stdin"},
+		{name: "interactive parallel", args: []string{"--environments", "dev", "-i", "--parallel", "2", "a.sh"}, want: "--interactive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// environmentValues returns the entries of environ for the given keys, in key order.
+func environmentValues(environ []string, keys ...string) []string {
+	var values []string
+	for _, key := range keys {
+		for _, kv := range environ {
+			if strings.HasPrefix(kv, key+"=") {
+				values = append(values, kv)
+			}
+		}
+	}
+	return values
+}
+
+func TestRunE_EnvironmentsKeepEnvFlags(t *testing.T) {
+	configs := stubEnvironments(t, map[string]map[string]string{"dev": {"API_URL": "https://dev"}}, nil)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--environments", "dev", "--env", "API_URL=https://local", "echo $API_URL"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	cfg := (*configs)[0]
+	if !reflect.DeepEqual(cfg.EnvOverrides, []string{"API_URL=https://local"}) {
+		t.Errorf("expected --env to be applied over the environment's values, got overrides %v", cfg.EnvOverrides)
+	}
+}
+
+func TestRunE_CleanEnv(t *testing.T) {
+	t.Setenv("AZD_EXEC_TEST_LEAK", "leaked")
+	t.Setenv("AZD_EXEC_TEST_PASS", "passed")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantPass bool
+	}{
+		{name: "single script", args: []string{"--clean-env", "-e", "dev", "echo hi"}},
+		{name: "pass-env", args: []string{"--clean-env", "--pass-env", "AZD_EXEC_TEST_P*", "-e", "dev", "echo hi"}, wantPass: true},
+		{name: "each", args: []string{"--clean-env", "-e", "dev", "--each", "echo a", "echo b"}},
+		{name: "environments", args: []string{"--clean-env", "--environments", "dev", "echo hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs := stubEnvironments(t, map[string]map[string]string{"dev": {"API_URL": "https://dev"}}, nil)
+
+			cmd := newRootCmd()
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+
+			environ := (*configs)[0].Environ
+			if got := environmentValues(environ, "API_URL", "AZURE_ENV_NAME"); !reflect.DeepEqual(got, []string{"API_URL=https://dev", "AZURE_ENV_NAME=dev"}) {
+				t.Errorf("expected the azd environment's values, got %v", got)
+			}
+			if len(environmentValues(environ, "PATH")) != 1 {
+				t.Error("expected PATH to be kept")
+			}
+			if len(environmentValues(environ, "AZD_EXEC_TEST_LEAK")) != 0 {
+				t.Error("expected other process variables to be dropped")
+			}
+			if got := len(environmentValues(environ, "AZD_EXEC_TEST_PASS")) == 1; got != tt.wantPass {
+				t.Errorf("AZD_EXEC_TEST_PASS kept = %v, want %v", got, tt.wantPass)
+			}
+		})
+	}
+}
+
+func TestRunE_CleanEnvRequiresEnvironment(t *testing.T) {
+	stubEnvironments(t, map[string]map[string]string{"dev": {}}, nil)
+	t.Setenv("AZURE_ENV_NAME", "")
+
+	for wantErr, args := range map[string][]string{
+		"requires an azd environment": {"--clean-env", "echo hi"},
+		"--pass-env requires":         {"--pass-env", "NODE_*", "echo hi"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Execute(%v): expected error containing %q, got %v", args, wantErr, err)
+		}
+	}
+}
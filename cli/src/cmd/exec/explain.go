@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+// shellSourceText describes each executor shell source in text output.
+var shellSourceText = map[string]string{
+	executor.ShellSourceFlag:      "set with --shell",
+	executor.ShellSourceExtension: "detected from the file extension",
+	executor.ShellSourceShebang:   "detected from the shebang line",
+	executor.ShellSourceDefault:   "default for this OS",
+}
+
+// explainScript prints how scriptInput would be run, as text or, with --output json,
+// as an executor.Plan. Nothing is run and Key Vault references are not resolved.
+func explainScript(exec scriptExecutor, scriptInput string) error {
+	if scriptInput == stdinScriptArg {
+		return fmt.Errorf("--dry-run cannot be used when the script is read from stdin")
+	}
+
+	var plan *executor.Plan
+	var err error
+	if absPath, absErr := filepath.Abs(scriptInput); absErr == nil {
+		if _, statErr := os.Stat(absPath); statErr == nil {
+			plan, err = exec.ExplainFile(absPath)
+		}
+	}
+	if plan == nil && err == nil {
+		plan, err = exec.ExplainInline(scriptInput)
+	}
+	if err != nil {
+		return err
+	}
+
+	return cliout.Print(plan, func() {
+		cliout.Label("Shell", fmt.Sprintf("%s (%s)", plan.Shell, shellSourceText[plan.ShellSource]))
+		cliout.Label("Command", formatCommand(plan.Command))
+		cliout.Label("Working dir", plan.WorkingDir)
+		if len(plan.KeyVaultReferences) == 0 {
+			cliout.Label("Key Vault", "no references")
+			return
+		}
+		cliout.Newline()
+		cliout.Plain("Key Vault references (resolved before the script starts):")
+		rows := make([]cliout.TableRow, 0, len(plan.KeyVaultReferences))
+		for _, ref := range plan.KeyVaultReferences {
+			rows = append(rows, cliout.TableRow{"Variable": ref.Name, "Vault": ref.Vault, "Secret": ref.Secret})
+		}
+		cliout.Table([]string{"Variable", "Vault", "Secret"}, rows)
+	})
+}
+
+// formatCommand joins a command line for display, quoting arguments that are
+// empty or contain whitespace or quotes.
+func formatCommand(args []string) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
@@ -5,15 +5,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/azdext"
 	"github.com/jongio/azd-core/cliout"
 	"github.com/jongio/azd-core/env"
 	"github.com/jongio/azd-exec/cli/src/cmd/exec/commands"
 	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/history"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
 	"github.com/jongio/azd-exec/cli/src/internal/skills"
 	"github.com/jongio/azd-exec/cli/src/internal/version"
 	"github.com/spf13/cobra"
@@ -26,11 +34,77 @@ var (
 
 	// Key Vault resolution behavior flags.
 	stopOnKeyVaultError bool
+	noCache             bool
+	cacheTTL            time.Duration
+
+	// Execution time limits.
+	timeout     time.Duration
+	gracePeriod time.Duration
+
+	// Retry policy for failed scripts.
+	retries          int
+	retryDelay       time.Duration
+	retryOnExitCodes []int
+
+	// captureOutput includes script output in the JSON result instead of streaming it.
+	captureOutput bool
+
+	// Output log files.
+	logFile    string
+	stdoutFile string
+	stderrFile string
+	tee        bool
+	timestamps bool
+
+	// noMask disables masking of resolved Key Vault secrets in script output.
+	noMask bool
+
+	// requireTrusted fails instead of prompting for script files that are not trusted.
+	requireTrusted bool
+
+	// recordArgs keeps the script's arguments in the execution history.
+	recordArgs bool
+
+	// dryRun shows how the script would run instead of running it.
+	dryRun bool
+
+	// Environment overrides, applied before Key Vault resolution.
+	envOverrides []string
+	envFiles     []string
+	unsetVars    []string
+
+	// workDir overrides the directory scripts run in.
+	workDir string
+
+	// cleanEnv starts scripts from the azd environment and a few essentials
+	// instead of the full process environment.
+	cleanEnv bool
+	passEnv  []string
+
+	// Multi-script and multi-environment execution flags.
+	each            bool
+	environments    []string
+	allEnvironments bool
+	parallel        int
+	continueOnError bool
+
+	// Watch mode flags.
+	watch      bool
+	watchGlobs []string
 )
 
+// stdinScriptArg is the script argument that reads the script body from stdin.
+const stdinScriptArg = "-"
+
 type scriptExecutor interface {
 	Execute(ctx context.Context, scriptPath string) error
 	ExecuteInline(ctx context.Context, scriptContent string) error
+	ExecuteReader(ctx context.Context, r io.Reader) error
+	ExecuteEach(ctx context.Context, scripts []string, opts executor.EachOptions) ([]executor.ScriptResult, error)
+	Watch(ctx context.Context, script string, opts executor.WatchOptions) error
+	ExplainFile(scriptPath string) (*executor.Plan, error)
+	ExplainInline(scriptContent string) (*executor.Plan, error)
+	LastResult() *executor.Result
 }
 
 var newScriptExecutor = func(config executor.Config) (scriptExecutor, error) {
@@ -38,11 +112,54 @@ var newScriptExecutor = func(config executor.Config) (scriptExecutor, error) {
 }
 
 func main() {
+	// Cancel the command context on Ctrl+C or SIGTERM so running scripts are
+	// interrupted gracefully instead of being orphaned.
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	var received atomic.Value
+	go func() {
+		if sig, ok := <-signals; ok {
+			received.Store(sig)
+			cancel()
+		}
+	}()
+
 	rootCmd := newRootCmd()
-	if err := rootCmd.Execute(); err != nil {
-		cliout.Error("%v", err)
-		os.Exit(1)
+	err := rootCmd.ExecuteContext(ctx)
+	signal.Stop(signals)
+	cancel()
+	if err != nil {
+		if cliout.IsJSON() {
+			// Keep stdout reserved for the JSON result.
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		} else {
+			cliout.Error("%v", err)
+		}
+		sig, _ := received.Load().(os.Signal)
+		os.Exit(exitCodeForInterrupt(err, sig))
+	}
+}
+
+// exitCodeForError returns the process exit code for an error returned by the root command.
+// Script failures propagate the script's own exit code (128+N when it was killed
+// by signal N) so callers can branch on it; any other failure exits with 1.
+func exitCodeForError(err error) int {
+	var execErr *executor.ExecutionError
+	if errors.As(err, &execErr) && execErr.ExitCode > 0 {
+		return execErr.ExitCode
+	}
+	return 1
+}
+
+// exitCodeForInterrupt returns the process exit code like exitCodeForError, except
+// that a run cancelled because azd exec received signal sig exits with 128+N, as
+// shells report a process ended by signal N (130 for Ctrl+C, 143 for SIGTERM).
+func exitCodeForInterrupt(err error, sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok && errors.Is(err, context.Canceled) {
+		return 128 + int(s)
 	}
+	return exitCodeForError(err)
 }
 
 func newRootCmd() *cobra.Command {
@@ -59,11 +176,58 @@ Examples:
 \tazd exec --shell pwsh "Write-Host 'Hello'"   # Inline PowerShell
 \tazd exec --shell pwsh ./deploy.ps1            # Script with shell
 \tazd exec ./build.sh -- --verbose              # Script with args
-\tazd exec ./init.sh -i                         # Interactive mode`,
+\tazd exec ./init.sh -i                         # Interactive mode
+\tazd exec --each --parallel 2 a.sh b.sh c.sh   # Several scripts, two at a time
+\tazd exec --environments dev,test ./smoke.sh   # Once per azd environment
+\tazd exec --log-file deploy.log --tee ./deploy.sh  # Keep an audit log
+\tazd exec --dry-run ./deploy.sh                # Show what would run
+\tazd exec --env-file .env.local --env DEBUG=1 ./run.sh  # Extra variables
+\tazd exec --clean-env -e dev ./deploy.sh        # Only azd values and essentials
+\tazd exec --workdir project ./scripts/migrate.sh  # Run from the project root`,
 	})
 
 	rootCmd.Args = cobra.MinimumNArgs(1)
 	rootCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		multiEnvironment := len(environments) > 0 || allEnvironments
+		if !each && !multiEnvironment && (cmd.Flags().Changed("parallel") || continueOnError) {
+			return fmt.Errorf("--parallel and --continue-on-error require --each or --environments")
+		}
+		if captureOutput && (!cliout.IsJSON() || each || multiEnvironment) {
+			return fmt.Errorf("--capture-output requires --output json and a single script")
+		}
+		if each && multiEnvironment {
+			return fmt.Errorf("--each cannot be combined with --environments or --all-environments")
+		}
+		if dryRun && (each || multiEnvironment) {
+			return fmt.Errorf("--dry-run cannot be combined with --each, --environments or --all-environments")
+		}
+		if len(passEnv) > 0 && !cleanEnv {
+			return fmt.Errorf("--pass-env requires --clean-env")
+		}
+		watching := watch || len(watchGlobs) > 0
+		if watching && (each || multiEnvironment || dryRun || captureOutput || cliout.IsJSON() || args[0] == stdinScriptArg) {
+			return fmt.Errorf("--watch cannot be combined with --each, --environments, --dry-run, --output json or a script read from stdin")
+		}
+
+		// Scripts inherit the process environment unless --clean-env replaces it.
+		// Multi-environment runs read each environment's values themselves.
+		config := baseConfig()
+		if !multiEnvironment {
+			var err error
+			if cleanEnv {
+				config.Environ, config.AzdEnvironment, err = cleanEnvironment(cmd.Context(), extCtx.Environment)
+			} else {
+				config.AzdEnvironment, err = commands.AzdEnvironmentScope(cmd.Context(), extCtx.Environment)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if each {
+			return runEach(cmd.Context(), config, args, historyEnvironment(extCtx.Environment))
+		}
+
 		// Parse script arguments - everything after the script path
 		scriptArgs := []string{}
 		scriptInput := args[0]
@@ -74,29 +238,33 @@ Examples:
 			scriptArgs = args[1:]
 		}
 
+		if multiEnvironment {
+			return runEnvironments(cmd.Context(), scriptInput, scriptArgs)
+		}
+
 		// Create executor
-		exec, err := newScriptExecutor(executor.Config{
-			Shell:               shell,
-			Interactive:         interactive,
-			StopOnKeyVaultError: stopOnKeyVaultError,
-			Args:                scriptArgs,
-		})
+		config.Args = scriptArgs
+		exec, err := newScriptExecutor(config)
 		if err != nil {
 			return fmt.Errorf("invalid configuration: %w", err)
 		}
+		if dryRun {
+			return explainScript(exec, scriptInput)
+		}
+		if watching {
+			return exec.Watch(cmd.Context(), scriptInput, executor.WatchOptions{Patterns: watchGlobs})
+		}
 
-		// Check if input is a file or inline script
-		// Try to resolve as file path first
-		absPath, err := filepath.Abs(scriptInput)
-		if err == nil {
-			if _, statErr := os.Stat(absPath); statErr == nil {
-				// It's a file that exists, execute as file
-				return exec.Execute(cmd.Context(), absPath)
+		runErr := dispatchScript(cmd.Context(), exec, scriptInput, cmd.InOrStdin())
+		recordRun(config, scriptInput, historyEnvironment(extCtx.Environment), exec.LastResult())
+		if cliout.IsJSON() {
+			if result := exec.LastResult(); result != nil {
+				if err := cliout.PrintJSON(result); err != nil {
+					return err
+				}
 			}
 		}
-
-		// Not a file, treat as inline script
-		return exec.ExecuteInline(cmd.Context(), scriptInput)
+		return runErr
 	}
 
 	// Save the SDK's PersistentPreRunE so we can chain it
@@ -127,14 +295,26 @@ Examples:
 			}
 		}
 
-		// Handle environment selection
-		if extCtx.Environment != "" {
+		// Handle environment selection. Multi-environment runs load each
+		// environment into its own run instead of the process environment.
+		// --clean-env reads the environment's values itself instead.
+		if extCtx.Environment != "" && len(environments) == 0 && !allEnvironments && !cleanEnv {
 			// Load environment variables from the specified environment
 			if err := env.LoadAzdEnvironment(cmd.Context(), extCtx.Environment); err != nil {
 				return fmt.Errorf("failed to load environment '%s': %w", extCtx.Environment, err)
 			}
 		}
 
+		// Make interpreter profiles from the project configuration available to
+		// --shell, auto-detection, tasks and the MCP tools. Only commands that
+		// depend on the configuration fail when it is invalid.
+		if err := registerProjectInterpreters(); err != nil {
+			if needsProjectConfig(cmd) {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: ignoring the project configuration: %v\n", err)
+		}
+
 		// Install Copilot skill
 		if err := skills.InstallSkill(); err != nil {
 			if extCtx.Debug {
@@ -152,9 +332,40 @@ Examples:
 	rootCmd.PersistentFlags().SetInterspersed(false)
 
 	// Add flags for direct script execution (when using 'azd exec ./script.sh')
-	rootCmd.Flags().StringVarP(&shell, "shell", "s", "", "Shell to use for execution (bash, sh, zsh, pwsh, powershell, cmd). Auto-detected if not specified.")
+	rootCmd.Flags().StringVarP(&shell, "shell", "s", "", "Shell or interpreter to use for execution (bash, sh, zsh, pwsh, powershell, cmd, python3, node, ...). Auto-detected if not specified.")
 	rootCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Run script in interactive mode")
-	rootCmd.Flags().BoolVar(&stopOnKeyVaultError, "stop-on-keyvault-error", false, "Fail-fast: stop execution when any Key Vault reference fails to resolve")
+	rootCmd.Flags().BoolVar(&stopOnKeyVaultError, "stop-on-keyvault-error", false, "Fail-fast: stop execution when any Key Vault or other secret reference fails to resolve")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Fetch every Key Vault secret from Key Vault instead of reusing values cached by earlier runs")
+	rootCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 0, "Cache resolved Key Vault secrets for later runs for this long (off by default)")
+	rootCmd.Flags().DurationVar(&timeout, "timeout", 0, "Maximum time the script may run (e.g. 30s, 10m). 0 means no timeout")
+	rootCmd.Flags().DurationVar(&gracePeriod, "grace-period", executor.DefaultGracePeriod, "Time to wait after interrupting a timed-out or cancelled script before killing it")
+	rootCmd.Flags().IntVar(&retries, "retries", 0, "Run the script up to this many more times when it fails with a non-zero exit code")
+	rootCmd.Flags().DurationVar(&retryDelay, "retry-delay", executor.DefaultRetryDelay, "Delay before the first retry; it doubles with every further retry, with random jitter")
+	rootCmd.Flags().IntSliceVar(&retryOnExitCodes, "retry-on-exit-codes", nil, "Only retry when the script exits with one of these codes, e.g. 1,75 (default any non-zero code)")
+	rootCmd.Flags().BoolVar(&captureOutput, "capture-output", false, "With --output json, include the script's stdout and stderr in the result instead of streaming them")
+	rootCmd.Flags().StringVar(&logFile, "log-file", "", "Append the script's stdout and stderr to this file")
+	rootCmd.Flags().StringVar(&stdoutFile, "stdout-file", "", "Append the script's stdout to this file")
+	rootCmd.Flags().StringVar(&stderrFile, "stderr-file", "", "Append the script's stderr to this file")
+	rootCmd.Flags().BoolVar(&tee, "tee", false, "Keep showing output in the terminal while writing it to log files")
+	rootCmd.Flags().BoolVar(&timestamps, "timestamps", false, "Prefix every line written to log files with a timestamp")
+	rootCmd.Flags().StringVar(&workDir, "workdir", "", "Directory to run the script in: a path, 'project' for the azd project root, or 'script' for the default")
+	rootCmd.Flags().StringArrayVar(&envOverrides, "env", nil, "Set an environment variable for the script as KEY=VALUE; the value may be a Key Vault reference (repeatable)")
+	rootCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "Load environment variables from a dotenv file; later files win (repeatable)")
+	rootCmd.Flags().StringArrayVar(&unsetVars, "unset", nil, "Remove an environment variable before running the script (repeatable)")
+	rootCmd.Flags().BoolVar(&cleanEnv, "clean-env", false, "Start the script with only the azd environment's values and essential variables such as PATH and HOME")
+	rootCmd.Flags().StringSliceVar(&passEnv, "pass-env", nil, "With --clean-env, also pass through variables whose names match these patterns, e.g. NODE_*")
+	rootCmd.Flags().BoolVar(&noMask, "no-mask", false, "Show resolved Key Vault secret values in script output instead of masking them with ***")
+	rootCmd.Flags().BoolVar(&requireTrusted, "require-trusted", false, "Fail instead of asking for confirmation when the script file is not trusted or has changed (for CI)")
+	rootCmd.Flags().BoolVar(&recordArgs, "record-args", false, "Keep the script's arguments in the execution history so 'azd exec rerun' can repeat the run; they are otherwise recorded only by hash")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the shell, command line, working directory and Key Vault references without running the script or resolving secrets")
+	rootCmd.Flags().BoolVar(&dryRun, "explain", false, "Alias for --dry-run")
+	rootCmd.Flags().BoolVar(&each, "each", false, "Treat every argument as a separate script and run them all with one shared environment")
+	rootCmd.Flags().StringSliceVar(&environments, "environments", nil, "Run the script once in each of these azd environments (comma-separated)")
+	rootCmd.Flags().BoolVar(&allEnvironments, "all-environments", false, "Run the script once in every azd environment of the project")
+	rootCmd.Flags().IntVar(&parallel, "parallel", 1, "With --each or --environments, the maximum number of runs at the same time")
+	rootCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "With --each or --environments, keep going after a run fails")
+	rootCmd.Flags().BoolVar(&watch, "watch", false, "Rerun the script whenever the script file changes, stopping a run that is still going; Key Vault references are resolved once")
+	rootCmd.Flags().StringArrayVar(&watchGlobs, "watch-glob", nil, "Also rerun when files matching this glob change, e.g. 'src/**/*.sql'; implies --watch (repeatable)")
 
 	// Register subcommands
 	rootCmd.AddCommand(
@@ -162,7 +373,160 @@ Examples:
 		commands.NewListenCommand(),
 		commands.NewMetadataCommand(newRootCmd),
 		commands.NewMCPCommand(),
+		commands.NewRunCommand(),
+		commands.NewListCommand(),
+		commands.NewShellsCommand(),
+		commands.NewSecretsCommand(),
+		commands.NewHistoryCommand(),
+		commands.NewRerunCommand(),
+		commands.NewTrustCommand(),
 	)
 
 	return rootCmd
 }
+
+// registerProjectInterpreters registers the interpreter profiles declared in the
+// configuration of the project containing the current directory, if there is one.
+func registerProjectInterpreters() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	cfg, err := project.Load(cwd)
+	if errors.Is(err, project.ErrNoProject) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := executor.RegisterProjectInterpreters(cfg); err != nil {
+		return fmt.Errorf("invalid interpreter in project configuration: %w", err)
+	}
+	return nil
+}
+
+// needsProjectConfig reports whether cmd cannot work without the project
+// configuration: the task and interpreter commands, and running a script with a
+// --shell that only the configuration could define.
+func needsProjectConfig(cmd *cobra.Command) bool {
+	if !cmd.HasParent() {
+		return shell != "" && !executor.IsValidShell(shell)
+	}
+	if cmd.Parent().HasParent() {
+		// Nested commands such as `trust list` and `secrets list` do not use it.
+		return false
+	}
+	switch cmd.Name() {
+	case "run", "list", "shells":
+		return true
+	}
+	return false
+}
+
+// baseConfig returns the executor configuration shared by every run of this invocation.
+func baseConfig() executor.Config {
+	return executor.Config{
+		Shell:               shell,
+		Interactive:         interactive,
+		StopOnKeyVaultError: stopOnKeyVaultError,
+		NoCache:             noCache,
+		CacheTTL:            cacheTTL,
+		Timeout:             timeout,
+		GracePeriod:         gracePeriod,
+		Retries:             retries,
+		RetryDelay:          retryDelay,
+		RetryOnExitCodes:    retryOnExitCodes,
+		WorkingDir:          workDir,
+		CaptureOutput:       captureOutput,
+		LogFile:             logFile,
+		StdoutFile:          stdoutFile,
+		StderrFile:          stderrFile,
+		Tee:                 tee,
+		Timestamps:          timestamps,
+		NoMask:              noMask,
+		Trust:               commands.TrustMode(requireTrusted),
+		EnvFiles:            envFiles,
+		EnvOverrides:        envOverrides,
+		Unset:               unsetVars,
+	}
+}
+
+// recordHistory adds a run to the execution history of the project containing startDir.
+var recordHistory = history.RecordRun
+
+// recordRun records a finished run in the project's execution history. A history
+// that cannot be written is reported on stderr but never fails the run.
+func recordRun(config executor.Config, script, environment string, result *executor.Result) {
+	if result == nil {
+		return
+	}
+	rec := history.NewRecord(config, script, environment, result)
+	rec.Options.CleanEnv = cleanEnv
+	rec.Options.PassEnv = passEnv
+	if recordArgs {
+		rec.Args = config.Args
+	}
+	cwd, err := os.Getwd()
+	if err == nil {
+		_, err = recordHistory(cwd, rec)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record the run in the execution history: %v\n", err)
+	}
+}
+
+// historyEnvironment returns the name of the azd environment runs are recorded
+// against: the one selected with -e, or else the one azd exec was started with.
+func historyEnvironment(selected string) string {
+	if selected != "" {
+		return selected
+	}
+	return os.Getenv("AZURE_ENV_NAME")
+}
+
+// dispatchScript runs scriptInput as a script read from stdin ("-"), an existing file, or an inline script.
+func dispatchScript(ctx context.Context, exec scriptExecutor, scriptInput string, stdin io.Reader) error {
+	// "-" reads the script body from stdin
+	if scriptInput == stdinScriptArg {
+		return exec.ExecuteReader(ctx, stdin)
+	}
+
+	// Check if input is a file or inline script
+	// Try to resolve as file path first
+	absPath, err := filepath.Abs(scriptInput)
+	if err == nil {
+		if _, statErr := os.Stat(absPath); statErr == nil {
+			// It's a file that exists, execute as file
+			return exec.Execute(ctx, absPath)
+		}
+	}
+
+	// Not a file, treat as inline script
+	return exec.ExecuteInline(ctx, scriptInput)
+}
+
+// runEach runs every argument as its own script with config and prints a summary
+// of exit codes. The returned error wraps the first failure, so its exit code
+// becomes azd exec's.
+func runEach(ctx context.Context, config executor.Config, scripts []string, environment string) error {
+	exec, err := newScriptExecutor(config)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	results, runErr := exec.ExecuteEach(ctx, scripts, executor.EachOptions{
+		Parallel:        parallel,
+		ContinueOnError: continueOnError,
+	})
+	if len(results) == 0 {
+		return runErr
+	}
+	for _, r := range results {
+		recordRun(config, r.Script, environment, r.Result)
+	}
+
+	if err := printSummary(summaryScriptColumn, results); err != nil {
+		return err
+	}
+	return runErr
+}
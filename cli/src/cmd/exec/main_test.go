@@ -2,20 +2,39 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/jongio/azd-core/cliout"
 	"github.com/jongio/azd-core/env"
 	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/history"
+	"github.com/jongio/azd-exec/cli/src/internal/trust"
 )
 
 type fakeExecutor struct {
 	executePath   string
 	inlineContent string
+	stdinContent  string
 	args          []string
+	eachScripts   []string
+	eachOpts      executor.EachOptions
+	eachResults   []executor.ScriptResult
+	eachErr       error
+	result        *executor.Result
+	explained     string
+	plan          *executor.Plan
+	watched       string
+	watchOpts     executor.WatchOptions
 }
 
 func (f *fakeExecutor) Execute(_ context.Context, scriptPath string) error {
@@ -28,6 +47,38 @@ func (f *fakeExecutor) ExecuteInline(_ context.Context, scriptContent string) er
 	return nil
 }
 
+func (f *fakeExecutor) ExecuteReader(_ context.Context, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	f.stdinContent = string(content)
+	return err
+}
+
+func (f *fakeExecutor) ExecuteEach(_ context.Context, scripts []string, opts executor.EachOptions) ([]executor.ScriptResult, error) {
+	f.eachScripts = scripts
+	f.eachOpts = opts
+	return f.eachResults, f.eachErr
+}
+
+func (f *fakeExecutor) Watch(_ context.Context, script string, opts executor.WatchOptions) error {
+	f.watched = script
+	f.watchOpts = opts
+	return nil
+}
+
+func (f *fakeExecutor) ExplainFile(scriptPath string) (*executor.Plan, error) {
+	f.explained = scriptPath
+	return f.plan, nil
+}
+
+func (f *fakeExecutor) ExplainInline(scriptContent string) (*executor.Plan, error) {
+	f.explained = scriptContent
+	return f.plan, nil
+}
+
+func (f *fakeExecutor) LastResult() *executor.Result {
+	return f.result
+}
+
 func TestPersistentPreRunE_SetsEnvAndCwd(t *testing.T) {
 	oldWd, err := os.Getwd()
 	if err != nil {
@@ -145,6 +196,37 @@ func TestRunE_DispatchesFileOrInline(t *testing.T) {
 	})
 }
 
+func TestRunE_DashReadsScriptFromStdin(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	fake := &fakeExecutor{}
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		fake.args = append([]string{}, cfg.Args...)
+		return fake, nil
+	}
+
+	shell = ""
+	interactive = false
+
+	cmd := newRootCmd()
+	cmd.SetIn(strings.NewReader("echo from-stdin\n"))
+	cmd.SetArgs([]string{"-", "arg1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if fake.stdinContent != "echo from-stdin\n" {
+		t.Fatalf("expected ExecuteReader to receive stdin content, got %q", fake.stdinContent)
+	}
+	if fake.inlineContent != "" || fake.executePath != "" {
+		t.Fatalf("expected only ExecuteReader to be called, got inline=%q path=%q", fake.inlineContent, fake.executePath)
+	}
+	if !reflect.DeepEqual(fake.args, []string{"arg1"}) {
+		t.Fatalf("expected script args [arg1], got %v", fake.args)
+	}
+}
+
 func TestRunE_AllowsPassthroughArgsWithoutDoubleDash(t *testing.T) {
 	oldNew := newScriptExecutor
 	defer func() { newScriptExecutor = oldNew }()
@@ -229,3 +311,539 @@ func TestPersistentPreRunE_PropagatesNoPromptEnvVar(t *testing.T) {
 		t.Errorf("AZD_NO_PROMPT = %q, want %q", got, "true")
 	}
 }
+
+func TestExitCodeForError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "script exit code", err: &executor.ExecutionError{ExitCode: 2, Shell: "bash"}, want: 2},
+		{name: "wrapped script exit code", err: fmt.Errorf("run failed: %w", &executor.ExecutionError{ExitCode: 42}), want: 42},
+		{name: "signal exit code", err: &executor.ExecutionError{ExitCode: 130, Signal: 2}, want: 130},
+		{name: "unknown exit code", err: &executor.ExecutionError{ExitCode: -1}, want: 1},
+		{name: "timeout", err: &executor.TimeoutError{Shell: "bash"}, want: 1},
+		{name: "generic error", err: errors.New("invalid configuration"), want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeForError(tt.err); got != tt.want {
+				t.Errorf("exitCodeForError() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCodeForInterrupt(t *testing.T) {
+	cancelled := fmt.Errorf("script execution cancelled: %w", context.Canceled)
+	tests := []struct {
+		name string
+		err  error
+		sig  os.Signal
+		want int
+	}{
+		{name: "Ctrl+C", err: cancelled, sig: os.Interrupt, want: 130},
+		{name: "SIGTERM", err: cancelled, sig: syscall.SIGTERM, want: 143},
+		{name: "cancelled without a signal", err: cancelled, want: 1},
+		{name: "script exit code after a signal", err: &executor.ExecutionError{ExitCode: 2}, sig: os.Interrupt, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeForInterrupt(tt.err, tt.sig); got != tt.want {
+				t.Errorf("exitCodeForInterrupt() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunE_EachRunsEveryArgument(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	scriptErr := &executor.ExecutionError{ExitCode: 3, Shell: "bash"}
+	fake := &fakeExecutor{
+		eachResults: []executor.ScriptResult{
+			{Script: "a.sh", Name: "a.sh", Result: &executor.Result{Shell: "sh", WorkingDir: "/app"}},
+			{Script: "b.sh", Name: "b.sh", ExitCode: 3, Err: scriptErr, Result: &executor.Result{Shell: "bash", ExitCode: 3}},
+			{Script: "c.sh", Name: "c.sh", Skipped: true},
+		},
+		eachErr: &executor.BatchError{Failed: 1, Total: 3, Script: "b.sh", Err: scriptErr},
+	}
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		fake.args = append([]string{}, cfg.Args...)
+		return fake, nil
+	}
+	oldRecord := recordHistory
+	defer func() { recordHistory = oldRecord }()
+	var recorded []history.Record
+	recordHistory = func(_ string, rec history.Record) (history.Record, error) {
+		recorded = append(recorded, rec)
+		return rec, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--each", "--parallel", "2", "--continue-on-error", "a.sh", "b.sh", "c.sh"})
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("expected batch failure to be returned")
+	}
+	if got := exitCodeForError(err); got != 3 {
+		t.Errorf("exitCodeForError() = %d, want 3", got)
+	}
+
+	if !reflect.DeepEqual(fake.eachScripts, []string{"a.sh", "b.sh", "c.sh"}) {
+		t.Errorf("expected scripts [a.sh b.sh c.sh], got %v", fake.eachScripts)
+	}
+	if fake.eachOpts.Parallel != 2 || !fake.eachOpts.ContinueOnError {
+		t.Errorf("unexpected options: %+v", fake.eachOpts)
+	}
+	if len(fake.args) != 0 {
+		t.Errorf("expected no script args in --each mode, got %v", fake.args)
+	}
+	if len(recorded) != 2 || recorded[0].Shell != "sh" || recorded[0].WorkingDir != "/app" || recorded[1].Shell != "bash" || recorded[1].Status != history.StatusFailed {
+		t.Errorf("expected the two scripts that ran to be recorded with their results, got %+v", recorded)
+	}
+}
+
+func TestRunE_ParallelRequiresEach(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		t.Fatal("executor should not be created")
+		return nil, nil
+	}
+
+	for _, flag := range []string{"--parallel=2", "--continue-on-error"} {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{flag, "echo hi"})
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "require --each") {
+			t.Errorf("%s: expected --each requirement error, got %v", flag, err)
+		}
+	}
+}
+
+func TestRunE_JSONOutputPrintsResult(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+	defer func() { _ = cliout.SetFormat("default") }()
+
+	fake := &fakeExecutor{result: &executor.Result{Shell: "bash", Command: []string{"bash", "-c", "echo hi"}, ExitCode: 0}}
+	var gotConfig executor.Config
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		gotConfig = cfg
+		return fake, nil
+	}
+
+	stdout := captureStdout(t, func() {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"--output", "json", "--capture-output", "echo hi"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	})
+
+	var result executor.Result
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("expected JSON result on stdout, got %q: %v", stdout, err)
+	}
+	if result.Shell != "bash" || len(result.Command) != 3 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !gotConfig.CaptureOutput {
+		t.Error("expected --capture-output to be passed to the executor")
+	}
+}
+
+func TestRunE_CaptureOutputRequiresJSON(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--capture-output", "echo hi"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--output json") {
+		t.Fatalf("expected --output json requirement error, got %v", err)
+	}
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe failed: %v", err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	fn()
+	_ = w.Close()
+	return <-done
+}
+
+func TestRunE_LogFileFlags(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	var got executor.Config
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		got = cfg
+		return &fakeExecutor{}, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--log-file", "run.log", "--stdout-file", "out.log", "--stderr-file", "err.log", "--tee", "--timestamps", "echo hi"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if got.LogFile != "run.log" || got.StdoutFile != "out.log" || got.StderrFile != "err.log" || !got.Tee || !got.Timestamps {
+		t.Errorf("log flags not passed to executor config: %+v", got)
+	}
+}
+
+func TestRunE_NoMaskFlag(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	var got executor.Config
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		got = cfg
+		return &fakeExecutor{}, nil
+	}
+
+	for _, args := range [][]string{{"echo hi"}, {"--no-mask", "echo hi"}} {
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute(%v) failed: %v", args, err)
+		}
+		if want := len(args) == 2; got.NoMask != want {
+			t.Errorf("Execute(%v): NoMask = %v, want %v", args, got.NoMask, want)
+		}
+	}
+}
+
+func TestRunE_DryRunExplainsWithoutRunning(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+	defer func() { _ = cliout.SetFormat("default") }()
+
+	fake := &fakeExecutor{plan: &executor.Plan{
+		Shell:              "bash",
+		ShellSource:        executor.ShellSourceDefault,
+		Command:            []string{"bash", "-c", "echo hi"},
+		WorkingDir:         "/work",
+		KeyVaultReferences: []executor.KeyVaultReference{{Name: "DB_PASSWORD", Vault: "prod-kv", Secret: "db-password"}},
+	}}
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		return fake, nil
+	}
+
+	stdout := captureStdout(t, func() {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"--output", "json", "--dry-run", "echo hi"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	})
+
+	if fake.inlineContent != "" || fake.explained != "echo hi" {
+		t.Fatalf("expected the script to be explained and not run, got inline=%q explained=%q", fake.inlineContent, fake.explained)
+	}
+	var plan executor.Plan
+	if err := json.Unmarshal([]byte(stdout), &plan); err != nil {
+		t.Fatalf("expected JSON plan on stdout, got %q: %v", stdout, err)
+	}
+	if !reflect.DeepEqual(&plan, fake.plan) {
+		t.Errorf("plan = %+v, want %+v", plan, fake.plan)
+	}
+}
+
+func TestRunE_DryRunRejectsMultipleRuns(t *testing.T) {
+	for _, args := range [][]string{
+		{"--dry-run", "--each", "a.sh", "b.sh"},
+		{"--explain", "--environments", "dev,test", "echo hi"},
+		{"--dry-run", "-"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--dry-run") {
+			t.Errorf("Execute(%v): expected a --dry-run error, got %v", args, err)
+		}
+	}
+}
+
+func TestRunE_WatchFlags(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	fake := &fakeExecutor{}
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		return fake, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--watch-glob", "sql/**/*.sql", "--watch-glob", "*.env", "./seed.sh"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if fake.watched != "./seed.sh" || !reflect.DeepEqual(fake.watchOpts.Patterns, []string{"sql/**/*.sql", "*.env"}) {
+		t.Errorf("Watch(%q, %v); want ./seed.sh with both globs", fake.watched, fake.watchOpts.Patterns)
+	}
+	if fake.executePath != "" || fake.inlineContent != "" {
+		t.Error("expected the script to run only through Watch")
+	}
+}
+
+func TestRunE_WatchRejectsOtherModes(t *testing.T) {
+	for _, args := range [][]string{
+		{"--watch", "--each", "a.sh", "b.sh"},
+		{"--watch", "--environments", "dev,test", "echo hi"},
+		{"--watch", "--dry-run", "./seed.sh"},
+		{"--watch-glob", "*.sql", "-"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--watch") {
+			t.Errorf("Execute(%v): expected a --watch error, got %v", args, err)
+		}
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	got := formatCommand([]string{"bash", "-c", "echo 'hi there'", ""})
+	if want := `bash -c "echo 'hi there'" ""`; got != want {
+		t.Errorf("formatCommand() = %s, want %s", got, want)
+	}
+}
+
+func TestRunE_EnvFlags(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	var got executor.Config
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		got = cfg
+		return &fakeExecutor{}, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{
+		"--env", "A=1", "--env", "LIST=x,y",
+		"--env-file", "base.env", "--env-file", "local.env",
+		"--unset", "SECRET",
+		"echo hi",
+	})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(got.EnvOverrides, []string{"A=1", "LIST=x,y"}) {
+		t.Errorf("EnvOverrides = %v", got.EnvOverrides)
+	}
+	if !reflect.DeepEqual(got.EnvFiles, []string{"base.env", "local.env"}) {
+		t.Errorf("EnvFiles = %v", got.EnvFiles)
+	}
+	if !reflect.DeepEqual(got.Unset, []string{"SECRET"}) {
+		t.Errorf("Unset = %v", got.Unset)
+	}
+}
+
+func TestRunE_WorkdirFlag(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	var got executor.Config
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		got = cfg
+		return &fakeExecutor{}, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--workdir", executor.WorkingDirProject, "echo hi"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got.WorkingDir != executor.WorkingDirProject {
+		t.Errorf("WorkingDir = %q, want %q", got.WorkingDir, executor.WorkingDirProject)
+	}
+}
+
+func TestRunE_CacheFlags(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	var got executor.Config
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		got = cfg
+		return &fakeExecutor{}, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-cache", "--cache-ttl", "1m", "echo hi"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !got.NoCache || got.CacheTTL != time.Minute {
+		t.Errorf("NoCache = %v, CacheTTL = %v; want true, 1m", got.NoCache, got.CacheTTL)
+	}
+}
+
+func TestRunE_RetryFlags(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	var got executor.Config
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		got = cfg
+		return &fakeExecutor{}, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--retries", "3", "--retry-delay", "2s", "--retry-on-exit-codes", "1,75", "./validate.sh"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got.Retries != 3 || got.RetryDelay != 2*time.Second || !reflect.DeepEqual(got.RetryOnExitCodes, []int{1, 75}) {
+		t.Errorf("Retries = %d, RetryDelay = %v, RetryOnExitCodes = %v; want 3, 2s, [1 75]", got.Retries, got.RetryDelay, got.RetryOnExitCodes)
+	}
+}
+
+func TestRunE_RequireTrustedFlag(t *testing.T) {
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+
+	for args, want := range map[string]executor.TrustMode{
+		"":                  executor.TrustPrompt,
+		"--require-trusted": executor.TrustRequire,
+	} {
+		var got executor.Config
+		newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+			got = cfg
+			return &fakeExecutor{}, nil
+		}
+
+		cmd := newRootCmd()
+		cmd.SetArgs(append(strings.Fields(args), "./deploy.sh"))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute(%q) failed: %v", args, err)
+		}
+		if got.Trust != want {
+			t.Errorf("Execute(%q): Trust = %q, want %q", args, got.Trust, want)
+		}
+	}
+}
+
+func TestRunE_RecordsHistory(t *testing.T) {
+	oldNew, oldRecord := newScriptExecutor, recordHistory
+	defer func() { newScriptExecutor, recordHistory = oldNew, oldRecord }()
+
+	fake := &fakeExecutor{result: &executor.Result{Shell: "bash", ExitCode: 4, DurationMs: 20}}
+	newScriptExecutor = func(cfg executor.Config) (scriptExecutor, error) {
+		return fake, nil
+	}
+	var recorded []history.Record
+	recordHistory = func(_ string, rec history.Record) (history.Record, error) {
+		recorded = append(recorded, rec)
+		return rec, nil
+	}
+	t.Setenv("AZURE_ENV_NAME", "dev")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--env", "TOKEN=plain", "echo $TOKEN"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("expected one recorded run, got %d", len(recorded))
+	}
+	rec := recorded[0]
+	if rec.Environment != "dev" || rec.InlineHash == "" || rec.Status != history.StatusFailed || rec.ExitCode != 4 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	if len(rec.Options.Env) != 0 || !reflect.DeepEqual(rec.Options.OmittedEnv, []string{"TOKEN"}) {
+		t.Errorf("expected the plain --env value to be left out, got Env %v", rec.Options.Env)
+	}
+
+	for args, want := range map[string][]string{
+		"./deploy.sh --token s3cret":               nil,
+		"--record-args ./deploy.sh --token s3cret": {"--token", "s3cret"},
+	} {
+		recorded = nil
+		cmd := newRootCmd()
+		cmd.SetArgs(strings.Fields(args))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute(%q) failed: %v", args, err)
+		}
+		if len(recorded) != 1 || !reflect.DeepEqual(recorded[0].Args, want) || recorded[0].ArgsHash == "" {
+			t.Errorf("Execute(%q) recorded %+v, want Args %v and an ArgsHash", args, recorded, want)
+		}
+	}
+}
+
+func TestRegisterProjectInterpreters(t *testing.T) {
+	root := t.TempDir()
+	config := "interpreters:\n  main-test-uv:\n    executable: uv\n    args: [run, python]\n    inline: [\"-c\", \"{script}\"]\n"
+	if err := os.WriteFile(filepath.Join(root, ".azdexec.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Chdir(root)
+
+	if err := registerProjectInterpreters(); err != nil {
+		t.Fatalf("registerProjectInterpreters() error: %v", err)
+	}
+	if _, err := executor.New(executor.Config{Shell: "main-test-uv"}); err != nil {
+		t.Errorf("expected the project interpreter to be a valid shell: %v", err)
+	}
+}
+
+func TestRegisterProjectInterpreters_NoProject(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if err := registerProjectInterpreters(); err != nil {
+		t.Errorf("registerProjectInterpreters() outside a project error: %v", err)
+	}
+}
+
+func TestPersistentPreRunE_InvalidProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".azdexec.yaml"), []byte("bogus: 1\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Chdir(root)
+	t.Setenv(trust.EnvVarTrustFile, filepath.Join(t.TempDir(), "trust.json"))
+
+	oldNew := newScriptExecutor
+	defer func() { newScriptExecutor = oldNew }()
+	newScriptExecutor = func(executor.Config) (scriptExecutor, error) { return &fakeExecutor{}, nil }
+
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{args: []string{"./deploy.sh"}},
+		{args: []string{"version"}},
+		{args: []string{"--shell", "bash", "./deploy.sh"}},
+		{args: []string{"--shell", "main-test-missing", "./deploy.sh"}, wantErr: true},
+		{args: []string{"list"}, wantErr: true},
+		{args: []string{"shells"}, wantErr: true},
+		{args: []string{"trust", "list"}},
+	}
+	for _, tt := range tests {
+		cmd := newRootCmd()
+		cmd.SetArgs(tt.args)
+		err := cmd.Execute()
+		if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "bogus")) {
+			t.Errorf("Execute(%v) error = %v, want the configuration error", tt.args, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("Execute(%v) failed: %v", tt.args, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jongio/azd-core/cliout"
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+// Summary column names; they also select which JSON field carries the run's name.
+const (
+	summaryScriptColumn      = "Script"
+	summaryEnvironmentColumn = "Environment"
+)
+
+// summaryEntry is the summary output for one run of a multi-script or multi-environment invocation.
+type summaryEntry struct {
+	Script      string `json:"script,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Status      string `json:"status"`
+	ExitCode    int    `json:"exitCode"`
+	DurationMs  int64  `json:"durationMs"`
+	Error       string `json:"error,omitempty"`
+}
+
+// printSummary prints one row per result, named by each result's Name, as a
+// table or, with --output json, as a JSON array.
+func printSummary(column string, results []executor.ScriptResult) error {
+	entries := make([]summaryEntry, 0, len(results))
+	for _, r := range results {
+		entry := summaryEntry{
+			Status:     "succeeded",
+			ExitCode:   r.ExitCode,
+			DurationMs: r.Duration.Milliseconds(),
+		}
+		if column == summaryEnvironmentColumn {
+			entry.Environment = r.Name
+		} else {
+			entry.Script = r.Name
+		}
+		switch {
+		case r.Skipped:
+			entry.Status = "skipped"
+		case r.Err != nil:
+			entry.Status = "failed"
+			entry.Error = r.Err.Error()
+		}
+		entries = append(entries, entry)
+	}
+
+	return cliout.Print(entries, func() {
+		rows := make([]cliout.TableRow, 0, len(entries))
+		for i, entry := range entries {
+			duration := "-"
+			if !results[i].Skipped {
+				duration = results[i].Duration.Round(time.Millisecond).String()
+			}
+			rows = append(rows, cliout.TableRow{
+				column:      results[i].Name,
+				"Status":    entry.Status,
+				"Exit Code": strconv.Itoa(entry.ExitCode),
+				"Duration":  duration,
+			})
+		}
+		cliout.Table([]string{column, "Status", "Exit Code", "Duration"}, rows)
+	})
+}
@@ -0,0 +1,221 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxInlineLabelLength caps how much of an inline script is used as its output label.
+const maxInlineLabelLength = 24
+
+// EachOptions controls how ExecuteEach runs multiple scripts.
+type EachOptions struct {
+	// Parallel is the maximum number of scripts that run at the same time.
+	// Values below 2 run scripts one after another in the order given.
+	Parallel int
+
+	// ContinueOnError runs every script even after one fails.
+	// By default no further scripts are started once a script fails.
+	ContinueOnError bool
+}
+
+// ScriptResult is the outcome of one script run by ExecuteEach or ForEach.
+type ScriptResult struct {
+	// Script is the script file or inline command as given.
+	Script string
+
+	// Name is the short name used to label the script's output: the file's
+	// base name, or the first line of an inline script.
+	Name string
+
+	// ExitCode is the script's exit code. It is 1 for failures that are not
+	// script exits (for example a timeout) and 0 for skipped scripts.
+	ExitCode int
+
+	// Duration is how long the script ran.
+	Duration time.Duration
+
+	// Err is the error returned for the script, if any.
+	Err error
+
+	// Skipped is true when the script was not started because an earlier
+	// script failed or the run was cancelled.
+	Skipped bool
+
+	// Result describes the script's run; it is nil when the script did not start.
+	Result *Result
+}
+
+// ExecuteEach runs several scripts with a single, shared environment.
+// Each entry is run as a file if it exists and as an inline script otherwise.
+// The environment, including Key Vault references, is resolved once before any
+// script starts. With opts.Parallel above 1, output lines are prefixed with the
+// script name so interleaved output stays readable.
+// Results are returned in the order the scripts were given. If any script fails,
+// a *BatchError wrapping the first failure (in that order) is also returned.
+// Returns an error without running anything if:
+//   - scripts is empty or any entry is invalid
+//   - any script file is not trusted under Config.Trust
+//   - Interactive mode is combined with parallel execution
+//   - environment preparation fails
+func (e *Executor) ExecuteEach(ctx context.Context, scripts []string, opts EachOptions) ([]ScriptResult, error) {
+	if len(scripts) == 0 {
+		return nil, &ValidationError{Field: "scripts", Reason: "at least one script is required"}
+	}
+	if opts.Parallel < 0 {
+		return nil, &ValidationError{Field: "parallel", Reason: "cannot be negative"}
+	}
+	parallel := max(opts.Parallel, 1)
+	if parallel > 1 && e.config.Interactive {
+		return nil, &ValidationError{Field: "interactive", Reason: "cannot be used with parallel execution"}
+	}
+
+	invocations := make([]invocation, len(scripts))
+	labels := make([]string, len(scripts))
+	for i, script := range scripts {
+		inv, err := e.scriptInvocation(script)
+		if err != nil {
+			return nil, err
+		}
+		invocations[i] = inv
+		labels[i] = scriptLabel(inv)
+	}
+	for _, inv := range invocations {
+		if err := e.checkTrust(inv); err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := e.resolveEnvironment(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputMu sync.Mutex // keeps prefixed lines from different scripts intact
+	runs := make([]*Result, len(scripts))
+	results := ForEach(ctx, len(scripts), opts, func(ctx context.Context, i int) error {
+		inv := invocations[i]
+		if parallel > 1 {
+			stdout := NewPrefixWriter(inv.stdout, labels[i], &outputMu)
+			stderr := NewPrefixWriter(inv.stderr, labels[i], &outputMu)
+			defer func() {
+				_ = stdout.Flush()
+				_ = stderr.Flush()
+			}()
+			inv.stdout, inv.stderr = stdout, stderr
+		}
+		var err error
+		runs[i], err = e.run(ctx, inv, env)
+		return err
+	})
+	for i := range results {
+		results[i].Script = scripts[i]
+		results[i].Name = labels[i]
+		results[i].Result = runs[i]
+	}
+
+	return results, batchError(results)
+}
+
+// ForEach calls run for every index in [0, n), starting them in order with at
+// most opts.Parallel calls running at once. Unless opts.ContinueOnError is set,
+// no further calls start once one returns an error; none start after ctx is done.
+// The returned results carry the outcome of each call; the caller fills in the
+// Script and Name fields.
+func ForEach(ctx context.Context, n int, opts EachOptions, run func(ctx context.Context, i int) error) []ScriptResult {
+	results := make([]ScriptResult, n)
+	for i := range results {
+		results[i].Skipped = true
+	}
+
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+		slots  = make(chan struct{}, max(opts.Parallel, 1))
+	)
+	for i := range n {
+		slots <- struct{}{}
+		if ctx.Err() != nil || (failed.Load() && !opts.ContinueOnError) {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			start := time.Now()
+			err := run(ctx, i)
+			results[i] = ScriptResult{
+				ExitCode: exitCodeOf(err),
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			if err != nil {
+				failed.Store(true)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// scriptInvocation treats script as a file path if it exists, otherwise as inline content.
+func (e *Executor) scriptInvocation(script string) (invocation, error) {
+	if absPath, err := filepath.Abs(script); err == nil {
+		if _, statErr := os.Stat(absPath); statErr == nil {
+			return e.fileInvocation(absPath)
+		}
+	}
+	return e.inlineInvocation(script)
+}
+
+// scriptLabel returns a short name for inv used to prefix its output.
+func scriptLabel(inv invocation) string {
+	if !inv.isInline {
+		return filepath.Base(inv.scriptOrPath)
+	}
+	label, _, _ := strings.Cut(strings.TrimSpace(inv.scriptOrPath), "\n")
+	if runes := []rune(label); len(runes) > maxInlineLabelLength {
+		label = string(runes[:maxInlineLabelLength]) + "..."
+	}
+	return label
+}
+
+// exitCodeOf returns the exit code to report for a script that returned err.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var execErr *ExecutionError
+	if errors.As(err, &execErr) && execErr.ExitCode > 0 {
+		return execErr.ExitCode
+	}
+	return 1
+}
+
+// batchError returns a *BatchError for results that contain failures, or nil.
+func batchError(results []ScriptResult) error {
+	var batchErr *BatchError
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		if batchErr == nil {
+			batchErr = &BatchError{Total: len(results), Script: r.Name, Err: r.Err}
+		}
+		batchErr.Failed++
+	}
+	if batchErr == nil {
+		return nil
+	}
+	return batchErr
+}
@@ -0,0 +1,208 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecuteEach_Validation(t *testing.T) {
+	exec, err := New(Config{Interactive: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		scripts []string
+		opts    EachOptions
+		field   string
+	}{
+		{name: "no scripts", field: "scripts"},
+		{name: "negative parallel", scripts: []string{"echo hi"}, opts: EachOptions{Parallel: -1}, field: "parallel"},
+		{name: "interactive parallel", scripts: []string{"echo hi"}, opts: EachOptions{Parallel: 2}, field: "interactive"},
+		{name: "empty inline entry", scripts: []string{"echo hi", "  "}, field: "scriptContent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := exec.ExecuteEach(context.Background(), tt.scripts, tt.opts)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+				t.Fatalf("expected ValidationError for %q, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestExecuteEach_StopsAfterFirstFailure(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	exec, err := New(Config{Shell: "bash"})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	results, err := exec.ExecuteEach(context.Background(), []string{"exit 0", "exit 4", "touch " + marker}, EachOptions{})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Failed != 1 || batchErr.Total != 3 {
+		t.Fatalf("expected BatchError with 1 of 3 failed, got %v", err)
+	}
+	var execErr *ExecutionError
+	if !errors.As(err, &execErr) || execErr.ExitCode != 4 {
+		t.Fatalf("expected wrapped ExecutionError with exit code 4, got %v", err)
+	}
+
+	if results[0].Err != nil || results[0].Skipped {
+		t.Errorf("first script: expected success, got %+v", results[0])
+	}
+	if results[1].ExitCode != 4 {
+		t.Errorf("second script: expected exit code 4, got %d", results[1].ExitCode)
+	}
+	if !results[2].Skipped {
+		t.Errorf("third script: expected to be skipped")
+	}
+	if _, statErr := os.Stat(marker); statErr == nil {
+		t.Error("third script should not have run")
+	}
+	if r := results[1].Result; r == nil || r.ExitCode != 4 || r.Shell != "bash" || r.StartTime.IsZero() {
+		t.Errorf("second script: expected its run's result, got %+v", r)
+	}
+	if results[2].Result != nil {
+		t.Errorf("third script: expected no result, got %+v", results[2].Result)
+	}
+}
+
+func TestExecuteEach_ContinueOnError(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{Shell: "bash"})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	results, err := exec.ExecuteEach(context.Background(), []string{"exit 2", "exit 0", "exit 5"}, EachOptions{ContinueOnError: true})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Failed != 2 || batchErr.Script != "exit 2" {
+		t.Fatalf("expected BatchError with 2 failures starting at 'exit 2', got %v", err)
+	}
+	got := []int{results[0].ExitCode, results[1].ExitCode, results[2].ExitCode}
+	if got[0] != 2 || got[1] != 0 || got[2] != 5 {
+		t.Errorf("expected exit codes [2 0 5], got %v", got)
+	}
+	for i, r := range results {
+		if r.Skipped {
+			t.Errorf("script %d: expected to run", i)
+		}
+	}
+}
+
+func TestExecuteEach_RunsInParallel(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	// Each script waits for the other's marker, so they only succeed if they overlap.
+	dir := t.TempDir()
+	waitFor := func(own, other string) string {
+		return "touch " + filepath.Join(dir, own) + "; for i in $(seq 50); do [ -f " + filepath.Join(dir, other) + " ] && exit 0; sleep 0.1; done; exit 1"
+	}
+
+	exec, err := New(Config{Shell: "bash"})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	results, err := exec.ExecuteEach(context.Background(), []string{waitFor("a", "b"), waitFor("b", "a")}, EachOptions{Parallel: 2})
+	if err != nil {
+		t.Fatalf("ExecuteEach() error: %v", err)
+	}
+	for i, r := range results {
+		if r.ExitCode != 0 {
+			t.Errorf("script %d: expected exit code 0, got %d", i, r.ExitCode)
+		}
+	}
+}
+
+func TestExecuteEach_ResolvesEnvironmentOnce(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	fake := &fakeEnvResolver{values: map[string]string{"@Microsoft.KeyVault(VaultName=v;SecretName=s)": "resolved"}}
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) { return fake, nil }
+
+	exec, err := New(Config{
+		Shell:        "bash",
+		EnvOverrides: []string{"AZD_EXEC_TEST_SECRET=@Microsoft.KeyVault(VaultName=v;SecretName=s)"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	check := `[ "$AZD_EXEC_TEST_SECRET" = resolved ]`
+	if _, err := exec.ExecuteEach(context.Background(), []string{check, check, check}, EachOptions{Parallel: 3}); err != nil {
+		t.Fatalf("ExecuteEach() error: %v", err)
+	}
+	if fake.calls != 1 {
+		t.Errorf("expected Key Vault resolution once, got %d", fake.calls)
+	}
+}
+
+func TestScriptLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		inv  invocation
+		want string
+	}{
+		{name: "file", inv: invocation{scriptOrPath: filepath.Join("scripts", "deploy.sh")}, want: "deploy.sh"},
+		{name: "inline first line", inv: invocation{scriptOrPath: "echo one\necho two", isInline: true}, want: "echo one"},
+		{name: "inline truncated", inv: invocation{scriptOrPath: strings.Repeat("x", 30), isInline: true}, want: strings.Repeat("x", maxInlineLabelLength) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scriptLabel(tt.inv); got != tt.want {
+				t.Errorf("scriptLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForEach_LimitsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	results := ForEach(context.Background(), 6, EachOptions{Parallel: 2}, func(context.Context, int) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("expected at most 2 concurrent runs, peak was %d", got)
+	}
+	for i, r := range results {
+		if r.Skipped || r.Err != nil {
+			t.Errorf("run %d: unexpected result %+v", i, r)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultGracePeriod is how long a timed-out or cancelled script is given to
+// exit after being interrupted before it is forcibly killed.
+const DefaultGracePeriod = 10 * time.Second
+
+// terminator stops a running command when its context is done.
+// It first interrupts the script (SIGTERM to the process group on Unix,
+// CTRL_BREAK on Windows) and kills it if it is still running after the grace period.
+type terminator struct {
+	cmd   *exec.Cmd
+	grace time.Duration
+	group bool
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// newTerminator wires graceful cancellation into cmd, which must have been
+// created with exec.CommandContext. When group is true the command is started
+// in its own process group so that child processes are signaled as well.
+func newTerminator(cmd *exec.Cmd, grace time.Duration, group bool) *terminator {
+	t := &terminator{cmd: cmd, grace: grace, group: group}
+	if group {
+		setProcessGroup(cmd)
+	}
+	cmd.Cancel = t.interrupt
+	// exec kills the direct child and closes its pipes if it outlives the grace period.
+	cmd.WaitDelay = grace
+	return t
+}
+
+// interrupt is installed as exec.Cmd.Cancel and runs once the context is done.
+func (t *terminator) interrupt() error {
+	t.mu.Lock()
+	t.timer = time.AfterFunc(t.grace, t.kill)
+	t.mu.Unlock()
+
+	if t.group {
+		return interruptProcessGroup(t.cmd.Process)
+	}
+	if err := t.cmd.Process.Signal(os.Interrupt); err != nil {
+		// os.Interrupt is not supported on Windows; fall back to killing the process.
+		return t.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// kill forcibly terminates the script once the grace period has elapsed.
+func (t *terminator) kill() {
+	if t.group {
+		_ = killProcessGroup(t.cmd.Process)
+		return
+	}
+	_ = t.cmd.Process.Kill()
+}
+
+// stop cancels a pending forced kill. It must be called after the command has exited.
+func (t *terminator) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate_Durations(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		wantField string
+	}{
+		{name: "Negative timeout", config: Config{Timeout: -time.Second}, wantField: "timeout"},
+		{name: "Negative grace period", config: Config{GracePeriod: -time.Second}, wantField: "gracePeriod"},
+		{name: "Positive durations", config: Config{Timeout: time.Minute, GracePeriod: time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if validationErr.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", validationErr.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestGracePeriodDefault(t *testing.T) {
+	exec, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if got := exec.gracePeriod(); got != DefaultGracePeriod {
+		t.Errorf("gracePeriod() = %v, want %v", got, DefaultGracePeriod)
+	}
+
+	exec, err = New(Config{GracePeriod: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if got := exec.gracePeriod(); got != 2*time.Second {
+		t.Errorf("gracePeriod() = %v, want 2s", got)
+	}
+}
+
+func TestExecuteInline_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{Shell: "bash", Timeout: 200 * time.Millisecond, GracePeriod: time.Second})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	start := time.Now()
+	err = exec.ExecuteInline(context.Background(), "sleep 30")
+	elapsed := time.Since(start)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected *TimeoutError, got %T: %v", err, err)
+	}
+	if timeoutErr.Timeout != 200*time.Millisecond {
+		t.Errorf("Timeout = %v, want 200ms", timeoutErr.Timeout)
+	}
+	if elapsed > 10*time.Second {
+		t.Errorf("script was not stopped promptly, took %v", elapsed)
+	}
+}
+
+func TestExecuteInline_KillsProcessGroupAfterGracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{Shell: "bash", Timeout: 200 * time.Millisecond, GracePeriod: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	// The script ignores SIGTERM and keeps a child holding stdout open,
+	// so only the forced kill of the whole group can end it.
+	start := time.Now()
+	err = exec.ExecuteInline(context.Background(), "trap '' TERM; sleep 30 & sleep 30; wait")
+	elapsed := time.Since(start)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected *TimeoutError, got %T: %v", err, err)
+	}
+	if elapsed > 10*time.Second {
+		t.Errorf("process group was not killed after the grace period, took %v", elapsed)
+	}
+}
+
+func TestExecuteInline_ContextCancellation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{Shell: "bash", GracePeriod: time.Second})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	err = exec.ExecuteInline(ctx, "sleep 30")
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected error for cancelled execution")
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		t.Fatalf("cancellation should not be reported as a timeout: %v", err)
+	}
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+	if elapsed > 10*time.Second {
+		t.Errorf("script was not stopped promptly, took %v", elapsed)
+	}
+}
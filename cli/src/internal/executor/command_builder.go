@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"os/exec"
 	"strings"
 
@@ -23,12 +24,14 @@ var validShells = map[string]bool{
 //   - Unix shells (bash, sh, zsh): Use -c for inline, direct path for files
 //   - PowerShell (pwsh, powershell): Use -Command for inline, -File for files
 //   - Windows cmd: Use /c for both inline and files
+//   - Registered interpreters (python, node, ...): Use their argument templates
 //   - Unknown shells: Fall back to -c flag (Unix-like behavior)
 //
 // Known shell names are normalized to lowercase for the executable binary
 // to ensure correct lookup on case-sensitive filesystems.
 // Script arguments (e.config.Args) are appended after the script specification.
-func (e *Executor) buildCommand(shell, scriptOrPath string, isInline bool) *exec.Cmd {
+// The command is bound to ctx; runCommand configures how it is stopped when ctx is done.
+func (e *Executor) buildCommand(ctx context.Context, shell, scriptOrPath string, isInline bool) *exec.Cmd {
 	var cmdArgs []string
 	skipAppendArgs := false
 
@@ -61,6 +64,11 @@ func (e *Executor) buildCommand(shell, scriptOrPath string, isInline bool) *exec
 	case shellutil.ShellCmd:
 		cmdArgs = []string{shellBin, "/c", scriptOrPath}
 	default:
+		if interp, ok := LookupInterpreter(shell); ok {
+			cmdArgs = interp.commandLine(scriptOrPath, isInline, e.config.Args)
+			skipAppendArgs = true
+			break
+		}
 		// Unknown shell: use Unix-like -c pattern as fallback.
 		// Preserve original casing for custom interpreters.
 		if isInline {
@@ -75,7 +83,7 @@ func (e *Executor) buildCommand(shell, scriptOrPath string, isInline bool) *exec
 		cmdArgs = append(cmdArgs, e.config.Args...)
 	}
 
-	return exec.Command(cmdArgs[0], cmdArgs[1:]...) //nolint:noctx // CLI command builder has no context available; #nosec G204
+	return exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...) // #nosec G204 -- script execution is the purpose of this tool
 }
 
 // buildPowerShellInlineCommand joins the inline script with its arguments into a single
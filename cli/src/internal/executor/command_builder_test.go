@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"os/exec"
 	"testing"
 )
@@ -35,7 +36,7 @@ func TestBuildCommandWithCustomShell(t *testing.T) {
 			if err != nil {
 				t.Fatalf("New() error: %v", err)
 			}
-			cmd := exec.buildCommand(tt.shell, tt.scriptPath, false)
+			cmd := exec.buildCommand(context.Background(), tt.shell, tt.scriptPath, false)
 
 			// Check if command was built
 			if cmd == nil {
@@ -82,7 +83,7 @@ func TestBuildCommandShellVariations(t *testing.T) {
 			if err != nil {
 				t.Fatalf("New() error: %v", err)
 			}
-			cmd := exec.buildCommand(tt.shell, tt.scriptPath, false)
+			cmd := exec.buildCommand(context.Background(), tt.shell, tt.scriptPath, false)
 
 			if cmd == nil {
 				t.Fatal("buildCommand returned nil")
@@ -97,7 +98,7 @@ func TestBuildCommandLookPath(t *testing.T) {
 	if err != nil {
 		t.Fatalf("New() error: %v", err)
 	}
-	cmd := exec.buildCommand("cmd", "test.bat", false)
+	cmd := exec.buildCommand(context.Background(), "cmd", "test.bat", false)
 
 	// On Windows, cmd should be findable
 	if cmd.Path == "" {
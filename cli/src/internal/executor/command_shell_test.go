@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -16,7 +17,7 @@ func TestBuildCommand_Bash(t *testing.T) {
 	}
 
 	t.Run("Inline bash", func(t *testing.T) {
-		cmd := exec.buildCommand("bash", "echo test", true)
+		cmd := exec.buildCommand(context.Background(), "bash", "echo test", true)
 		if len(cmd.Args) < 3 {
 			t.Fatalf("Expected at least 3 args, got %d", len(cmd.Args))
 		}
@@ -32,7 +33,7 @@ func TestBuildCommand_Bash(t *testing.T) {
 	})
 
 	t.Run("File bash", func(t *testing.T) {
-		cmd := exec.buildCommand("bash", "script.sh", false)
+		cmd := exec.buildCommand(context.Background(), "bash", "script.sh", false)
 		if len(cmd.Args) < 2 {
 			t.Fatalf("Expected at least 2 args, got %d", len(cmd.Args))
 		}
@@ -52,7 +53,7 @@ func TestBuildCommand_PowerShell(t *testing.T) {
 	}
 
 	t.Run("Inline pwsh", func(t *testing.T) {
-		cmd := exec.buildCommand("pwsh", "Write-Host 'test'", true)
+		cmd := exec.buildCommand(context.Background(), "pwsh", "Write-Host 'test'", true)
 		if len(cmd.Args) < 3 {
 			t.Fatalf("Expected at least 3 args, got %d", len(cmd.Args))
 		}
@@ -65,7 +66,7 @@ func TestBuildCommand_PowerShell(t *testing.T) {
 	})
 
 	t.Run("File powershell", func(t *testing.T) {
-		cmd := exec.buildCommand("powershell", "script.ps1", false)
+		cmd := exec.buildCommand(context.Background(), "powershell", "script.ps1", false)
 		if len(cmd.Args) < 3 {
 			t.Fatalf("Expected at least 3 args, got %d", len(cmd.Args))
 		}
@@ -89,7 +90,7 @@ func TestBuildCommand_PowerShellInlineArgsAreEmbedded(t *testing.T) {
 		t.Fatalf("New() error: %v", err)
 	}
 
-	cmd := exec.buildCommand("pwsh", "pnpm", true)
+	cmd := exec.buildCommand(context.Background(), "pwsh", "pnpm", true)
 
 	expected := "pnpm 'sync' '--' '--skip-sync'"
 	if cmd == nil {
@@ -112,7 +113,7 @@ func TestBuildCommand_Cmd(t *testing.T) {
 	}
 
 	t.Run("Inline cmd", func(t *testing.T) {
-		cmd := exec.buildCommand("cmd", "echo test", true)
+		cmd := exec.buildCommand(context.Background(), "cmd", "echo test", true)
 		if len(cmd.Args) < 3 {
 			t.Fatalf("Expected at least 3 args, got %d", len(cmd.Args))
 		}
@@ -125,7 +126,7 @@ func TestBuildCommand_Cmd(t *testing.T) {
 	})
 
 	t.Run("File cmd", func(t *testing.T) {
-		cmd := exec.buildCommand("cmd", "script.bat", false)
+		cmd := exec.buildCommand(context.Background(), "cmd", "script.bat", false)
 		if len(cmd.Args) < 3 {
 			t.Fatalf("Expected at least 3 args, got %d", len(cmd.Args))
 		}
@@ -143,7 +144,7 @@ func TestBuildCommand_WithArgs(t *testing.T) {
 		t.Fatalf("New() error: %v", err)
 	}
 
-	cmd := exec.buildCommand("bash", "script.sh", false)
+	cmd := exec.buildCommand(context.Background(), "bash", "script.sh", false)
 	if len(cmd.Args) < 4 {
 		t.Fatalf("Expected at least 4 args, got %d", len(cmd.Args))
 	}
@@ -164,7 +165,7 @@ func TestBuildCommand_Zsh(t *testing.T) {
 	}
 
 	t.Run("Inline zsh", func(t *testing.T) {
-		cmd := exec.buildCommand("zsh", "echo test", true)
+		cmd := exec.buildCommand(context.Background(), "zsh", "echo test", true)
 		if cmd.Args[0] != "zsh" {
 			t.Errorf("Expected 'zsh', got %q", cmd.Args[0])
 		}
@@ -174,7 +175,7 @@ func TestBuildCommand_Zsh(t *testing.T) {
 	})
 
 	t.Run("File zsh", func(t *testing.T) {
-		cmd := exec.buildCommand("zsh", "script.zsh", false)
+		cmd := exec.buildCommand(context.Background(), "zsh", "script.zsh", false)
 		if cmd.Args[0] != "zsh" {
 			t.Errorf("Expected 'zsh', got %q", cmd.Args[0])
 		}
@@ -190,7 +191,7 @@ func TestBuildCommand_Sh(t *testing.T) {
 		t.Fatalf("New() error: %v", err)
 	}
 
-	cmd := exec.buildCommand("sh", "script.sh", false)
+	cmd := exec.buildCommand(context.Background(), "sh", "script.sh", false)
 	if cmd.Args[0] != "sh" {
 		t.Errorf("Expected 'sh', got %q", cmd.Args[0])
 	}
@@ -203,7 +204,7 @@ func TestBuildCommand_CustomShell(t *testing.T) {
 	}
 
 	t.Run("Inline custom shell", func(t *testing.T) {
-		cmd := exec.buildCommand("python3", "print('test')", true)
+		cmd := exec.buildCommand(context.Background(), "python3", "print('test')", true)
 		if cmd.Args[0] != "python3" {
 			t.Errorf("Expected 'python3', got %q", cmd.Args[0])
 		}
@@ -213,7 +214,7 @@ func TestBuildCommand_CustomShell(t *testing.T) {
 	})
 
 	t.Run("File custom shell", func(t *testing.T) {
-		cmd := exec.buildCommand("python3", "script.py", false)
+		cmd := exec.buildCommand(context.Background(), "python3", "script.py", false)
 		if cmd.Args[0] != "python3" {
 			t.Errorf("Expected 'python3', got %q", cmd.Args[0])
 		}
@@ -244,7 +245,7 @@ func TestBuildCommand_CaseInsensitiveShellNames(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.shell, func(t *testing.T) {
-			cmd := exec.buildCommand(tt.shell, "test", true)
+			cmd := exec.buildCommand(context.Background(), tt.shell, "test", true)
 			if cmd.Args[0] != tt.expected {
 				t.Errorf("Expected %q, got %q", tt.expected, cmd.Args[0])
 			}
@@ -436,7 +437,7 @@ func TestBuildCommand(t *testing.T) {
 			if err != nil {
 				t.Fatalf("New() error: %v", err)
 			}
-			cmd := exec.buildCommand(tt.shell, tt.scriptPath, tt.isInline)
+			cmd := exec.buildCommand(context.Background(), tt.shell, tt.scriptPath, tt.isInline)
 
 			if cmd.Path == "" {
 				t.Error("buildCommand() returned command with empty Path")
@@ -0,0 +1,191 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// essentialEnvVars are the variables CleanEnviron always keeps because ordinary
+// programs need them to find executables, temporary and home directories,
+// locale settings, and the azd and Azure CLI configuration.
+var essentialEnvVars = []string{
+	// Unix
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "COLORTERM", "LANG", "LC_*", "TZ", "TMPDIR",
+	// Windows
+	"SystemRoot", "SystemDrive", "windir", "ComSpec", "PATHEXT", "TEMP", "TMP",
+	"USERPROFILE", "USERNAME", "HOMEDRIVE", "HOMEPATH", "APPDATA", "LOCALAPPDATA",
+	"ProgramData", "ProgramFiles", "ProgramFiles(x86)", "ProgramW6432", "CommonProgramFiles",
+	"PROCESSOR_ARCHITECTURE", "NUMBER_OF_PROCESSORS", "OS", "PSModulePath",
+	// azd and Azure CLI configuration
+	"AZD_CONFIG_DIR", "AZURE_CONFIG_DIR",
+}
+
+// CleanEnviron returns the variables in environ that are essential for running
+// programs (PATH, HOME, TEMP and similar) or whose names match one of patterns.
+// Patterns use path.Match syntax, e.g. "NODE_*". Names compare case-insensitively on Windows.
+// Returns a *ValidationError if a pattern is malformed.
+func CleanEnviron(environ, patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, &ValidationError{Field: "passEnv", Reason: fmt.Sprintf("%q is not a valid pattern", pattern)}
+		}
+	}
+
+	keep := append(append([]string{}, essentialEnvVars...), patterns...)
+	var kept []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if matchesEnvPattern(keep, name) {
+			kept = append(kept, kv)
+		}
+	}
+	return kept, nil
+}
+
+// matchesEnvPattern reports whether name matches one of patterns.
+func matchesEnvPattern(patterns []string, name string) bool {
+	if runtime.GOOS == osWindows {
+		name = strings.ToUpper(name)
+	}
+	for _, pattern := range patterns {
+		if runtime.GOOS == osWindows {
+			pattern = strings.ToUpper(pattern)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeEnv returns base with overrides applied. Each override is a KEY=VALUE pair;
+// an existing variable with the same key is replaced in place, otherwise the
+// pair is appended. Keys compare case-insensitively on Windows.
+func MergeEnv(base, overrides []string) []string {
+	if len(overrides) == 0 {
+		return base
+	}
+
+	merged := make([]string, len(base), len(base)+len(overrides))
+	copy(merged, base)
+
+	for _, kv := range overrides {
+		key, _, _ := strings.Cut(kv, "=")
+		if i := indexEnv(merged, key); i >= 0 {
+			merged[i] = kv
+		} else {
+			merged = append(merged, kv)
+		}
+	}
+	return merged
+}
+
+// AzdEnvironmentEnv returns the values of the azd environment named name as
+// KEY=VALUE pairs sorted by key, with AZURE_ENV_NAME set to name, ready to be
+// merged into a script's environment with MergeEnv.
+func AzdEnvironmentEnv(name string, values map[string]string) []string {
+	envVars := make([]string, 0, len(values)+1)
+	for key, value := range values {
+		if key != "AZURE_ENV_NAME" {
+			envVars = append(envVars, key+"="+value)
+		}
+	}
+	envVars = append(envVars, "AZURE_ENV_NAME="+name)
+	sort.Strings(envVars)
+	return envVars
+}
+
+// unsetEnv returns envVars without the variables named in keys.
+func unsetEnv(envVars, keys []string) []string {
+	if len(keys) == 0 {
+		return envVars
+	}
+
+	kept := make([]string, 0, len(envVars))
+	for _, kv := range envVars {
+		name, _, _ := strings.Cut(kv, "=")
+		if !containsEnvKey(keys, name) {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// containsEnvKey reports whether keys names the variable key.
+func containsEnvKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if sameEnvKey(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// readEnvFile reads a dotenv file and returns its variables as KEY=VALUE pairs sorted by key.
+func readEnvFile(path string) ([]string, error) {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &ValidationError{Field: "envFile", Reason: fmt.Sprintf("%s does not exist", filepath.Base(path))}
+		}
+		return nil, &ValidationError{Field: "envFile", Reason: fmt.Sprintf("cannot read %s: %v", filepath.Base(path), err)}
+	}
+
+	envVars := make([]string, 0, len(values))
+	for key, value := range values {
+		envVars = append(envVars, key+"="+value)
+	}
+	sort.Strings(envVars)
+	return envVars, nil
+}
+
+// indexEnv returns the index of key in envVars, or -1 if it is not present.
+func indexEnv(envVars []string, key string) int {
+	for i, kv := range envVars {
+		if k, _, ok := strings.Cut(kv, "="); ok && sameEnvKey(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameEnvKey reports whether two environment variable names refer to the same variable.
+func sameEnvKey(a, b string) bool {
+	if runtime.GOOS == osWindows {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// resolvedSecrets returns the values in resolved that replaced a secret reference
+// in unresolved, leaving out values too short to be masked; tooShort names the
+// variables that held them.
+func resolvedSecrets(unresolved, resolved []string) (secrets, tooShort []string) {
+	for _, kv := range resolved {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || value == "" {
+			continue
+		}
+		i := indexEnv(unresolved, key)
+		if i < 0 {
+			continue
+		}
+		_, original, _ := strings.Cut(unresolved[i], "=")
+		if original == value || !IsSecretReference(original) {
+			continue
+		}
+		if isMaskable(value) {
+			secrets = append(secrets, value)
+		} else {
+			tooShort = append(tooShort, key)
+		}
+	}
+	return secrets, tooShort
+}
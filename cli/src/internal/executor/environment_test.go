@@ -0,0 +1,291 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jongio/azd-core/keyvault"
+)
+
+func TestMergeEnv(t *testing.T) {
+	base := []string{"A=1", "B=2"}
+
+	got := MergeEnv(base, []string{"B=override", "C=3", "D="})
+	want := []string{"A=1", "B=override", "C=3", "D="}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeEnv() = %v, want %v", got, want)
+	}
+	if base[1] != "B=2" {
+		t.Errorf("MergeEnv() modified its input: %v", base)
+	}
+	if got := MergeEnv(base, nil); !reflect.DeepEqual(got, base) {
+		t.Errorf("MergeEnv() without overrides = %v, want %v", got, base)
+	}
+}
+
+func TestAzdEnvironmentEnv(t *testing.T) {
+	got := AzdEnvironmentEnv("dev", map[string]string{"WEB_URL": "https://dev", "AZURE_ENV_NAME": "stale", "API_URL": "https://api"})
+	want := []string{"API_URL=https://api", "AZURE_ENV_NAME=dev", "WEB_URL=https://dev"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AzdEnvironmentEnv() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigValidate_EnvOverrides(t *testing.T) {
+	for _, kv := range []string{"NOEQUALS", "=value"} {
+		cfg := Config{EnvOverrides: []string{kv}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate() with override %q should fail", kv)
+		}
+	}
+
+	cfg := Config{EnvOverrides: []string{"KEY=", "OTHER=a=b"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+}
+
+func TestResolveEnvironment_AppliesOverrides(t *testing.T) {
+	t.Setenv("AZD_EXEC_TEST_BASE", "base")
+
+	exec, err := New(Config{EnvOverrides: []string{"AZD_EXEC_TEST_BASE=overridden", "AZD_EXEC_TEST_NEW=added"}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil {
+		t.Fatalf("resolveEnvironment() error: %v", err)
+	}
+
+	env := strings.Join(resolved.vars, "\n")
+	if !strings.Contains(env, "AZD_EXEC_TEST_BASE=overridden") || strings.Contains(env, "AZD_EXEC_TEST_BASE=base") {
+		t.Errorf("expected override to replace base value")
+	}
+	if !strings.Contains(env, "AZD_EXEC_TEST_NEW=added") {
+		t.Errorf("expected new variable to be added")
+	}
+}
+
+func TestResolveEnvironment_OverrideKeyVaultReference(t *testing.T) {
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	fake := &fakeEnvResolver{values: map[string]string{"@Microsoft.KeyVault(VaultName=v;SecretName=s)": "resolved"}}
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) { return fake, nil }
+
+	exec, err := New(Config{EnvOverrides: []string{"AZD_EXEC_TEST_SECRET=@Microsoft.KeyVault(VaultName=v;SecretName=s)"}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil {
+		t.Fatalf("resolveEnvironment() error: %v", err)
+	}
+	if !strings.Contains(strings.Join(resolved.vars, "\n"), "AZD_EXEC_TEST_SECRET=resolved") {
+		t.Error("expected Key Vault reference in override to be resolved")
+	}
+}
+
+func TestResolveEnvironment_EnvFilesOverridesAndUnset(t *testing.T) {
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	fake := &fakeEnvResolver{values: map[string]string{"@Microsoft.KeyVault(VaultName=v;SecretName=s)": "resolved"}}
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) { return fake, nil }
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.env")
+	second := filepath.Join(dir, "second.env")
+	if err := os.WriteFile(first, []byte("# shared settings\nREGION=westus\nTIER=basic\nDB_PASSWORD=\"@Microsoft.KeyVault(VaultName=v;SecretName=s)\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("export TIER=premium\nDEBUG=true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	exec, err := New(Config{
+		Environ:      []string{"REGION=eastus", "NAME=base", "SECRET_TOKEN=abc"},
+		EnvFiles:     []string{first, second},
+		EnvOverrides: []string{"NAME=override", "DEBUG=false"},
+		Unset:        []string{"SECRET_TOKEN"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil {
+		t.Fatalf("resolveEnvironment() error: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, kv := range resolved.vars {
+		key, value, _ := strings.Cut(kv, "=")
+		got[key] = value
+	}
+	want := map[string]string{
+		"REGION":      "westus",   // env file over base environment
+		"TIER":        "premium",  // later env file over earlier one
+		"NAME":        "override", // override over base environment
+		"DEBUG":       "false",    // override over env file
+		"DB_PASSWORD": "resolved", // env file values are resolved from Key Vault
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("environment = %v, want %v", got, want)
+	}
+}
+
+func TestResolveEnvironment_MissingEnvFile(t *testing.T) {
+	exec, err := New(Config{EnvFiles: []string{filepath.Join(t.TempDir(), "missing.env")}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	_, err = exec.resolveEnvironment(context.Background())
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "envFile" || !strings.Contains(err.Error(), "missing.env does not exist") {
+		t.Errorf("expected envFile validation error, got %v", err)
+	}
+}
+
+func TestConfigValidate_EnvFilesAndUnset(t *testing.T) {
+	for _, cfg := range []Config{
+		{EnvFiles: []string{" "}},
+		{Unset: []string{""}},
+		{Unset: []string{"KEY=VALUE"}},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestCleanEnviron(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "HOME=/home/me", "LC_ALL=C", "NODE_ENV=dev", "NODE_OPTIONS=--trace", "GITHUB_TOKEN=abc", "EDITOR=vim"}
+
+	got, err := CleanEnviron(environ, []string{"NODE_*"})
+	if err != nil {
+		t.Fatalf("CleanEnviron() error: %v", err)
+	}
+	want := []string{"PATH=/usr/bin", "HOME=/home/me", "LC_ALL=C", "NODE_ENV=dev", "NODE_OPTIONS=--trace"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CleanEnviron() = %v, want %v", got, want)
+	}
+
+	if _, err := CleanEnviron(environ, []string{"NODE_["}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func TestResolveWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		workingDir string
+		want       string
+		wantErr    bool
+	}{
+		{name: "default", workingDir: "", want: "/default"},
+		{name: "script shorthand", workingDir: WorkingDirScript, want: "/default"},
+		{name: "existing directory", workingDir: dir, want: dir},
+		{name: "missing directory", workingDir: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "file", workingDir: file, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, err := New(Config{WorkingDir: tt.workingDir})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			got, err := exec.resolveWorkingDir("/default")
+			if tt.wantErr {
+				if _, ok := err.(*ValidationError); !ok {
+					t.Fatalf("expected *ValidationError, got %T: %v", err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveWorkingDir() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveWorkingDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWorkingDir_Project(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "azure.yaml"), []byte("name: demo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(root, "scripts", "db")
+	if err := os.MkdirAll(nested, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(nested)
+
+	exec, err := New(Config{WorkingDir: WorkingDirProject})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	got, err := exec.resolveWorkingDir("/default")
+	if err != nil {
+		t.Fatalf("resolveWorkingDir() error: %v", err)
+	}
+	if got != root {
+		t.Errorf("resolveWorkingDir() = %q, want %q", got, root)
+	}
+
+	t.Chdir(t.TempDir())
+	if _, err := exec.resolveWorkingDir("/default"); err == nil {
+		t.Error("expected an error outside an azd project")
+	} else if _, ok := err.(*ValidationError); !ok {
+		t.Errorf("expected *ValidationError, got %T: %v", err, err)
+	}
+}
+
+// fakeEnvResolver resolves Key Vault references from an in-memory map.
+type fakeEnvResolver struct {
+	values map[string]string
+	calls  int
+}
+
+func (f *fakeEnvResolver) ResolveEnvironmentVariables(_ context.Context, envVars []string, options keyvault.ResolveEnvironmentOptions) ([]string, []keyvault.KeyVaultResolutionWarning, error) {
+	f.calls++
+	resolved := make([]string, 0, len(envVars))
+	var warnings []keyvault.KeyVaultResolutionWarning
+	for _, kv := range envVars {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !keyvault.IsKeyVaultReference(value) {
+			resolved = append(resolved, kv)
+			continue
+		}
+		secret, found := f.values[value]
+		if !found {
+			warnings = append(warnings, keyvault.KeyVaultResolutionWarning{Key: key, Err: fmt.Errorf("secret not found")})
+			if options.StopOnError {
+				return nil, warnings, fmt.Errorf("failed to resolve Key Vault reference for %s", key)
+			}
+			resolved = append(resolved, kv)
+			continue
+		}
+		resolved = append(resolved, key+"="+secret)
+	}
+	return resolved, warnings, nil
+}
@@ -1,6 +1,10 @@
 package executor
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // ValidationError indicates that input validation failed.
 type ValidationError struct {
@@ -27,19 +31,78 @@ type InvalidShellError struct {
 }
 
 func (e *InvalidShellError) Error() string {
-	return fmt.Sprintf("invalid shell: %s (valid: bash, sh, zsh, pwsh, powershell, cmd)", e.Shell)
+	return fmt.Sprintf("invalid shell: %s (valid: %s)", e.Shell, strings.Join(ShellNames(), ", "))
 }
 
 // ExecutionError indicates that script execution failed with an exit code.
+// When the script was terminated by a signal, Signal holds the signal number
+// and ExitCode is 128 + Signal, matching shell conventions.
 type ExecutionError struct {
 	ExitCode int
+	Signal   int
 	Shell    string
 	IsInline bool
 }
 
 func (e *ExecutionError) Error() string {
+	if e.Signal != 0 {
+		if e.IsInline {
+			return fmt.Sprintf("inline script terminated by signal %d (shell: %s)", e.Signal, e.Shell)
+		}
+		return fmt.Sprintf("script terminated by signal %d (shell: %s)", e.Signal, e.Shell)
+	}
 	if e.IsInline {
 		return fmt.Sprintf("inline script exited with code %d (shell: %s)", e.ExitCode, e.Shell)
 	}
 	return fmt.Sprintf("script exited with code %d (shell: %s)", e.ExitCode, e.Shell)
 }
+
+// TimeoutError indicates that script execution exceeded the configured timeout
+// and the script was terminated.
+type TimeoutError struct {
+	Timeout  time.Duration
+	Shell    string
+	IsInline bool
+}
+
+func (e *TimeoutError) Error() string {
+	if e.IsInline {
+		return fmt.Sprintf("inline script timed out after %s (shell: %s)", e.Timeout, e.Shell)
+	}
+	return fmt.Sprintf("script timed out after %s (shell: %s)", e.Timeout, e.Shell)
+}
+
+// BatchError indicates that one or more scripts run by ExecuteEach failed.
+// It wraps the error of the first failed script, so errors.As can still
+// reach that script's *ExecutionError.
+type BatchError struct {
+	Failed int
+	Total  int
+	Script string
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d scripts failed; first failure in %s: %v", e.Failed, e.Total, e.Script, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// UntrustedScriptError indicates that a script file was not run because it is
+// not in the trust store, or has changed since it was trusted, and was not
+// confirmed. Reason says why no confirmation was asked for or given.
+type UntrustedScriptError struct {
+	Path    string
+	Changed bool
+	Reason  string
+}
+
+func (e *UntrustedScriptError) Error() string {
+	state := "is not trusted"
+	if e.Changed {
+		state = "has changed since it was trusted"
+	}
+	return fmt.Sprintf("script %s %s (%s); review it and run 'azd exec trust add %s' to trust it", e.Path, state, e.Reason, e.Path)
+}
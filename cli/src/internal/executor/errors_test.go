@@ -1,8 +1,10 @@
 package executor
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidationError(t *testing.T) {
@@ -66,6 +68,25 @@ func TestExecutionError(t *testing.T) {
 			},
 			wantText: "script exited with code 127 (shell: pwsh)",
 		},
+		{
+			name: "Script terminated by signal",
+			err: &ExecutionError{
+				ExitCode: 143,
+				Signal:   15,
+				Shell:    "bash",
+			},
+			wantText: "script terminated by signal 15 (shell: bash)",
+		},
+		{
+			name: "Inline script terminated by signal",
+			err: &ExecutionError{
+				ExitCode: 137,
+				Signal:   9,
+				Shell:    "sh",
+				IsInline: true,
+			},
+			wantText: "inline script terminated by signal 9 (shell: sh)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -124,3 +145,28 @@ func TestConfigValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeoutError(t *testing.T) {
+	fileErr := &TimeoutError{Timeout: 30 * time.Second, Shell: "bash"}
+	if got, want := fileErr.Error(), "script timed out after 30s (shell: bash)"; got != want {
+		t.Errorf("TimeoutError.Error() = %q, want %q", got, want)
+	}
+
+	inlineErr := &TimeoutError{Timeout: 2 * time.Minute, Shell: "pwsh", IsInline: true}
+	if got, want := inlineErr.Error(), "inline script timed out after 2m0s (shell: pwsh)"; got != want {
+		t.Errorf("TimeoutError.Error() = %q, want %q", got, want)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	inner := &ExecutionError{ExitCode: 2, Shell: "bash"}
+	err := &BatchError{Failed: 1, Total: 3, Script: "build.sh", Err: inner}
+
+	want := "1 of 3 scripts failed; first failure in build.sh: script exited with code 2 (shell: bash)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if !errors.Is(err, inner) {
+		t.Error("expected BatchError to unwrap to the first failure")
+	}
+}
@@ -1,31 +1,51 @@
 // Package executor provides secure script execution with Azure context and Key Vault integration.
-// It supports multiple shells (bash, sh, zsh, pwsh, powershell, cmd) and handles environment
-// variable resolution including Azure Key Vault secret references.
+// It runs scripts with the built-in shells (bash, sh, zsh, pwsh, powershell, cmd) or with
+// the interpreters in its registry, such as python, node and go, plus interpreter profiles
+// declared in project configuration. It handles environment variable resolution including
+// Azure Key Vault secret references.
 package executor
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jongio/azd-core/cliout"
 	"github.com/jongio/azd-core/keyvault"
 	"github.com/jongio/azd-core/shellutil"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
 )
 
 const osWindows = "windows"
 
+// Working directory shorthands accepted in Config.WorkingDir.
+const (
+	// WorkingDirProject runs scripts from the azd project root, the nearest
+	// directory at or above the current one that contains azure.yaml.
+	WorkingDirProject = "project"
+
+	// WorkingDirScript keeps the default: the script's directory for file
+	// scripts and the current directory for inline scripts.
+	WorkingDirScript = "script"
+)
+
 // Config holds the configuration for script execution.
 // All fields are optional and have sensible defaults.
 type Config struct {
-	// Shell specifies the shell to use for execution.
+	// Shell specifies the shell or interpreter to use for execution.
 	// If empty, shell is auto-detected from script extension or shebang.
-	// Valid values: bash, sh, zsh, pwsh, powershell, cmd
+	// Valid values: bash, sh, zsh, pwsh, powershell, cmd, or the name of a
+	// registered interpreter (see RegisterInterpreter and ShellNames), such as
+	// python, node, go or a project-defined profile.
 	Shell string
 
 	// Interactive enables interactive mode, connecting stdin to the script.
@@ -35,15 +55,145 @@ type Config struct {
 	// Default is false (continue resolving other references and run with unresolved values left as-is).
 	StopOnKeyVaultError bool
 
+	// NoCache fetches every Key Vault secret from Key Vault instead of reusing
+	// values resolved by earlier runs.
+	NoCache bool
+
+	// CacheTTL is how long resolved Key Vault secrets are reused by later runs
+	// of the same tenant and principal. Zero, the default, disables the cache.
+	CacheTTL time.Duration
+
 	// Args are additional arguments to pass to the script.
 	Args []string
+
+	// Timeout limits how long the script may run, across all retries and the
+	// delays between them. Zero means no timeout.
+	// When exceeded, the script is terminated and a *TimeoutError is returned.
+	Timeout time.Duration
+
+	// GracePeriod is how long a timed-out or cancelled script may take to exit
+	// after being interrupted before it is killed. Zero uses DefaultGracePeriod.
+	GracePeriod time.Duration
+
+	// Retries is how many more times a script is run after a failed attempt.
+	// Only failures with a non-zero exit code are retried, never timeouts or cancellation.
+	Retries int
+
+	// RetryDelay is the delay before the first retry; it doubles with every
+	// further retry and is shortened by a random jitter. Zero uses DefaultRetryDelay.
+	RetryDelay time.Duration
+
+	// RetryOnExitCodes limits retries to attempts that exit with one of these
+	// codes. If empty, any non-zero exit code is retried.
+	RetryOnExitCodes []int
+
+	// WorkingDir overrides the working directory. If empty, file scripts run in
+	// the script's directory and inline scripts in the current directory.
+	// WorkingDirProject selects the azd project root and WorkingDirScript the default.
+	WorkingDir string
+
+	// Environ is the base environment as KEY=VALUE pairs. If nil, the process environment is used.
+	Environ []string
+
+	// AzdEnvironment holds the values of the azd environment the script runs
+	// against as KEY=VALUE pairs, as returned by AzdEnvironmentEnv. Key Vault
+	// references are resolved in any variable, but references of other providers,
+	// such as env:// and file://, only in these values, EnvFiles and EnvOverrides,
+	// never in variables inherited from elsewhere.
+	AzdEnvironment []string
+
+	// EnvFiles are dotenv files loaded on top of the base environment, in order,
+	// so later files win over earlier ones.
+	EnvFiles []string
+
+	// EnvOverrides are KEY=VALUE pairs applied on top of the base environment and
+	// EnvFiles before Key Vault resolution, so values may themselves be Key Vault references.
+	EnvOverrides []string
+
+	// Unset names variables removed from the environment after EnvFiles and EnvOverrides are applied.
+	Unset []string
+
+	// Stdout and Stderr receive the script's output.
+	// If nil, the process's standard output and error are used.
+	// When Stderr is set, Key Vault warnings are written to it as well.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// CaptureOutput records the script's stdout and stderr in its Result
+	// instead of writing them to Stdout and Stderr.
+	CaptureOutput bool
+
+	// LogFile receives a copy of both stdout and stderr; StdoutFile and StderrFile
+	// receive one stream each. Files are created if needed and appended to.
+	LogFile    string
+	StdoutFile string
+	StderrFile string
+
+	// Tee keeps showing a stream on Stdout or Stderr while it is written to a file.
+	// Without Tee, a stream that is written to a file is not shown.
+	Tee bool
+
+	// Timestamps prefixes every line written to a file with a timestamp.
+	Timestamps bool
+
+	// NoMask disables masking of resolved Key Vault secret values in the script's
+	// output. By default those values, and their base64 and URL-encoded forms,
+	// are replaced with *** everywhere output is written.
+	NoMask bool
+
+	// Trust selects whether script files must be in the trust store before they
+	// run. The zero value, TrustOff, runs them without checking.
+	Trust TrustMode
 }
 
 // Validate checks if the Config has valid values.
 func (c *Config) Validate() error {
-	if c.Shell != "" && !validShells[strings.ToLower(c.Shell)] {
+	if c.Shell != "" && !IsValidShell(c.Shell) {
 		return &InvalidShellError{Shell: c.Shell}
 	}
+	if c.Timeout < 0 {
+		return &ValidationError{Field: "timeout", Reason: "cannot be negative"}
+	}
+	if c.GracePeriod < 0 {
+		return &ValidationError{Field: "gracePeriod", Reason: "cannot be negative"}
+	}
+	if c.Retries < 0 {
+		return &ValidationError{Field: "retries", Reason: "cannot be negative"}
+	}
+	if c.RetryDelay < 0 {
+		return &ValidationError{Field: "retryDelay", Reason: "cannot be negative"}
+	}
+	for _, code := range c.RetryOnExitCodes {
+		if code <= 0 {
+			return &ValidationError{Field: "retryOnExitCodes", Reason: fmt.Sprintf("%d is not a failing exit code", code)}
+		}
+	}
+	switch c.Trust {
+	case TrustOff, TrustPrompt, TrustRequire:
+	default:
+		return &ValidationError{Field: "trust", Reason: fmt.Sprintf("unknown mode %q", c.Trust)}
+	}
+	if c.CacheTTL < 0 {
+		return &ValidationError{Field: "cacheTTL", Reason: "cannot be negative"}
+	}
+	if (c.Tee || c.Timestamps) && !c.hasLogFiles() {
+		return &ValidationError{Field: "tee", Reason: "tee and timestamps require a log, stdout or stderr file"}
+	}
+	for _, kv := range c.EnvOverrides {
+		if key, _, ok := strings.Cut(kv, "="); !ok || key == "" {
+			return &ValidationError{Field: "env", Reason: fmt.Sprintf("%q must be in KEY=VALUE format", kv)}
+		}
+	}
+	for _, path := range c.EnvFiles {
+		if strings.TrimSpace(path) == "" {
+			return &ValidationError{Field: "envFile", Reason: "cannot be empty"}
+		}
+	}
+	for _, key := range c.Unset {
+		if key == "" || strings.Contains(key, "=") {
+			return &ValidationError{Field: "unset", Reason: fmt.Sprintf("%q is not a variable name", key)}
+		}
+	}
 	return nil
 }
 
@@ -58,6 +208,9 @@ var newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) {
 // Executor executes scripts with azd context.
 type Executor struct {
 	config Config
+
+	mu         sync.Mutex
+	lastResult *Result
 }
 
 // New creates a new script executor with the given configuration.
@@ -77,17 +230,30 @@ func New(config Config) (*Executor, error) {
 //   - scriptPath does not exist or is not a regular file
 //   - scriptPath is a directory
 //   - scriptPath contains path traversal attempts (..)
+//   - the script is not trusted under Config.Trust
 //   - script execution fails
 func (e *Executor) Execute(ctx context.Context, scriptPath string) error {
+	inv, err := e.fileInvocation(scriptPath)
+	if err != nil {
+		return err
+	}
+	if err := e.checkTrust(inv); err != nil {
+		return err
+	}
+	return e.executeCommand(ctx, inv)
+}
+
+// fileInvocation validates a script file and describes how to run it.
+func (e *Executor) fileInvocation(scriptPath string) (invocation, error) {
 	// Validate script path
 	if scriptPath == "" {
-		return &ValidationError{Field: "scriptPath", Reason: "cannot be empty"}
+		return invocation{}, &ValidationError{Field: "scriptPath", Reason: "cannot be empty"}
 	}
 
 	// Get absolute path and validate
 	absPath, err := filepath.Abs(scriptPath)
 	if err != nil {
-		return &ValidationError{Field: "scriptPath", Reason: fmt.Sprintf("invalid path: %v", err)}
+		return invocation{}, &ValidationError{Field: "scriptPath", Reason: fmt.Sprintf("invalid path: %v", err)}
 	}
 
 	// Note: filepath.Abs resolves all ".." components, so explicit traversal
@@ -98,25 +264,44 @@ func (e *Executor) Execute(ctx context.Context, scriptPath string) error {
 	info, err := os.Stat(absPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return &ScriptNotFoundError{Path: filepath.Base(absPath)}
+			return invocation{}, &ScriptNotFoundError{Path: filepath.Base(absPath)}
 		}
-		return &ValidationError{Field: "scriptPath", Reason: fmt.Sprintf("cannot access: %v", err)}
+		return invocation{}, &ValidationError{Field: "scriptPath", Reason: fmt.Sprintf("cannot access: %v", err)}
 	}
 
 	if info.IsDir() {
-		return &ValidationError{Field: "scriptPath", Reason: "must be a file, not a directory"}
+		return invocation{}, &ValidationError{Field: "scriptPath", Reason: "must be a file, not a directory"}
 	}
 
 	// Auto-detect shell if not specified
-	shell := e.config.Shell
+	shell, shellSource := e.config.Shell, ShellSourceFlag
 	if shell == "" {
-		shell = shellutil.DetectShell(absPath)
+		shell, shellSource = detectFileShell(absPath)
+	}
+
+	// Use script's directory as working directory unless overridden
+	workingDir, err := e.resolveWorkingDir(filepath.Dir(absPath))
+	if err != nil {
+		return invocation{}, err
 	}
 
-	// Use script's directory as working directory
-	workingDir := filepath.Dir(absPath)
+	inv := e.newInvocation(shell, workingDir, absPath, false)
+	inv.shellSource = shellSource
+	return inv, nil
+}
 
-	return e.executeCommand(ctx, shell, workingDir, absPath, false)
+// RunFile runs a script file like Execute and returns its structured result.
+// The result is nil, and only an error is returned, if the script did not start
+// because validation or environment preparation failed. Failed runs return both.
+func (e *Executor) RunFile(ctx context.Context, scriptPath string) (*Result, error) {
+	inv, err := e.fileInvocation(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkTrust(inv); err != nil {
+		return nil, err
+	}
+	return e.executeWithResult(ctx, inv)
 }
 
 // ExecuteInline runs an inline script command with azd context.
@@ -126,85 +311,312 @@ func (e *Executor) Execute(ctx context.Context, scriptPath string) error {
 //   - shell detection fails
 //   - script execution fails
 func (e *Executor) ExecuteInline(ctx context.Context, scriptContent string) error {
+	inv, err := e.inlineInvocation(scriptContent)
+	if err != nil {
+		return err
+	}
+	return e.executeCommand(ctx, inv)
+}
+
+// RunInline runs an inline script like ExecuteInline and returns its structured result.
+// Results and errors are returned as described for RunFile.
+func (e *Executor) RunInline(ctx context.Context, scriptContent string) (*Result, error) {
+	inv, err := e.inlineInvocation(scriptContent)
+	if err != nil {
+		return nil, err
+	}
+	return e.executeWithResult(ctx, inv)
+}
+
+// inlineInvocation validates inline script content and describes how to run it.
+func (e *Executor) inlineInvocation(scriptContent string) (invocation, error) {
 	// Validate script content
 	if strings.TrimSpace(scriptContent) == "" {
-		return &ValidationError{Field: "scriptContent", Reason: "cannot be empty or whitespace"}
+		return invocation{}, &ValidationError{Field: "scriptContent", Reason: "cannot be empty or whitespace"}
 	}
 
 	// Auto-detect shell if not specified, default based on OS
-	shell := e.config.Shell
+	shell, shellSource := e.config.Shell, ShellSourceFlag
 	if shell == "" {
-		shell = getDefaultShellForOS()
+		shell, shellSource = getDefaultShellForOS(), ShellSourceDefault
+	}
+	if interp, ok := LookupInterpreter(shell); ok && !interp.SupportsInline() {
+		return invocation{}, &ValidationError{Field: "shell", Reason: fmt.Sprintf("%s cannot run inline scripts", interp.Name)}
 	}
 
-	// Use current directory as working directory
-	workingDir, err := os.Getwd()
+	// Use current directory as working directory unless overridden
+	cwd, err := os.Getwd()
+	if err != nil {
+		return invocation{}, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	workingDir, err := e.resolveWorkingDir(cwd)
 	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
+		return invocation{}, err
 	}
 
-	return e.executeCommand(ctx, shell, workingDir, scriptContent, true)
+	inv := e.newInvocation(shell, workingDir, scriptContent, true)
+	inv.shellSource = shellSource
+	return inv, nil
+}
+
+// invocation describes a single script run: what to run, where, and where its output goes.
+type invocation struct {
+	shell        string
+	shellSource  string
+	workingDir   string
+	scriptOrPath string
+	isInline     bool
+	stdout       io.Writer
+	stderr       io.Writer
+}
+
+// newInvocation returns an invocation that writes to the configured output writers.
+func (e *Executor) newInvocation(shell, workingDir, scriptOrPath string, isInline bool) invocation {
+	inv := invocation{
+		shell:        shell,
+		workingDir:   workingDir,
+		scriptOrPath: scriptOrPath,
+		isInline:     isInline,
+		stdout:       e.config.Stdout,
+		stderr:       e.config.Stderr,
+	}
+	if inv.stdout == nil {
+		inv.stdout = os.Stdout
+	}
+	if inv.stderr == nil {
+		inv.stderr = os.Stderr
+	}
+	return inv
 }
 
 // executeCommand is the common execution logic for both file and inline scripts.
-func (e *Executor) executeCommand(ctx context.Context, shell, workingDir, scriptOrPath string, isInline bool) error {
-	// Build command
-	cmd := e.buildCommand(shell, scriptOrPath, isInline)
-	cmd.Dir = workingDir
+func (e *Executor) executeCommand(ctx context.Context, inv invocation) error {
+	_, err := e.executeWithResult(ctx, inv)
+	return err
+}
 
-	// Prepare environment with Key Vault resolution
-	envVars, warnings, err := e.prepareEnvironment(ctx)
+// executeWithResult prepares the environment and runs inv, returning its result.
+// The result is nil if the script did not start because the environment could not be prepared.
+func (e *Executor) executeWithResult(ctx context.Context, inv invocation) (*Result, error) {
+	env, err := e.resolveEnvironment(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return e.run(ctx, inv, env)
+}
+
+// resolvedEnv is a script environment after Key Vault resolution.
+type resolvedEnv struct {
+	vars []string
+
+	// warningKeys names the variables whose secret references could not be resolved.
+	warningKeys []string
+
+	// secrets are the values resolved from secret references, which are masked in output
+	// unless NoMask is set.
+	secrets []string
+}
+
+// resolveEnvironment prepares the script environment and reports secret resolution warnings.
+func (e *Executor) resolveEnvironment(ctx context.Context) (resolvedEnv, error) {
+	base, scoped, err := e.baseEnvironment()
+	if err != nil {
+		return resolvedEnv{}, err
+	}
+	envVars, warnings, err := e.resolveSecretReferences(ctx, base, scoped)
+	if err != nil {
+		return resolvedEnv{}, err
+	}
+	secrets, tooShort := resolvedSecrets(base, envVars)
+	env := resolvedEnv{vars: envVars, secrets: secrets}
+	if !e.config.NoMask {
+		for _, key := range tooShort {
+			e.warn("The value of %s is shorter than %d characters and is not masked in output", key, minSecretLength)
+		}
 	}
 	for _, w := range warnings {
 		if w.Key != "" {
-			cliout.Warning("Failed to resolve Key Vault reference for %s: %v", w.Key, w.Err)
+			env.warningKeys = append(env.warningKeys, w.Key)
+			e.warn("Failed to resolve %s for %s: %v", referenceKindOf(base, w.Key), w.Key, w.Err)
 		} else {
-			cliout.Warning("%v", w.Err)
+			e.warn("%v", w.Err)
 		}
 	}
-	cmd.Env = envVars
+	return env, nil
+}
+
+// warn prints a warning to the configured Stderr writer if there is one.
+// Otherwise it uses cliout, except with JSON output, where it goes to stderr
+// so that stdout stays parseable.
+func (e *Executor) warn(format string, args ...any) {
+	switch {
+	case e.config.Stderr != nil:
+		fmt.Fprintf(e.config.Stderr, "Warning: "+format+"\n", args...)
+	case cliout.IsJSON():
+		fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+	default:
+		cliout.Warning(format, args...)
+	}
+}
+
+// notice prints an informational message where warn prints warnings.
+func (e *Executor) notice(format string, args ...any) {
+	switch {
+	case e.config.Stderr != nil:
+		fmt.Fprintf(e.config.Stderr, format+"\n", args...)
+	case cliout.IsJSON():
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	default:
+		cliout.Info(format, args...)
+	}
+}
+
+// LastResult returns the result of the most recently finished script run,
+// or nil if no script has run yet.
+func (e *Executor) LastResult() *Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastResult
+}
+
+// run starts the script described by inv with an already prepared environment.
+// The outcome is returned and also recorded as the executor's LastResult.
+func (e *Executor) run(ctx context.Context, inv invocation, env resolvedEnv) (*Result, error) {
+	// The timeout covers every attempt and the delays between retries, but not
+	// Key Vault resolution, which happened before.
+	if e.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.Timeout)
+		defer cancel()
+	}
+
+	// Build command
+	cmd := e.buildCommand(ctx, inv.shell, inv.scriptOrPath, inv.isInline)
+	cmd.Dir = inv.workingDir
+	cmd.Env = env.vars
 
 	// Set up stdio
 	if e.config.Interactive {
 		cmd.Stdin = os.Stdin
 	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	var stdout, stderr bytes.Buffer
+	terminalOut, terminalErr := inv.stdout, inv.stderr
+	if e.config.CaptureOutput {
+		terminalOut, terminalErr = &stdout, &stderr
+	}
+	var closeOutputs func() error
+	var err error
+	cmd.Stdout, cmd.Stderr, closeOutputs, err = e.openOutputs(terminalOut, terminalErr)
+	if err != nil {
+		return nil, err
+	}
+	var redactors []*redactor
+	if !e.config.NoMask {
+		if r := newRedactor(cmd.Stdout, env.secrets); r != nil {
+			cmd.Stdout = r
+			redactors = append(redactors, r)
+		}
+		if r := newRedactor(cmd.Stderr, env.secrets); r != nil {
+			cmd.Stderr = r
+			redactors = append(redactors, r)
+		}
+	}
 
 	// Add debug output
 	if os.Getenv(shellutil.EnvVarDebug) == "true" {
-		e.logDebugInfo(shell, workingDir, scriptOrPath, isInline, cmd.Args)
+		e.logDebugInfo(inv.shell, inv.workingDir, inv.scriptOrPath, inv.isInline, cmd.Args)
 	}
 
 	// Run the command
-	return e.runCommand(cmd, scriptOrPath, shell, isInline)
-}
+	result := &Result{
+		Shell:            inv.shell,
+		Command:          cmd.Args,
+		WorkingDir:       inv.workingDir,
+		KeyVaultWarnings: env.warningKeys,
+		StartTime:        time.Now(),
+	}
+	// Captured output describes the last attempt only; earlier attempts are
+	// still written to the terminal and log files as they run.
+	beforeRetry := func() {
+		for _, r := range redactors {
+			_ = r.Flush() // a failing writer fails the final flush as well
+		}
+		stdout.Reset()
+		stderr.Reset()
+	}
+	cmd, result.Attempts, err = e.runAttempts(ctx, cmd, inv, beforeRetry)
+	for _, r := range redactors {
+		if flushErr := r.Flush(); flushErr != nil && err == nil {
+			err = fmt.Errorf("failed to write script output: %w", flushErr)
+		}
+	}
+	if closeErr := closeOutputs(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	result.finish(cmd.ProcessState, err)
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
 
-// prepareEnvironment prepares environment variables with Key Vault resolution.
-func (e *Executor) prepareEnvironment(ctx context.Context) ([]string, []keyvault.KeyVaultResolutionWarning, error) {
-	envVars := os.Environ()
+	e.mu.Lock()
+	e.lastResult = result
+	e.mu.Unlock()
 
-	if !e.hasKeyVaultReferences(envVars) {
-		return envVars, nil, nil
-	}
+	return result, err
+}
 
-	resolver, err := newKeyVaultEnvResolver()
-	if err != nil {
-		if e.config.StopOnKeyVaultError {
-			return nil, nil, fmt.Errorf("failed to create Key Vault resolver: %w", err)
+// resolveWorkingDir returns the configured working directory, or defaultDir if none is set.
+// A configured directory must exist.
+func (e *Executor) resolveWorkingDir(defaultDir string) (string, error) {
+	switch e.config.WorkingDir {
+	case "", WorkingDirScript:
+		return defaultDir, nil
+	case WorkingDirProject:
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get working directory: %w", err)
+		}
+		root, err := project.FindAzdRoot(cwd)
+		if err != nil {
+			return "", &ValidationError{Field: "workingDir", Reason: err.Error()}
 		}
-		return envVars, []keyvault.KeyVaultResolutionWarning{{Err: fmt.Errorf("failed to create Key Vault resolver: %w", err)}}, nil
+		return root, nil
 	}
 
-	resolvedVars, warnings, err := resolver.ResolveEnvironmentVariables(ctx, envVars, keyvault.ResolveEnvironmentOptions{StopOnError: e.config.StopOnKeyVaultError})
+	absDir, err := filepath.Abs(e.config.WorkingDir)
 	if err != nil {
-		// Fail-fast mode returns an error and should prevent script execution.
-		return nil, warnings, fmt.Errorf("failed to resolve Key Vault references: %w", err)
+		return "", &ValidationError{Field: "workingDir", Reason: fmt.Sprintf("invalid path: %v", err)}
+	}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return "", &ValidationError{Field: "workingDir", Reason: fmt.Sprintf("cannot access %s: %v", filepath.Base(absDir), err)}
+	}
+	if !info.IsDir() {
+		return "", &ValidationError{Field: "workingDir", Reason: "must be a directory"}
 	}
+	return absDir, nil
+}
 
-	return resolvedVars, warnings, nil
+// baseEnvironment returns the environment before Key Vault resolution:
+// Environ (or the process environment), then EnvFiles in order, then
+// EnvOverrides, with the Unset variables removed. scoped holds the pairs taken
+// from AzdEnvironment, EnvFiles and EnvOverrides, in which references of every
+// provider are resolved.
+func (e *Executor) baseEnvironment() (envVars, scoped []string, err error) {
+	envVars = e.config.Environ
+	if envVars == nil {
+		envVars = os.Environ()
+	}
+	scoped = append(scoped, e.config.AzdEnvironment...)
+	for _, path := range e.config.EnvFiles {
+		fileVars, err := readEnvFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		envVars = MergeEnv(envVars, fileVars)
+		scoped = append(scoped, fileVars...)
+	}
+	envVars = MergeEnv(envVars, e.config.EnvOverrides)
+	scoped = append(scoped, e.config.EnvOverrides...)
+	return unsetEnv(envVars, e.config.Unset), scoped, nil
 }
 
 // logDebugInfo logs debug information about script execution.
@@ -219,16 +631,33 @@ func (e *Executor) logDebugInfo(shell, workingDir, scriptOrPath string, isInline
 }
 
 // runCommand executes the command and handles errors.
+// When ctx is done the script is interrupted and, after the grace period, killed.
+// Interactive scripts stay in the terminal's process group so they can read
+// from it; only the script process itself is signaled in that case.
 // Error messages are sanitized to avoid leaking sensitive path information.
-func (e *Executor) runCommand(cmd *exec.Cmd, scriptOrPath, shell string, isInline bool) error {
-	if err := cmd.Run(); err != nil {
+func (e *Executor) runCommand(ctx context.Context, cmd *exec.Cmd, scriptOrPath, shell string, isInline bool) error {
+	term := newTerminator(cmd, e.gracePeriod(), !e.config.Interactive)
+	err := cmd.Run()
+	term.stop()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return e.cancellationError(ctx, shell, isInline)
+		}
+
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
-			return &ExecutionError{
+			execErr := &ExecutionError{
 				ExitCode: exitErr.ExitCode(),
 				Shell:    shell,
 				IsInline: isInline,
 			}
+			// Report signal deaths the way shells do: 128 + signal number.
+			if sig, ok := terminatingSignal(exitErr.ProcessState); ok {
+				execErr.Signal = sig
+				execErr.ExitCode = 128 + sig
+			}
+			return execErr
 		}
 		if isInline {
 			return fmt.Errorf("failed to execute inline script with shell %q: %w", shell, err)
@@ -239,14 +668,28 @@ func (e *Executor) runCommand(cmd *exec.Cmd, scriptOrPath, shell string, isInlin
 	return nil
 }
 
-// hasKeyVaultReferences checks if any environment variables contain Key Vault references.
-func (e *Executor) hasKeyVaultReferences(envVars []string) bool {
-	for _, envVar := range envVars {
-		if parts := strings.SplitN(envVar, "=", 2); len(parts) == 2 && keyvault.IsKeyVaultReference(parts[1]) {
-			return true
-		}
+// cancellationError returns the error for a run stopped because ctx is done:
+// a *TimeoutError when the configured timeout elapsed.
+func (e *Executor) cancellationError(ctx context.Context, shell string, isInline bool) error {
+	ctxErr := ctx.Err()
+	if errors.Is(ctxErr, context.DeadlineExceeded) && e.config.Timeout > 0 {
+		return &TimeoutError{Timeout: e.config.Timeout, Shell: shell, IsInline: isInline}
 	}
-	return false
+	return fmt.Errorf("script execution cancelled: %w", ctxErr)
+}
+
+// gracePeriod returns the configured grace period or DefaultGracePeriod.
+func (e *Executor) gracePeriod() time.Duration {
+	if e.config.GracePeriod > 0 {
+		return e.config.GracePeriod
+	}
+	return DefaultGracePeriod
+}
+
+// DefaultShell returns the shell used for inline scripts, and for script files
+// whose shell cannot be detected, when no shell is configured.
+func DefaultShell() string {
+	return getDefaultShellForOS()
 }
 
 // getDefaultShellForOS returns the default shell for the current operating system.
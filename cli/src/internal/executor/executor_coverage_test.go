@@ -1,7 +1,9 @@
 package executor
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -73,7 +75,7 @@ func TestExecuteInline_Validation(t *testing.T) {
 	})
 }
 
-func TestPrepareEnvironment(t *testing.T) {
+func TestResolveEnvironment(t *testing.T) {
 	exec, err := New(Config{})
 	if err != nil {
 		t.Fatalf("New() error: %v", err)
@@ -94,12 +96,12 @@ func TestPrepareEnvironment(t *testing.T) {
 
 		_ = os.Setenv("NORMAL_VAR", "value")
 
-		envVars, _, err := exec.prepareEnvironment(context.Background())
+		resolved, err := exec.resolveEnvironment(context.Background())
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
 		// warnings are allowed but should be empty for non-KV env
-		if len(envVars) == 0 {
+		if len(resolved.vars) == 0 {
 			t.Error("Expected non-empty environment")
 		}
 	})
@@ -118,20 +120,20 @@ func TestPrepareEnvironment(t *testing.T) {
 
 		_ = os.Setenv("KV_VAR", "@Microsoft.KeyVault(VaultName=test;SecretName=secret)")
 
-		_, warnings, err := exec.prepareEnvironment(context.Background())
+		resolved, err := exec.resolveEnvironment(context.Background())
 		// Default behavior is continue-on-error; errors should be reserved for strict mode.
 		if err != nil {
 			t.Fatalf("Unexpected error in continue-on-error mode: %v", err)
 		}
-		if len(warnings) == 0 {
+		if len(resolved.warningKeys) == 0 {
 			t.Log("No warnings emitted (credentials+secret may have resolved successfully)")
 		} else {
-			t.Logf("Warnings emitted for Key Vault resolution as expected: %d", len(warnings))
+			t.Logf("Warnings emitted for Key Vault resolution as expected: %d", len(resolved.warningKeys))
 		}
 	})
 }
 
-func TestHasKeyVaultReferences(t *testing.T) {
+func TestHasSecretReferences(t *testing.T) {
 	exec, err := New(Config{})
 	if err != nil {
 		t.Fatalf("New() error: %v", err)
@@ -140,6 +142,7 @@ func TestHasKeyVaultReferences(t *testing.T) {
 	tests := []struct {
 		name    string
 		envVars []string
+		scoped  []string
 		want    bool
 	}{
 		{
@@ -157,6 +160,17 @@ func TestHasKeyVaultReferences(t *testing.T) {
 			envVars: []string{"KV=@Microsoft.KeyVault(VaultName=vault;SecretName=secret)"},
 			want:    true,
 		},
+		{
+			name:    "Inherited env reference",
+			envVars: []string{"TOKEN=env://CI_TOKEN"},
+			want:    false,
+		},
+		{
+			name:    "Scoped env reference",
+			envVars: []string{"TOKEN=env://CI_TOKEN"},
+			scoped:  []string{"TOKEN=env://CI_TOKEN"},
+			want:    true,
+		},
 		{
 			name:    "Empty environment",
 			envVars: []string{},
@@ -171,9 +185,9 @@ func TestHasKeyVaultReferences(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := exec.hasKeyVaultReferences(tt.envVars)
+			got := exec.hasSecretReferences(tt.envVars, tt.scoped)
 			if got != tt.want {
-				t.Errorf("hasKeyVaultReferences() = %v, want %v", got, tt.want)
+				t.Errorf("hasSecretReferences() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -214,30 +228,70 @@ func TestRunCommand_ErrorHandling(t *testing.T) {
 
 	t.Run("Exit code error", func(t *testing.T) {
 		// Create a command that will fail
-		cmd := exec.buildCommand(shell, exitCmd, true)
-		err := exec.runCommand(cmd, "test", shell, true)
+		cmd := exec.buildCommand(context.Background(), shell, exitCmd, true)
+		err := exec.runCommand(context.Background(), cmd, "test", shell, true)
 		if err == nil {
 			t.Error("Expected error for exit code 1")
 		}
 	})
 
 	t.Run("Inline script error formatting", func(t *testing.T) {
-		cmd := exec.buildCommand(shell, missingCmd, true)
-		err := exec.runCommand(cmd, missingCmd, shell, true)
+		cmd := exec.buildCommand(context.Background(), shell, missingCmd, true)
+		err := exec.runCommand(context.Background(), cmd, missingCmd, shell, true)
 		if err == nil {
 			t.Error("Expected error for nonexistent command")
 		}
 	})
 
 	t.Run("File script error formatting", func(t *testing.T) {
-		cmd := exec.buildCommand(shell, missingFile, false)
-		err := exec.runCommand(cmd, missingFile, shell, false)
+		cmd := exec.buildCommand(context.Background(), shell, missingFile, false)
+		err := exec.runCommand(context.Background(), cmd, missingFile, shell, false)
 		if err == nil {
 			t.Error("Expected error for nonexistent file")
 		}
 	})
 }
 
+func TestRunCommand_ExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		script     string
+		wantCode   int
+		wantSignal int
+	}{
+		{name: "Exit code is preserved", script: "exit 2", wantCode: 2},
+		{name: "SIGTERM maps to 143", script: "kill -TERM $$", wantCode: 143, wantSignal: 15},
+		{name: "SIGKILL maps to 137", script: "kill -KILL $$", wantCode: 137, wantSignal: 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.buildCommand(context.Background(), "bash", tt.script, true)
+			err := exec.runCommand(context.Background(), cmd, tt.script, "bash", true)
+
+			var execErr *ExecutionError
+			if !errors.As(err, &execErr) {
+				t.Fatalf("expected *ExecutionError, got %T: %v", err, err)
+			}
+			if execErr.ExitCode != tt.wantCode {
+				t.Errorf("ExitCode = %d, want %d", execErr.ExitCode, tt.wantCode)
+			}
+			if execErr.Signal != tt.wantSignal {
+				t.Errorf("Signal = %d, want %d", execErr.Signal, tt.wantSignal)
+			}
+		})
+	}
+}
+
 func TestExecutorWithDebugMode(t *testing.T) {
 	origDebug := os.Getenv("AZD_SCRIPT_DEBUG")
 	defer func() {
@@ -316,8 +370,8 @@ func TestExecute_DirectoryPath(t *testing.T) {
 	}
 }
 
-// TestPrepareEnvironment_StopOnKeyVaultError verifies fail-fast behavior.
-func TestPrepareEnvironment_StopOnKeyVaultError(t *testing.T) {
+// TestResolveEnvironment_StopOnKeyVaultError verifies fail-fast behavior.
+func TestResolveEnvironment_StopOnKeyVaultError(t *testing.T) {
 	origEnv := os.Environ()
 	defer func() {
 		os.Clearenv()
@@ -336,7 +390,7 @@ func TestPrepareEnvironment_StopOnKeyVaultError(t *testing.T) {
 	if err != nil {
 		t.Fatalf("New() error: %v", err)
 	}
-	_, _, err = exec.prepareEnvironment(context.Background())
+	_, err = exec.resolveEnvironment(context.Background())
 	// With StopOnKeyVaultError=true and no real Azure credentials, we expect an error
 	// (either resolver creation fails or resolution fails)
 	if err != nil {
@@ -346,8 +400,8 @@ func TestPrepareEnvironment_StopOnKeyVaultError(t *testing.T) {
 	}
 }
 
-// TestPrepareEnvironment_ResolverCreationError verifies handling when Key Vault resolver fails to initialize.
-func TestPrepareEnvironment_ResolverCreationError(t *testing.T) {
+// TestResolveEnvironment_ResolverCreationError verifies handling when Key Vault resolver fails to initialize.
+func TestResolveEnvironment_ResolverCreationError(t *testing.T) {
 	origEnv := os.Environ()
 	defer func() {
 		os.Clearenv()
@@ -370,18 +424,19 @@ func TestPrepareEnvironment_ResolverCreationError(t *testing.T) {
 	}
 
 	t.Run("continue on error mode", func(t *testing.T) {
-		exec, err := New(Config{StopOnKeyVaultError: false})
+		var stderr bytes.Buffer
+		exec, err := New(Config{StopOnKeyVaultError: false, Stderr: &stderr})
 		if err != nil {
 			t.Fatalf("New() error: %v", err)
 		}
-		envVars, warnings, err := exec.prepareEnvironment(context.Background())
+		resolved, err := exec.resolveEnvironment(context.Background())
 		if err != nil {
 			t.Fatalf("expected no error in continue mode, got: %v", err)
 		}
-		if len(warnings) == 0 {
-			t.Error("expected warnings when resolver fails")
+		if !strings.Contains(stderr.String(), "mock resolver error") {
+			t.Errorf("expected a warning when resolver fails, got %q", stderr.String())
 		}
-		if len(envVars) == 0 {
+		if len(resolved.vars) == 0 {
 			t.Error("expected fallback to original env vars")
 		}
 	})
@@ -391,7 +446,7 @@ func TestPrepareEnvironment_ResolverCreationError(t *testing.T) {
 		if err != nil {
 			t.Fatalf("New() error: %v", err)
 		}
-		_, _, err = exec.prepareEnvironment(context.Background())
+		_, err = exec.resolveEnvironment(context.Background())
 		if err == nil {
 			t.Error("expected error in stop-on-error mode")
 		}
@@ -0,0 +1,192 @@
+package executor
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jongio/azd-core/shellutil"
+)
+
+// Shell sources reported in Plan.ShellSource.
+const (
+	// ShellSourceFlag means the shell was set explicitly, e.g. with --shell.
+	ShellSourceFlag = "flag"
+	// ShellSourceExtension means the shell was chosen from the script's file extension.
+	ShellSourceExtension = "extension"
+	// ShellSourceShebang means the shell was read from the script's shebang line.
+	ShellSourceShebang = "shebang"
+	// ShellSourceDefault means the operating system's default shell was used.
+	ShellSourceDefault = "default"
+)
+
+// shellExtensions are the file extensions that select a built-in shell during
+// auto-detection. They take precedence over interpreter extensions.
+var shellExtensions = []string{".sh", ".zsh", ".ps1", ".cmd", ".bat"}
+
+// Key Vault reference formats, used to report which vault a reference targets.
+// They mirror the formats accepted by keyvault.IsKeyVaultReference, plus the
+// akv://vault/secret[/version] format of the Key Vault secret provider.
+var (
+	vaultNameRefPattern = regexp.MustCompile(`^@Microsoft\.KeyVault\(VaultName=([^;]+);SecretName=([^;)]+)(?:;SecretVersion=([^;)]+))?\)$`)
+	secretURIRefPattern = regexp.MustCompile(`^@Microsoft\.KeyVault\(SecretUri=(.+)\)$`)
+	akvsRefPattern      = regexp.MustCompile(`^akvs://[^/]+/([^/]+)/([^/]+)(?:/([^/]+))?$`)
+	akvRefPattern       = regexp.MustCompile(`^akv://([^/]+)/([^/]+)(?:/([^/]+))?$`)
+)
+
+// Plan describes how a script would be run, without running it or resolving secrets.
+// It is the JSON schema of `azd exec --dry-run --output json`.
+type Plan struct {
+	// Shell is the shell or interpreter that would run the script.
+	Shell string `json:"shell"`
+
+	// ShellSource says why Shell was chosen: flag, extension, shebang or default.
+	ShellSource string `json:"shellSource"`
+
+	// Command is the command line that would be run, starting with the executable.
+	Command []string `json:"command"`
+
+	// WorkingDir is the directory the script would run in.
+	WorkingDir string `json:"workingDir"`
+
+	// KeyVaultReferences lists the environment variables that hold Key Vault
+	// references, sorted by name. They would be resolved before the script starts.
+	KeyVaultReferences []KeyVaultReference `json:"keyVaultReferences,omitempty"`
+}
+
+// KeyVaultReference is an environment variable whose value is a Key Vault reference.
+type KeyVaultReference struct {
+	// Name is the environment variable's name.
+	Name string `json:"name"`
+
+	// Vault is the name of the Key Vault the reference points to.
+	Vault string `json:"vault"`
+
+	// Secret is the name of the referenced secret.
+	Secret string `json:"secret"`
+
+	// Version is the referenced secret version, empty for the latest version.
+	Version string `json:"version,omitempty"`
+}
+
+// ExplainFile validates a script file like Execute and returns how it would be
+// run. Nothing is run and Key Vault references are not resolved.
+func (e *Executor) ExplainFile(scriptPath string) (*Plan, error) {
+	inv, err := e.fileInvocation(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	return e.plan(inv)
+}
+
+// ExplainInline validates inline script content like ExecuteInline and returns
+// how it would be run. Nothing is run and Key Vault references are not resolved.
+func (e *Executor) ExplainInline(scriptContent string) (*Plan, error) {
+	inv, err := e.inlineInvocation(scriptContent)
+	if err != nil {
+		return nil, err
+	}
+	return e.plan(inv)
+}
+
+// plan describes inv using the same command construction as a real run.
+func (e *Executor) plan(inv invocation) (*Plan, error) {
+	envVars, _, err := e.baseEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	cmd := e.buildCommand(context.Background(), inv.shell, inv.scriptOrPath, inv.isInline)
+	return &Plan{
+		Shell:              inv.shell,
+		ShellSource:        inv.shellSource,
+		Command:            cmd.Args,
+		WorkingDir:         inv.workingDir,
+		KeyVaultReferences: keyVaultReferences(envVars),
+	}, nil
+}
+
+// detectFileShell picks the shell for a script file the way shellutil.DetectShell
+// does, extended with the extensions of registered interpreters, and also
+// reports which rule chose it.
+func detectFileShell(path string) (shell, source string) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if slices.Contains(shellExtensions, ext) {
+		return shellutil.DetectShell(path), ShellSourceExtension
+	}
+	if interp, ok := interpreterForExtension(ext); ok {
+		return interp.Name, ShellSourceExtension
+	}
+	if shebang := shellutil.ReadShebang(path); shebang != "" {
+		return shebang, ShellSourceShebang
+	}
+	return getDefaultShellForOS(), ShellSourceDefault
+}
+
+// ExtensionsForShell returns the file extensions that select the named shell or
+// interpreter during auto-detection on this operating system, sorted.
+func ExtensionsForShell(name string) []string {
+	var exts []string
+	if validShells[strings.ToLower(name)] {
+		for _, ext := range shellExtensions {
+			if shellutil.DetectShell("script"+ext) == strings.ToLower(name) {
+				exts = append(exts, ext)
+			}
+		}
+	} else if interp, ok := LookupInterpreter(name); ok {
+		for _, registered := range Interpreters() {
+			if registered.Name == interp.Name {
+				exts = registered.Extensions
+			}
+		}
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// keyVaultReferences returns the variables in envVars that hold Key Vault references.
+func keyVaultReferences(envVars []string) []KeyVaultReference {
+	var refs []KeyVaultReference
+	for _, kv := range envVars {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !(keyVaultProvider{}).Matches(value) {
+			continue
+		}
+		vault, secret, version := keyVaultTarget(value)
+		refs = append(refs, KeyVaultReference{Name: name, Vault: vault, Secret: secret, Version: version})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs
+}
+
+// keyVaultTarget returns the vault, secret and version a Key Vault reference points to.
+// The version is empty for references to the latest version. Parts that cannot
+// be determined are returned empty.
+func keyVaultTarget(reference string) (vault, secret, version string) {
+	reference = unquoteReference(reference)
+
+	if m := vaultNameRefPattern.FindStringSubmatch(reference); m != nil {
+		return m[1], m[2], m[3]
+	}
+	if m := akvsRefPattern.FindStringSubmatch(reference); m != nil {
+		return m[1], m[2], m[3]
+	}
+	if m := akvRefPattern.FindStringSubmatch(reference); m != nil {
+		return m[1], m[2], m[3]
+	}
+	if m := secretURIRefPattern.FindStringSubmatch(reference); m != nil {
+		u, err := url.Parse(strings.TrimSpace(m[1]))
+		if err != nil {
+			return "", "", ""
+		}
+		vault, _, _ = strings.Cut(u.Hostname(), ".")
+		if rest, ok := strings.CutPrefix(u.Path, "/secrets/"); ok {
+			secret, version, _ = strings.Cut(rest, "/")
+		}
+		return vault, secret, version
+	}
+	return "", "", ""
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestExplainFile_ShellSource(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	shPath := write("deploy.sh", "echo hi\n")
+	shebangPath := write("deploy", "#!/usr/bin/env zsh\necho hi\n")
+	plainPath := write("plain", "echo hi\n")
+
+	tests := []struct {
+		name       string
+		shell      string
+		path       string
+		wantShell  string
+		wantSource string
+	}{
+		{name: "flag", shell: "sh", path: shPath, wantShell: "sh", wantSource: ShellSourceFlag},
+		{name: "extension", path: shPath, wantShell: "bash", wantSource: ShellSourceExtension},
+		{name: "shebang", path: shebangPath, wantShell: "zsh", wantSource: ShellSourceShebang},
+		{name: "default", path: plainPath, wantShell: getDefaultShellForOS(), wantSource: ShellSourceDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, err := New(Config{Shell: tt.shell, Args: []string{"--env", "dev"}})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			plan, err := exec.ExplainFile(tt.path)
+			if err != nil {
+				t.Fatalf("ExplainFile() error: %v", err)
+			}
+			if plan.Shell != tt.wantShell || plan.ShellSource != tt.wantSource {
+				t.Errorf("got shell %q from %q, want %q from %q", plan.Shell, plan.ShellSource, tt.wantShell, tt.wantSource)
+			}
+			if plan.WorkingDir != dir {
+				t.Errorf("WorkingDir = %q, want %q", plan.WorkingDir, dir)
+			}
+			if n := len(plan.Command); n < 2 || plan.Command[n-2] != "--env" || plan.Command[n-1] != "dev" {
+				t.Errorf("Command = %v, want script args at the end", plan.Command)
+			}
+		})
+	}
+}
+
+func TestExtensionsForShell(t *testing.T) {
+	pwshExts, powershellExts := []string{".ps1"}, []string(nil)
+	if runtime.GOOS == osWindows {
+		pwshExts, powershellExts = nil, []string{".ps1"}
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "bash", want: []string{".sh"}},
+		{name: "CMD", want: []string{".bat", ".cmd"}},
+		{name: "pwsh", want: pwshExts},
+		{name: "powershell", want: powershellExts},
+		{name: "sh", want: nil},
+		{name: "node", want: []string{".cjs", ".js", ".mjs"}},
+		{name: "unknown", want: nil},
+	}
+	for _, tt := range tests {
+		if got := ExtensionsForShell(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtensionsForShell(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExplainFile_MissingScript(t *testing.T) {
+	exec, _ := New(Config{})
+	if _, err := exec.ExplainFile(filepath.Join(t.TempDir(), "missing.sh")); err == nil {
+		t.Fatal("expected an error for a missing script")
+	}
+}
+
+func TestExplainInline_DoesNotResolveSecrets(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	resolver := &fakeEnvResolver{}
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) { return resolver, nil }
+
+	exec, err := New(Config{
+		Shell:   "bash",
+		Environ: []string{"PATH=/usr/bin"},
+		EnvOverrides: []string{
+			"DB_PASSWORD=@Microsoft.KeyVault(VaultName=prod-kv;SecretName=db-password)",
+			"API_KEY=akvs://00000000-0000-0000-0000-000000000000/shared-kv/api-key",
+			"CERT=akv://cert-kv/signing-cert/v2",
+			"TOKEN='@Microsoft.KeyVault(SecretUri=https://uri-kv.vault.azure.net/secrets/token/abc123)'",
+			"PLAIN=value",
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	plan, err := exec.ExplainInline("echo $DB_PASSWORD")
+	if err != nil {
+		t.Fatalf("ExplainInline() error: %v", err)
+	}
+
+	if resolver.calls != 0 {
+		t.Errorf("expected no Key Vault resolution, got %d calls", resolver.calls)
+	}
+	if plan.ShellSource != ShellSourceFlag || !reflect.DeepEqual(plan.Command, []string{"bash", "-c", "echo $DB_PASSWORD"}) {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	want := []KeyVaultReference{
+		{Name: "API_KEY", Vault: "shared-kv", Secret: "api-key"},
+		{Name: "CERT", Vault: "cert-kv", Secret: "signing-cert", Version: "v2"},
+		{Name: "DB_PASSWORD", Vault: "prod-kv", Secret: "db-password"},
+		{Name: "TOKEN", Vault: "uri-kv", Secret: "token", Version: "abc123"},
+	}
+	if !reflect.DeepEqual(plan.KeyVaultReferences, want) {
+		t.Errorf("KeyVaultReferences = %+v, want %+v", plan.KeyVaultReferences, want)
+	}
+}
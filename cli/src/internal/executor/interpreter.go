@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jongio/azd-exec/cli/src/internal/project"
+)
+
+// ScriptPlaceholder marks where the script path or inline script content goes
+// in an interpreter's argument templates.
+const ScriptPlaceholder = "{script}"
+
+// Interpreter sources, reported by Interpreters and `azd exec shells`.
+const (
+	InterpreterSourceBuiltin = "built-in"
+	InterpreterSourceProject = "project"
+)
+
+// Interpreter describes how to run scripts with a program other than a shell,
+// such as python or node. Script arguments are appended after the templated arguments.
+type Interpreter struct {
+	// Name selects the interpreter, e.g. with --shell. Names are case-insensitive.
+	Name string `json:"name"`
+
+	// Command is the executable followed by any fixed arguments, e.g. ["go", "run"].
+	Command []string `json:"command"`
+
+	// InlineArgs is the argument template for inline scripts, e.g. ["-c", "{script}"].
+	// Empty means the interpreter cannot run inline scripts.
+	InlineArgs []string `json:"inlineArgs,omitempty"`
+
+	// FileArgs is the argument template for script files, e.g. ["{script}"].
+	FileArgs []string `json:"fileArgs"`
+
+	// ArgsSeparator, if set, is inserted before the script arguments when there are any,
+	// for interpreters that stop reading their own options at a marker such as "--".
+	ArgsSeparator string `json:"argsSeparator,omitempty"`
+
+	// Extensions are the file extensions, with a leading dot, that select this
+	// interpreter during auto-detection.
+	Extensions []string `json:"extensions,omitempty"`
+
+	// VersionArgs are the arguments that make the executable, Command[0], print its
+	// version. Empty means "--version".
+	VersionArgs []string `json:"versionArgs,omitempty"`
+
+	// Source says where the interpreter was defined: InterpreterSourceBuiltin or
+	// InterpreterSourceProject.
+	Source string `json:"source,omitempty"`
+}
+
+// builtinInterpreters are the interpreters known without any configuration.
+var builtinInterpreters = []Interpreter{
+	{Name: "python", Command: []string{"python"}, InlineArgs: []string{"-c", ScriptPlaceholder}, FileArgs: []string{ScriptPlaceholder}},
+	{Name: "python3", Command: []string{"python3"}, InlineArgs: []string{"-c", ScriptPlaceholder}, FileArgs: []string{ScriptPlaceholder}},
+	{Name: "node", Command: []string{"node"}, InlineArgs: []string{"-e", ScriptPlaceholder}, FileArgs: []string{ScriptPlaceholder}, Extensions: []string{".js", ".mjs", ".cjs"}},
+	// deno denies environment and network access by default; scripts run by
+	// azd exec get the same access they would have under a shell.
+	{Name: "deno", Command: []string{"deno"}, InlineArgs: []string{"eval", ScriptPlaceholder}, FileArgs: []string{"run", "--allow-all", ScriptPlaceholder}, Extensions: []string{".ts"}},
+	{Name: "bun", Command: []string{"bun"}, InlineArgs: []string{"-e", ScriptPlaceholder}, FileArgs: []string{"run", ScriptPlaceholder}},
+	{Name: "go", Command: []string{"go", "run"}, FileArgs: []string{ScriptPlaceholder}, Extensions: []string{".go"}, VersionArgs: []string{"version"}},
+	{Name: "dotnet-script", Command: []string{"dotnet", "script"}, InlineArgs: []string{"eval", ScriptPlaceholder}, FileArgs: []string{ScriptPlaceholder}, ArgsSeparator: "--", Extensions: []string{".csx"}, VersionArgs: []string{"script", "--version"}},
+}
+
+var (
+	interpretersMu sync.RWMutex
+	// interpreters maps lowercase names to registered interpreters.
+	interpreters = map[string]Interpreter{}
+	// interpreterExtensions maps lowercase extensions to interpreter names.
+	interpreterExtensions = map[string]string{}
+)
+
+func init() {
+	for _, interp := range builtinInterpreters {
+		interp.Source = InterpreterSourceBuiltin
+		if interp.Name == pythonForExtension() {
+			interp.Extensions = []string{".py"}
+		}
+		if err := RegisterInterpreter(interp); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// pythonForExtension returns the python interpreter that runs .py files:
+// python on Windows, where python3 is rarely on PATH, and python3 elsewhere,
+// where python is often missing or Python 2.
+func pythonForExtension() string {
+	if runtime.GOOS == osWindows {
+		return "python"
+	}
+	return "python3"
+}
+
+// RegisterInterpreter adds an interpreter, or replaces the one with the same name.
+// Its extensions take over from any interpreter that claimed them before.
+// Returns a *ValidationError if the interpreter is invalid or its name is a built-in shell.
+func RegisterInterpreter(interp Interpreter) error {
+	if err := interp.validate(); err != nil {
+		return err
+	}
+
+	interpretersMu.Lock()
+	defer interpretersMu.Unlock()
+
+	name := strings.ToLower(interp.Name)
+	interpreters[name] = interp
+	for _, ext := range interp.Extensions {
+		interpreterExtensions[strings.ToLower(ext)] = name
+	}
+	return nil
+}
+
+// RegisterProjectInterpreters registers the interpreter profiles declared in
+// project configuration. A profile replaces a built-in interpreter with the same name.
+// Returns a *ValidationError for the first invalid profile.
+func RegisterProjectInterpreters(cfg *project.Config) error {
+	for _, name := range cfg.InterpreterNames() {
+		profile := cfg.Interpreters[name]
+		interp := Interpreter{
+			Name:          name,
+			Command:       append([]string{profile.Executable}, profile.Args...),
+			InlineArgs:    profile.Inline,
+			FileArgs:      profile.File,
+			ArgsSeparator: profile.ArgsSeparator,
+			Extensions:    profile.Extensions,
+			Source:        InterpreterSourceProject,
+		}
+		if len(interp.FileArgs) == 0 {
+			interp.FileArgs = []string{ScriptPlaceholder}
+		}
+		if err := RegisterInterpreter(interp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LookupInterpreter returns the registered interpreter with the given name.
+func LookupInterpreter(name string) (Interpreter, bool) {
+	interpretersMu.RLock()
+	defer interpretersMu.RUnlock()
+	interp, ok := interpreters[strings.ToLower(name)]
+	return interp, ok
+}
+
+// Interpreters returns every registered interpreter, sorted by name.
+// The Extensions of each are those it currently owns for auto-detection.
+func Interpreters() []Interpreter {
+	interpretersMu.RLock()
+	defer interpretersMu.RUnlock()
+
+	list := make([]Interpreter, 0, len(interpreters))
+	for name, interp := range interpreters {
+		interp.Extensions = nil
+		for ext, owner := range interpreterExtensions {
+			if owner == name {
+				interp.Extensions = append(interp.Extensions, ext)
+			}
+		}
+		sort.Strings(interp.Extensions)
+		list = append(list, interp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// interpreterForExtension returns the interpreter that runs files with extension ext.
+func interpreterForExtension(ext string) (Interpreter, bool) {
+	interpretersMu.RLock()
+	name, ok := interpreterExtensions[strings.ToLower(ext)]
+	interpretersMu.RUnlock()
+	if !ok {
+		return Interpreter{}, false
+	}
+	return LookupInterpreter(name)
+}
+
+// IsValidShell reports whether name is a built-in shell or a registered interpreter.
+func IsValidShell(name string) bool {
+	if validShells[strings.ToLower(name)] {
+		return true
+	}
+	_, ok := LookupInterpreter(name)
+	return ok
+}
+
+// ShellNames returns the names of the built-in shells followed by the registered interpreters.
+func ShellNames() []string {
+	names := make([]string, 0, len(validShells))
+	for name := range validShells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, interp := range Interpreters() {
+		names = append(names, interp.Name)
+	}
+	return names
+}
+
+// validate checks that the interpreter can build a command line.
+func (i Interpreter) validate() error {
+	field := "interpreter"
+	if i.Name != "" {
+		field = fmt.Sprintf("interpreters.%s", i.Name)
+	}
+	switch {
+	case strings.TrimSpace(i.Name) == "" || strings.ContainsAny(i.Name, " \t/\\"):
+		return &ValidationError{Field: field, Reason: "name must be a single word"}
+	case validShells[strings.ToLower(i.Name)]:
+		return &ValidationError{Field: field, Reason: "name is a built-in shell"}
+	case len(i.Command) == 0 || strings.TrimSpace(i.Command[0]) == "":
+		return &ValidationError{Field: field, Reason: "command is required"}
+	case !hasPlaceholder(i.FileArgs):
+		return &ValidationError{Field: field, Reason: "file arguments must contain " + ScriptPlaceholder}
+	case len(i.InlineArgs) > 0 && !hasPlaceholder(i.InlineArgs):
+		return &ValidationError{Field: field, Reason: "inline arguments must contain " + ScriptPlaceholder}
+	}
+	for _, ext := range i.Extensions {
+		if len(ext) < 2 || !strings.HasPrefix(ext, ".") {
+			return &ValidationError{Field: field, Reason: fmt.Sprintf("extension %q must start with a dot", ext)}
+		}
+	}
+	return nil
+}
+
+// hasPlaceholder reports whether any argument in template contains ScriptPlaceholder.
+func hasPlaceholder(template []string) bool {
+	return slices.ContainsFunc(template, func(arg string) bool { return strings.Contains(arg, ScriptPlaceholder) })
+}
+
+// SupportsInline reports whether the interpreter can run inline scripts.
+func (i Interpreter) SupportsInline() bool {
+	return len(i.InlineArgs) > 0
+}
+
+// commandLine returns the full command line for running scriptOrPath with scriptArgs.
+func (i Interpreter) commandLine(scriptOrPath string, isInline bool, scriptArgs []string) []string {
+	template := i.FileArgs
+	if isInline {
+		template = i.InlineArgs
+	}
+
+	args := append([]string{}, i.Command...)
+	for _, arg := range template {
+		args = append(args, strings.ReplaceAll(arg, ScriptPlaceholder, scriptOrPath))
+	}
+	if len(scriptArgs) > 0 && i.ArgsSeparator != "" {
+		args = append(args, i.ArgsSeparator)
+	}
+	return append(args, scriptArgs...)
+}
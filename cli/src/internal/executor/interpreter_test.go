@@ -0,0 +1,231 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jongio/azd-exec/cli/src/internal/project"
+)
+
+// restoreInterpreters undoes interpreter registrations made by a test.
+func restoreInterpreters(t *testing.T) {
+	t.Helper()
+	interpretersMu.RLock()
+	savedInterpreters := maps.Clone(interpreters)
+	savedExtensions := maps.Clone(interpreterExtensions)
+	interpretersMu.RUnlock()
+	t.Cleanup(func() {
+		interpretersMu.Lock()
+		interpreters, interpreterExtensions = savedInterpreters, savedExtensions
+		interpretersMu.Unlock()
+	})
+}
+
+func TestBuildCommand_Interpreters(t *testing.T) {
+	tests := []struct {
+		shell    string
+		script   string
+		isInline bool
+		args     []string
+		want     []string
+	}{
+		{shell: "python3", script: "print(1)", isInline: true, args: []string{"a"}, want: []string{"python3", "-c", "print(1)", "a"}},
+		{shell: "Python", script: "main.py", want: []string{"python", "main.py"}},
+		{shell: "node", script: "console.log(1)", isInline: true, want: []string{"node", "-e", "console.log(1)"}},
+		{shell: "node", script: "main.mjs", args: []string{"--flag"}, want: []string{"node", "main.mjs", "--flag"}},
+		{shell: "deno", script: "main.ts", want: []string{"deno", "run", "--allow-all", "main.ts"}},
+		{shell: "deno", script: "console.log(1)", isInline: true, want: []string{"deno", "eval", "console.log(1)"}},
+		{shell: "bun", script: "main.ts", want: []string{"bun", "run", "main.ts"}},
+		{shell: "go", script: "main.go", args: []string{"x"}, want: []string{"go", "run", "main.go", "x"}},
+		{shell: "dotnet-script", script: "main.csx", want: []string{"dotnet", "script", "main.csx"}},
+		{shell: "dotnet-script", script: "main.csx", args: []string{"x"}, want: []string{"dotnet", "script", "main.csx", "--", "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell+" "+tt.script, func(t *testing.T) {
+			exec, err := New(Config{Shell: tt.shell, Args: tt.args})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			cmd := exec.buildCommand(context.Background(), tt.shell, tt.script, tt.isInline)
+			if !reflect.DeepEqual(cmd.Args, tt.want) {
+				t.Errorf("Args = %q, want %q", cmd.Args, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFileShell_InterpreterExtensions(t *testing.T) {
+	dir := t.TempDir()
+	for name, want := range map[string]string{
+		"app.js":     "node",
+		"app.MJS":    "node",
+		"app.ts":     "deno",
+		"main.go":    "go",
+		"build.csx":  "dotnet-script",
+		"tool.py":    pythonForExtension(),
+		"deploy.sh":  "bash",
+		"notes.text": getDefaultShellForOS(),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := detectFileShell(path); got != want {
+			t.Errorf("detectFileShell(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestInlineInvocation_InterpreterWithoutInlineSupport(t *testing.T) {
+	exec, err := New(Config{Shell: "go"})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	_, err = exec.inlineInvocation("package main")
+	if err == nil || !strings.Contains(err.Error(), "cannot run inline scripts") {
+		t.Errorf("expected inline error, got %v", err)
+	}
+}
+
+func TestRegisterInterpreter(t *testing.T) {
+	restoreInterpreters(t)
+
+	custom := Interpreter{
+		Name:       "tsx",
+		Command:    []string{"npx", "tsx"},
+		InlineArgs: []string{"--eval", ScriptPlaceholder},
+		FileArgs:   []string{ScriptPlaceholder},
+		Extensions: []string{".ts"},
+	}
+	if err := RegisterInterpreter(custom); err != nil {
+		t.Fatalf("RegisterInterpreter() error: %v", err)
+	}
+
+	if !IsValidShell("TSX") {
+		t.Error("expected a registered interpreter to be a valid shell")
+	}
+	if interp, ok := interpreterForExtension(".ts"); !ok || interp.Name != "tsx" {
+		t.Errorf("expected tsx to take over .ts, got %+v", interp)
+	}
+	for _, interp := range Interpreters() {
+		if interp.Name == "deno" && len(interp.Extensions) != 0 {
+			t.Errorf("expected deno to lose .ts, got %v", interp.Extensions)
+		}
+	}
+	if names := ShellNames(); names[0] != "bash" || !strings.Contains(strings.Join(names, ","), "tsx") {
+		t.Errorf("ShellNames() = %v", names)
+	}
+}
+
+func TestRegisterInterpreter_Invalid(t *testing.T) {
+	restoreInterpreters(t)
+
+	for _, interp := range []Interpreter{
+		{Command: []string{"x"}, FileArgs: []string{ScriptPlaceholder}},
+		{Name: "bash", Command: []string{"x"}, FileArgs: []string{ScriptPlaceholder}},
+		{Name: "x", FileArgs: []string{ScriptPlaceholder}},
+		{Name: "x", Command: []string{"x"}, FileArgs: []string{"file"}},
+		{Name: "x", Command: []string{"x"}, FileArgs: []string{ScriptPlaceholder}, InlineArgs: []string{"-e"}},
+		{Name: "x", Command: []string{"x"}, FileArgs: []string{ScriptPlaceholder}, Extensions: []string{"py"}},
+	} {
+		if err := RegisterInterpreter(interp); err == nil {
+			t.Errorf("RegisterInterpreter(%+v) should fail", interp)
+		}
+	}
+}
+
+func TestRegisterProjectInterpreters(t *testing.T) {
+	restoreInterpreters(t)
+
+	cfg := &project.Config{Interpreters: map[string]project.Interpreter{
+		"uv": {
+			Executable: "uv",
+			Args:       []string{"run", "python"},
+			Inline:     []string{"-c", ScriptPlaceholder},
+			Extensions: []string{".py"},
+		},
+		"box": {
+			Executable:    "/opt/box",
+			File:          []string{"--script", ScriptPlaceholder},
+			ArgsSeparator: "--",
+		},
+	}}
+	if err := RegisterProjectInterpreters(cfg); err != nil {
+		t.Fatalf("RegisterProjectInterpreters() error: %v", err)
+	}
+
+	if shell, source := detectFileShell(filepath.Join(t.TempDir(), "seed.py")); shell != "uv" || source != ShellSourceExtension {
+		t.Errorf("detectFileShell(seed.py) = %q, %q, want uv, %q", shell, source, ShellSourceExtension)
+	}
+	if interp, _ := LookupInterpreter("uv"); interp.Source != InterpreterSourceProject {
+		t.Errorf("Source = %q, want %q", interp.Source, InterpreterSourceProject)
+	}
+
+	tests := []struct {
+		shell    string
+		script   string
+		isInline bool
+		args     []string
+		want     []string
+	}{
+		{shell: "uv", script: "seed.py", args: []string{"-n", "5"}, want: []string{"uv", "run", "python", "seed.py", "-n", "5"}},
+		{shell: "uv", script: "print(1)", isInline: true, want: []string{"uv", "run", "python", "-c", "print(1)"}},
+		{shell: "box", script: "job.sh", args: []string{"x"}, want: []string{"/opt/box", "--script", "job.sh", "--", "x"}},
+	}
+	for _, tt := range tests {
+		exec, err := New(Config{Shell: tt.shell, Args: tt.args})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		cmd := exec.buildCommand(context.Background(), tt.shell, tt.script, tt.isInline)
+		if !reflect.DeepEqual(cmd.Args, tt.want) {
+			t.Errorf("buildCommand(%q, %q) Args = %q, want %q", tt.shell, tt.script, cmd.Args, tt.want)
+		}
+	}
+}
+
+func TestRegisterProjectInterpreters_Invalid(t *testing.T) {
+	restoreInterpreters(t)
+
+	cfg := &project.Config{Interpreters: map[string]project.Interpreter{
+		"bad": {Executable: "bad", Inline: []string{"-c"}},
+	}}
+	var validationErr *ValidationError
+	if err := RegisterProjectInterpreters(cfg); !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	if IsValidShell("bad") {
+		t.Error("invalid profile should not be registered")
+	}
+}
+
+func TestExecute_PythonScript(t *testing.T) {
+	if _, err := exec.LookPath(pythonForExtension()); err != nil {
+		t.Skipf("%s not available", pythonForExtension())
+	}
+
+	script := filepath.Join(t.TempDir(), "hello.py")
+	if err := os.WriteFile(script, []byte("import os, sys\nprint(os.environ['AZD_EXEC_TEST_VALUE'], sys.argv[1])\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	executor, err := New(Config{CaptureOutput: true, Args: []string{"arg1"}, EnvOverrides: []string{"AZD_EXEC_TEST_VALUE=from-env"}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	result, err := executor.RunFile(context.Background(), script)
+	if err != nil {
+		t.Fatalf("RunFile() error: %v", err)
+	}
+	if result.Shell != pythonForExtension() || result.Stdout != "from-env arg1\n" {
+		t.Errorf("unexpected result: shell=%q stdout=%q", result.Shell, result.Stdout)
+	}
+}
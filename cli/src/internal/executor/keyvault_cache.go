@@ -0,0 +1,293 @@
+package executor
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// keyVaultScope is the token scope of Key Vault data-plane requests.
+const keyVaultScope = "https://vault.azure.net/.default"
+
+// Key Vault cache files, stored in the cache directory.
+const (
+	secretCacheFileName = "secrets.cache"
+	secretCacheKeyName  = "secrets.key"
+)
+
+// keyVaultCacheDir returns the directory that holds the on-disk Key Vault cache.
+var keyVaultCacheDir = func() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "azd-exec", "keyvault"), nil
+}
+
+// sharedSecretCache is used by every Executor in the process, so runs started by
+// ExecuteEach, multi-environment runs and the MCP server share resolved secrets.
+var sharedSecretCache = &secretCache{}
+
+// secretCache keeps resolved Key Vault secrets in memory and in an encrypted
+// file so later runs can skip the round trip to Key Vault. It is only used when
+// a cache TTL is set. Entries are keyed by the tenant and principal that fetched
+// them and by vault, secret and version, so a value is never reused by another
+// identity.
+//
+// The file is encrypted with AES-GCM using a random key kept next to it with
+// owner-only permissions. Anyone who can read the file can also read the key,
+// so the encryption only keeps secret values out of plain-text searches; it
+// does not protect them from other processes running as the same user or from
+// backups of the whole directory.
+type secretCache struct {
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]secretCacheEntry
+}
+
+// secretCacheEntry is a cached secret value and when it stops being valid.
+type secretCacheEntry struct {
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// secretCacheKey returns the cache key of a Key Vault reference, or an empty
+// string if the reference's vault or secret cannot be determined.
+func secretCacheKey(reference string) string {
+	vault, secret, version := keyVaultTarget(reference)
+	if vault == "" || secret == "" {
+		return ""
+	}
+	return vault + "/" + secret + "/" + version
+}
+
+// keyVaultIdentity returns the tenant and principal that Key Vault requests are
+// made as, in the form "tenant/principal".
+var keyVaultIdentity = func(ctx context.Context) (string, error) {
+	cred, err := newAzureCredential()
+	if err != nil {
+		return "", err
+	}
+	token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{keyVaultScope}})
+	if err != nil {
+		return "", err
+	}
+	return tokenIdentity(token.Token)
+}
+
+// tokenIdentity returns "tenant/principal" from the tid and oid claims of a JWT
+// access token. The token is not verified; it was just issued to this process.
+func tokenIdentity(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+	var claims struct {
+		TenantID string `json:"tid"`
+		ObjectID string `json:"oid"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+	if claims.TenantID == "" || claims.ObjectID == "" {
+		return "", errors.New("access token has no tenant or principal")
+	}
+	return claims.TenantID + "/" + claims.ObjectID, nil
+}
+
+// get returns the cached value for key if it has not expired.
+func (c *secretCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.Expires) {
+		return "", false
+	}
+	return entry.Value, true
+}
+
+// put caches values, keyed by cache key, for ttl and saves the cache file.
+func (c *secretCache) put(values map[string]string, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	expires := time.Now().Add(ttl)
+	for key, value := range values {
+		c.entries[key] = secretCacheEntry{Value: value, Expires: expires}
+	}
+	return c.saveLocked()
+}
+
+// reset forgets the in-memory entries so the next lookup reads the cache file again.
+func (c *secretCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loaded = false
+	c.entries = nil
+}
+
+// loadLocked reads the cache file once. A missing, unreadable or corrupt file
+// is treated as an empty cache.
+func (c *secretCache) loadLocked() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = map[string]secretCacheEntry{}
+
+	dir, err := keyVaultCacheDir()
+	if err != nil {
+		return
+	}
+	key, err := os.ReadFile(filepath.Join(dir, secretCacheKeyName)) // #nosec G304 -- path is within the user cache directory
+	if err != nil {
+		return
+	}
+	sealed, err := os.ReadFile(filepath.Join(dir, secretCacheFileName)) // #nosec G304 -- path is within the user cache directory
+	if err != nil {
+		return
+	}
+	plain, err := openSecretCache(key, sealed)
+	if err != nil {
+		return
+	}
+	var entries map[string]secretCacheEntry
+	if err := json.Unmarshal(plain, &entries); err != nil {
+		return
+	}
+	now := time.Now()
+	for k, entry := range entries {
+		if now.Before(entry.Expires) {
+			c.entries[k] = entry
+		}
+	}
+}
+
+// saveLocked writes the unexpired entries to the cache file, creating the
+// cache directory and encryption key if needed.
+func (c *secretCache) saveLocked() error {
+	dir, err := keyVaultCacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to locate Key Vault cache directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create Key Vault cache directory: %w", err)
+	}
+	key, err := secretCacheEncryptionKey(dir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	live := make(map[string]secretCacheEntry, len(c.entries))
+	for k, entry := range c.entries {
+		if now.Before(entry.Expires) {
+			live[k] = entry
+		}
+	}
+	plain, err := json.Marshal(live)
+	if err != nil {
+		return fmt.Errorf("failed to encode Key Vault cache: %w", err)
+	}
+	sealed, err := sealSecretCache(key, plain)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so concurrent runs never read a partial file.
+	tmp, err := os.CreateTemp(dir, secretCacheFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write Key Vault cache: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(sealed); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write Key Vault cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write Key Vault cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, secretCacheFileName)); err != nil {
+		return fmt.Errorf("failed to write Key Vault cache: %w", err)
+	}
+	return nil
+}
+
+// secretCacheEncryptionKey returns the cache encryption key in dir, creating a random one if needed.
+func secretCacheEncryptionKey(dir string) ([]byte, error) {
+	path := filepath.Join(dir, secretCacheKeyName)
+	key, err := os.ReadFile(path) // #nosec G304 -- path is within the user cache directory
+	if err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read Key Vault cache key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to create Key Vault cache key: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write Key Vault cache key: %w", err)
+	}
+	return key, nil
+}
+
+// sealSecretCache encrypts plain with key, prefixing the result with its nonce.
+func sealSecretCache(key, plain []byte) ([]byte, error) {
+	gcm, err := newSecretCacheCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to encrypt Key Vault cache: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+// openSecretCache decrypts data produced by sealSecretCache.
+func openSecretCache(key, sealed []byte) ([]byte, error) {
+	gcm, err := newSecretCacheCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("truncated Key Vault cache")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newSecretCacheCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Key Vault cache key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,406 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/jongio/azd-core/keyvault"
+)
+
+// fakeReferenceResolver resolves references one at a time from a map of
+// reference to value, recording how many fetches happen and how many overlap.
+type fakeReferenceResolver struct {
+	values map[string]string
+	delay  time.Duration
+
+	mu      sync.Mutex
+	calls   map[string]int
+	running int32
+	peak    int32
+}
+
+func (f *fakeReferenceResolver) ResolveReference(_ context.Context, reference string) (string, error) {
+	n := atomic.AddInt32(&f.running, 1)
+	defer atomic.AddInt32(&f.running, -1)
+	for {
+		peak := atomic.LoadInt32(&f.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&f.peak, peak, n) {
+			break
+		}
+	}
+
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[reference]++
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+	value, ok := f.values[reference]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return value, nil
+}
+
+func (f *fakeReferenceResolver) ResolveEnvironmentVariables(context.Context, []string, keyvault.ResolveEnvironmentOptions) ([]string, []keyvault.KeyVaultResolutionWarning, error) {
+	return nil, nil, errors.New("expected references to be resolved one at a time")
+}
+
+func (f *fakeReferenceResolver) totalCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	total := 0
+	for _, n := range f.calls {
+		total += n
+	}
+	return total
+}
+
+// useFakeReferenceResolver installs resolver behind newKeyVaultEnvResolver,
+// makes Key Vault requests as the identity "tenant/alice" and points the Key
+// Vault cache at a fresh directory, which it returns.
+func useFakeReferenceResolver(t *testing.T, resolver *fakeReferenceResolver) string {
+	t.Helper()
+	oldResolver, oldDir, oldIdentity := newKeyVaultEnvResolver, keyVaultCacheDir, keyVaultIdentity
+	dir := t.TempDir()
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) { return resolver, nil }
+	keyVaultCacheDir = func() (string, error) { return dir, nil }
+	useKeyVaultIdentity("tenant/alice")
+	sharedSecretCache.reset()
+	t.Cleanup(func() {
+		newKeyVaultEnvResolver, keyVaultCacheDir, keyVaultIdentity = oldResolver, oldDir, oldIdentity
+		sharedSecretCache.reset()
+	})
+	return dir
+}
+
+// useKeyVaultIdentity makes Key Vault requests as identity, or as an unknown
+// identity if it is empty.
+func useKeyVaultIdentity(identity string) {
+	keyVaultIdentity = func(context.Context) (string, error) {
+		if identity == "" {
+			return "", errors.New("no identity")
+		}
+		return identity, nil
+	}
+}
+
+func TestResolveReferences_ConcurrentAndDeduplicated(t *testing.T) {
+	fake := &fakeReferenceResolver{values: map[string]string{}, delay: 20 * time.Millisecond}
+	var environ []string
+	for i := range 30 {
+		ref := fmt.Sprintf("@Microsoft.KeyVault(VaultName=v;SecretName=s%d)", i)
+		fake.values[ref] = fmt.Sprintf("value-%d", i)
+		environ = append(environ, fmt.Sprintf("SECRET_%d=%s", i, ref))
+	}
+	// The same secret in another format is fetched only once.
+	environ = append(environ, "SAME_AS_0=akvs://c3b3091e-400e-43a7-8ee5-e6e8cefdbebf/v/s0", "PLAIN=value")
+	useFakeReferenceResolver(t, fake)
+
+	exec, err := New(Config{Environ: environ})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil || len(resolved.warningKeys) != 0 {
+		t.Fatalf("resolveEnvironment() = %v, %v", resolved.warningKeys, err)
+	}
+
+	env := strings.Join(resolved.vars, "\n")
+	for _, want := range []string{"SECRET_0=value-0", "SECRET_29=value-29", "SAME_AS_0=value-0", "PLAIN=value"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("expected %s in environment", want)
+		}
+	}
+	if got := fake.totalCalls(); got != 30 {
+		t.Errorf("fetched %d times, want 30", got)
+	}
+	if peak := atomic.LoadInt32(&fake.peak); peak < 2 || peak > keyVaultConcurrency {
+		t.Errorf("peak concurrent fetches = %d, want between 2 and %d", peak, keyVaultConcurrency)
+	}
+}
+
+func TestResolveReferences_CacheAcrossRuns(t *testing.T) {
+	const ref = "@Microsoft.KeyVault(VaultName=v;SecretName=db;SecretVersion=abc)"
+	fake := &fakeReferenceResolver{values: map[string]string{ref: "s3cr3t-value"}}
+	dir := useFakeReferenceResolver(t, fake)
+
+	run := func(config Config) string {
+		t.Helper()
+		config.Environ = []string{"DB=" + ref}
+		exec, err := New(config)
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		resolved, err := exec.resolveEnvironment(context.Background())
+		if err != nil {
+			t.Fatalf("resolveEnvironment() error: %v", err)
+		}
+		return strings.Join(resolved.vars, "\n")
+	}
+
+	cached := Config{CacheTTL: time.Minute}
+	if env := run(cached); env != "DB=s3cr3t-value" {
+		t.Fatalf("first run env = %q", env)
+	}
+	// A new process starts with an empty memory cache and reads the file.
+	sharedSecretCache.reset()
+	if env := run(cached); env != "DB=s3cr3t-value" {
+		t.Fatalf("cached run env = %q", env)
+	}
+	if got := fake.totalCalls(); got != 1 {
+		t.Errorf("fetched %d times, want 1", got)
+	}
+
+	sealed, err := os.ReadFile(filepath.Join(dir, secretCacheFileName))
+	if err != nil {
+		t.Fatalf("expected a cache file: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("s3cr3t-value")) || bytes.Contains(sealed, []byte("db")) {
+		t.Error("cache file should be encrypted")
+	}
+
+	run(Config{NoCache: true, CacheTTL: time.Minute})
+	if got := fake.totalCalls(); got != 2 {
+		t.Errorf("--no-cache fetched %d times in total, want 2", got)
+	}
+}
+
+func TestResolveReferences_CacheOffByDefault(t *testing.T) {
+	const ref = "@Microsoft.KeyVault(VaultName=v;SecretName=s)"
+	fake := &fakeReferenceResolver{values: map[string]string{ref: "value"}}
+	dir := useFakeReferenceResolver(t, fake)
+
+	for range 2 {
+		exec, err := New(Config{Environ: []string{"S=" + ref}})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		if _, err := exec.resolveEnvironment(context.Background()); err != nil {
+			t.Fatalf("resolveEnvironment() error: %v", err)
+		}
+	}
+	if got := fake.totalCalls(); got != 2 {
+		t.Errorf("fetched %d times, want 2 without a cache TTL", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, secretCacheFileName)); !os.IsNotExist(err) {
+		t.Errorf("expected no cache file without a cache TTL, got %v", err)
+	}
+}
+
+func TestResolveReferences_CacheKeyedByIdentity(t *testing.T) {
+	const ref = "@Microsoft.KeyVault(VaultName=v;SecretName=s)"
+	fake := &fakeReferenceResolver{values: map[string]string{ref: "value"}}
+	useFakeReferenceResolver(t, fake)
+
+	run := func(identity string) {
+		t.Helper()
+		useKeyVaultIdentity(identity)
+		exec, err := New(Config{Environ: []string{"S=" + ref}, CacheTTL: time.Minute})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		if _, err := exec.resolveEnvironment(context.Background()); err != nil {
+			t.Fatalf("resolveEnvironment() error: %v", err)
+		}
+	}
+
+	run("tenant/alice")
+	run("tenant/alice")
+	if got := fake.totalCalls(); got != 1 {
+		t.Fatalf("fetched %d times, want 1", got)
+	}
+	run("tenant/bob")
+	run("other-tenant/alice")
+	if got := fake.totalCalls(); got != 3 {
+		t.Errorf("fetched %d times, want 3 when another identity runs", got)
+	}
+	// Without a known identity the cache is neither read nor written.
+	run("")
+	if got := fake.totalCalls(); got != 4 {
+		t.Errorf("fetched %d times, want 4 when the identity is unknown", got)
+	}
+}
+
+func TestTokenIdentity(t *testing.T) {
+	jwt := func(claims string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+	}
+	tests := []struct {
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{token: jwt(`{"tid":"t1","oid":"o1","aud":"https://vault.azure.net"}`), want: "t1/o1"},
+		{token: jwt(`{"tid":"t1"}`), wantErr: true},
+		{token: jwt(`not json`), wantErr: true},
+		{token: "opaque-token", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tokenIdentity(tt.token)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("tokenIdentity(%q) = %q, %v; want %q, error %v", tt.token, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveReferences_CacheExpires(t *testing.T) {
+	const ref = "@Microsoft.KeyVault(VaultName=v;SecretName=s)"
+	fake := &fakeReferenceResolver{values: map[string]string{ref: "value"}}
+	useFakeReferenceResolver(t, fake)
+
+	for range 2 {
+		exec, err := New(Config{Environ: []string{"S=" + ref}, CacheTTL: time.Nanosecond})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		if _, err := exec.resolveEnvironment(context.Background()); err != nil {
+			t.Fatalf("resolveEnvironment() error: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := fake.totalCalls(); got != 2 {
+		t.Errorf("fetched %d times, want 2 after the cache expired", got)
+	}
+}
+
+func TestResolveReferences_Failures(t *testing.T) {
+	const good = "@Microsoft.KeyVault(VaultName=v;SecretName=good)"
+	environ := []string{
+		"GOOD=" + good,
+		"BAD=@Microsoft.KeyVault(VaultName=v;SecretName=missing)",
+		"AFTER=@Microsoft.KeyVault(VaultName=v;SecretName=missing2)",
+	}
+
+	t.Run("continue", func(t *testing.T) {
+		useFakeReferenceResolver(t, &fakeReferenceResolver{values: map[string]string{good: "ok"}})
+		exec, _ := New(Config{Environ: environ})
+		resolved, err := exec.resolveEnvironment(context.Background())
+		if err != nil {
+			t.Fatalf("resolveEnvironment() error: %v", err)
+		}
+		if len(resolved.warningKeys) != 2 || resolved.warningKeys[0] != "BAD" || resolved.warningKeys[1] != "AFTER" {
+			t.Errorf("warning keys = %v, want BAD then AFTER", resolved.warningKeys)
+		}
+		if resolved.vars[0] != "GOOD=ok" || resolved.vars[1] != environ[1] {
+			t.Errorf("resolved.vars = %v", resolved.vars)
+		}
+	})
+
+	t.Run("stop on error", func(t *testing.T) {
+		useFakeReferenceResolver(t, &fakeReferenceResolver{values: map[string]string{good: "ok"}})
+		exec, _ := New(Config{Environ: environ, StopOnKeyVaultError: true})
+		resolved, err := exec.resolveEnvironment(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "for BAD") {
+			t.Fatalf("expected failure for BAD, got %v", err)
+		}
+		if resolved.vars != nil {
+			t.Errorf("got %v, want no variables", resolved.vars)
+		}
+	})
+}
+
+func TestSecretCacheKey(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      string
+	}{
+		{reference: "@Microsoft.KeyVault(VaultName=kv;SecretName=db)", want: "kv/db/"},
+		{reference: "@Microsoft.KeyVault(VaultName=kv;SecretName=db;SecretVersion=v1)", want: "kv/db/v1"},
+		{reference: "@Microsoft.KeyVault(SecretUri=https://kv.vault.azure.net/secrets/db/v1)", want: "kv/db/v1"},
+		{reference: "'akvs://c3b3091e-400e-43a7-8ee5-e6e8cefdbebf/kv/db'", want: "kv/db/"},
+		{reference: "plain", want: ""},
+	}
+	for _, tt := range tests {
+		if got := secretCacheKey(tt.reference); got != tt.want {
+			t.Errorf("secretCacheKey(%q) = %q, want %q", tt.reference, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSecretReferences(t *testing.T) {
+	const good = "@Microsoft.KeyVault(VaultName=kv;SecretName=good)"
+	fake := &fakeReferenceResolver{values: map[string]string{good: "value"}}
+	useFakeReferenceResolver(t, fake)
+
+	exec, err := New(Config{Environ: []string{
+		"GOOD=" + good,
+		"MISSING=@Microsoft.KeyVault(VaultName=kv;SecretName=missing;SecretVersion=v2)",
+		"PLAIN=value",
+	}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	refs, err := exec.SecretReferences()
+	if err != nil || len(refs) != 2 || refs[1].Name != "MISSING" || refs[1].Version != "v2" {
+		t.Fatalf("SecretReferences() = %+v, %v", refs, err)
+	}
+
+	// Checks ignore the cache, so a second check fetches again.
+	for range 2 {
+		checks, err := exec.CheckSecretReferences(context.Background())
+		if err != nil {
+			t.Fatalf("CheckSecretReferences() error: %v", err)
+		}
+		if len(checks) != 2 || !checks[0].Resolved || checks[0].Name != "GOOD" {
+			t.Fatalf("checks = %+v", checks)
+		}
+		if checks[1].Resolved || checks[1].ErrorClass != SecretErrorOther || checks[1].Error == "" {
+			t.Errorf("MISSING check = %+v, want a failure", checks[1])
+		}
+	}
+	if got := fake.totalCalls(); got != 4 {
+		t.Errorf("fetched %d times, want 4", got)
+	}
+}
+
+func TestCheckSecretReferences_ResolverUnavailable(t *testing.T) {
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) { return nil, errors.New("no credential") }
+
+	exec, _ := New(Config{Environ: []string{"S=@Microsoft.KeyVault(VaultName=kv;SecretName=s)"}})
+	checks, err := exec.CheckSecretReferences(context.Background())
+	if err != nil {
+		t.Fatalf("CheckSecretReferences() error: %v", err)
+	}
+	if len(checks) != 1 || checks[0].Resolved || checks[0].ErrorClass != SecretErrorAuthentication {
+		t.Errorf("checks = %+v, want an authentication failure", checks)
+	}
+}
+
+func TestClassifySecretError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: fmt.Errorf("failed to get secret from Key Vault: %w", &azcore.ResponseError{StatusCode: http.StatusNotFound}), want: SecretErrorNotFound},
+		{err: fmt.Errorf("failed to get secret from Key Vault: %w", &azcore.ResponseError{StatusCode: http.StatusForbidden}), want: SecretErrorForbidden},
+		{err: &azcore.ResponseError{StatusCode: http.StatusUnauthorized}, want: SecretErrorAuthentication},
+		{err: &azcore.ResponseError{StatusCode: http.StatusInternalServerError}, want: SecretErrorOther},
+		{err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded), want: SecretErrorTimeout},
+		{err: errors.New("DefaultAzureCredential: failed to acquire a token"), want: SecretErrorAuthentication},
+		{err: errors.New("vault name must be 3-24 characters, got 1"), want: SecretErrorOther},
+		{err: fmt.Errorf("cannot read dev.env: %w", os.ErrNotExist), want: SecretErrorNotFound},
+	}
+	for _, tt := range tests {
+		if got := ClassifySecretError(tt.err); got != tt.want {
+			t.Errorf("ClassifySecretError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jongio/azd-core/keyvault"
+	"github.com/jongio/azd-core/shellutil"
+)
+
+// keyVaultScheme is the scheme of akv://vault/secret[/version] references. The
+// Key Vault provider also resolves the @Microsoft.KeyVault(...) and akvs:// formats.
+const keyVaultScheme = "akv"
+
+// keyVaultConcurrency is the maximum number of Key Vault secrets fetched at the same time.
+const keyVaultConcurrency = secretConcurrency
+
+// keyVaultReferenceResolver resolves a single Key Vault reference. When the
+// resolver returned by newKeyVaultEnvResolver implements it, references are
+// fetched concurrently and cached instead of one after another.
+type keyVaultReferenceResolver interface {
+	ResolveReference(ctx context.Context, reference string) (string, error)
+}
+
+// keyVaultProvider is the secret provider for Azure Key Vault references.
+type keyVaultProvider struct{}
+
+func (keyVaultProvider) Scheme() string { return keyVaultScheme }
+
+func (keyVaultProvider) Matches(value string) bool {
+	return keyvault.IsKeyVaultReference(value) || akvRefPattern.MatchString(unquoteReference(value))
+}
+
+// Resolve resolves references with a resolver from newKeyVaultEnvResolver.
+// Resolvers that can fetch single references get concurrent fetching and caching.
+func (keyVaultProvider) Resolve(ctx context.Context, references []string, opts SecretResolveOptions) ([]SecretResult, error) {
+	resolver, err := newKeyVaultEnvResolver()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Key Vault resolver: %w", err)
+	}
+
+	// The resolvers only understand the azd-core formats.
+	converted := make([]string, len(references))
+	for i, ref := range references {
+		converted[i] = toKeyVaultReference(ref)
+	}
+
+	if refResolver, ok := resolver.(keyVaultReferenceResolver); ok {
+		return resolveKeyVaultReferences(ctx, refResolver, converted, opts), nil
+	}
+	return resolveKeyVaultEnvironment(ctx, resolver, converted)
+}
+
+// toKeyVaultReference rewrites an akv://vault/secret[/version] reference in the
+// @Microsoft.KeyVault(...) format. Other references are returned unchanged.
+func toKeyVaultReference(reference string) string {
+	m := akvRefPattern.FindStringSubmatch(unquoteReference(reference))
+	if m == nil {
+		return reference
+	}
+	converted := "@Microsoft.KeyVault(VaultName=" + m[1] + ";SecretName=" + m[2]
+	if m[3] != "" {
+		converted += ";SecretVersion=" + m[3]
+	}
+	return converted + ")"
+}
+
+// secretFetch is the resolution of one distinct Key Vault secret.
+type secretFetch struct {
+	reference string
+	cacheKey  string
+	value     string
+	err       error
+	cached    bool
+}
+
+// resolveKeyVaultReferences fetches each distinct secret among references only
+// once, up to keyVaultConcurrency at a time. Cached values are reused when a
+// CacheTTL is set, NoCache is not, and the caller's identity can be determined.
+func resolveKeyVaultReferences(ctx context.Context, resolver keyVaultReferenceResolver, references []string, opts SecretResolveOptions) []SecretResult {
+	identity := ""
+	if !opts.NoCache && opts.CacheTTL > 0 {
+		var err error
+		if identity, err = keyVaultIdentity(ctx); err != nil && os.Getenv(shellutil.EnvVarDebug) == "true" {
+			fmt.Fprintf(os.Stderr, "Key Vault cache not used: %v\n", err)
+		}
+	}
+	useCache := identity != ""
+
+	fetches := map[string]*secretFetch{}
+	var pending []*secretFetch
+	for _, ref := range references {
+		id := secretFetchID(ref)
+		if _, seen := fetches[id]; seen {
+			continue
+		}
+		f := &secretFetch{reference: ref}
+		if key := secretCacheKey(ref); key != "" && useCache {
+			f.cacheKey = identity + "/" + key
+		}
+		fetches[id] = f
+		if f.cacheKey != "" {
+			if f.value, f.cached = sharedSecretCache.get(f.cacheKey); f.cached {
+				continue
+			}
+		}
+		pending = append(pending, f)
+	}
+
+	sem := make(chan struct{}, keyVaultConcurrency)
+	var wg sync.WaitGroup
+	for _, f := range pending {
+		wg.Add(1)
+		go func(f *secretFetch) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				f.err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			f.value, f.err = resolver.ResolveReference(ctx, f.reference)
+		}(f)
+	}
+	wg.Wait()
+
+	if useCache && ctx.Err() == nil {
+		fresh := map[string]string{}
+		for _, f := range pending {
+			if f.err == nil && f.cacheKey != "" {
+				fresh[f.cacheKey] = f.value
+			}
+		}
+		if err := sharedSecretCache.put(fresh, opts.CacheTTL); err != nil && os.Getenv(shellutil.EnvVarDebug) == "true" {
+			fmt.Fprintf(os.Stderr, "Key Vault cache not saved: %v\n", err)
+		}
+	}
+
+	results := make([]SecretResult, len(references))
+	for i, ref := range references {
+		f := fetches[secretFetchID(ref)]
+		results[i] = SecretResult{Value: f.value, Err: f.err}
+	}
+	return results
+}
+
+// resolveKeyVaultEnvironment resolves references with a resolver that can only
+// resolve whole environments, one reference after another and without caching.
+func resolveKeyVaultEnvironment(ctx context.Context, resolver keyVaultEnvResolver, references []string) ([]SecretResult, error) {
+	envVars := make([]string, len(references))
+	for i, ref := range references {
+		envVars[i] = fmt.Sprintf("REF_%d=%s", i, ref)
+	}
+	resolved, warnings, err := resolver.ResolveEnvironmentVariables(ctx, envVars, keyvault.ResolveEnvironmentOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve Key Vault references: %w", err)
+	}
+
+	results := make([]SecretResult, len(references))
+	for _, w := range warnings {
+		var i int
+		if _, err := fmt.Sscanf(w.Key, "REF_%d", &i); err == nil && i >= 0 && i < len(results) {
+			results[i].Err = w.Err
+		}
+	}
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		j := indexEnv(resolved, fmt.Sprintf("REF_%d", i))
+		if j < 0 {
+			results[i].Err = errors.New("no value returned by the Key Vault resolver")
+			continue
+		}
+		_, results[i].Value, _ = strings.Cut(resolved[j], "=")
+	}
+	return results, nil
+}
+
+// secretFetchID identifies the secret a reference fetches, so that references
+// in different formats to the same secret are fetched once.
+func secretFetchID(reference string) string {
+	if key := secretCacheKey(reference); key != "" {
+		return key
+	}
+	return strings.TrimSpace(reference)
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPendingLine is how much of an unterminated line a lineWriter buffers
+// before writing it out anyway, so output without newlines cannot grow unbounded.
+const maxPendingLine = 64 << 10
+
+// lineWriter writes complete lines to w, passing each through format first.
+// Partial lines are held until their newline arrives, and every line is written
+// while holding mu, so lineWriters that share mu never interleave mid-line.
+type lineWriter struct {
+	w       io.Writer
+	mu      *sync.Mutex
+	format  func(line []byte) []byte
+	pending []byte
+}
+
+// Write buffers b and writes out every complete line it contains.
+func (l *lineWriter) Write(b []byte) (int, error) {
+	l.pending = append(l.pending, b...)
+	for {
+		i := bytes.IndexByte(l.pending, '\n')
+		if i < 0 {
+			break
+		}
+		if err := l.writeLine(l.pending[:i+1]); err != nil {
+			return len(b), err
+		}
+		l.pending = l.pending[i+1:]
+	}
+	if len(l.pending) >= maxPendingLine {
+		if err := l.Flush(); err != nil {
+			return len(b), err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes any buffered partial line, terminated with a newline.
+func (l *lineWriter) Flush() error {
+	if len(l.pending) == 0 {
+		return nil
+	}
+	line := append(l.pending, '\n')
+	l.pending = nil
+	return l.writeLine(line)
+}
+
+func (l *lineWriter) writeLine(line []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.w.Write(l.format(line))
+	return err
+}
+
+// PrefixWriter writes complete lines to an underlying writer, each prefixed with "[label] ".
+// Partial lines are held until their newline arrives so that output from several
+// concurrent scripts stays readable.
+type PrefixWriter struct {
+	lineWriter
+}
+
+// NewPrefixWriter returns a PrefixWriter that writes lines labeled with label to w.
+// Writers that share mu never interleave mid-line. Call Flush once writing is done.
+func NewPrefixWriter(w io.Writer, label string, mu *sync.Mutex) *PrefixWriter {
+	prefix := []byte("[" + label + "] ")
+	return &PrefixWriter{lineWriter{w: w, mu: mu, format: func(line []byte) []byte {
+		buf := make([]byte, 0, len(prefix)+len(line))
+		buf = append(buf, prefix...)
+		return append(buf, line...)
+	}}}
+}
@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter_PrefixesCompleteLines(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := NewPrefixWriter(&out, "build.sh", &mu)
+
+	// Lines split across writes are only emitted once complete.
+	for _, chunk := range []string{"hel", "lo\nwor", "ld\n", "tail"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+	if got, want := out.String(), "[build.sh] hello\n[build.sh] world\n"; got != want {
+		t.Fatalf("before Flush got %q, want %q", got, want)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if got, want := out.String(), "[build.sh] hello\n[build.sh] world\n[build.sh] tail\n"; got != want {
+		t.Errorf("after Flush got %q, want %q", got, want)
+	}
+}
+
+func TestPrefixWriter_FlushesLongPartialLines(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := NewPrefixWriter(&out, "x", &mu)
+
+	if _, err := w.Write(bytes.Repeat([]byte("a"), maxPendingLine)); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "[x] aaa") {
+		t.Error("expected an oversized partial line to be written without waiting for a newline")
+	}
+}
@@ -0,0 +1,116 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// logTimestampFormat is the timestamp prefixed to log file lines when Timestamps is set.
+const logTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// hasLogFiles reports whether any output is written to files.
+func (c *Config) hasLogFiles() bool {
+	return c.LogFile != "" || c.StdoutFile != "" || c.StderrFile != ""
+}
+
+// openOutputs returns the writers for a script's stdout and stderr. Output goes
+// to the given terminal writers and to any configured log files; a stream that is
+// written to a file only reaches the terminal in Tee mode.
+// The returned function flushes and closes the files.
+func (e *Executor) openOutputs(terminalOut, terminalErr io.Writer) (io.Writer, io.Writer, func() error, error) {
+	if !e.config.hasLogFiles() {
+		return terminalOut, terminalErr, func() error { return nil }, nil
+	}
+
+	format := logLineFormat(e.config.Timestamps)
+	var (
+		files   []*os.File
+		writers []*lineWriter
+	)
+	closeAll := func() error {
+		var firstErr error
+		for _, w := range writers {
+			if err := w.Flush(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to write log file: %w", err)
+			}
+		}
+		for _, f := range files {
+			if err := f.Close(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to close log file: %w", err)
+			}
+		}
+		return firstErr
+	}
+	// open returns count line writers that share one log file.
+	open := func(path string, count int) ([]io.Writer, error) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) // #nosec G304 -- log path is chosen by the user
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		files = append(files, f)
+		mu := &sync.Mutex{}
+		shared := make([]io.Writer, count)
+		for i := range shared {
+			w := &lineWriter{w: f, mu: mu, format: format}
+			writers = append(writers, w)
+			shared[i] = w
+		}
+		return shared, nil
+	}
+
+	var stdouts, stderrs []io.Writer
+	if e.config.LogFile != "" {
+		shared, err := open(e.config.LogFile, 2)
+		if err != nil {
+			_ = closeAll()
+			return nil, nil, nil, err
+		}
+		stdouts = append(stdouts, shared[0])
+		stderrs = append(stderrs, shared[1])
+	}
+	if e.config.StdoutFile != "" {
+		w, err := open(e.config.StdoutFile, 1)
+		if err != nil {
+			_ = closeAll()
+			return nil, nil, nil, err
+		}
+		stdouts = append(stdouts, w[0])
+	}
+	if e.config.StderrFile != "" {
+		w, err := open(e.config.StderrFile, 1)
+		if err != nil {
+			_ = closeAll()
+			return nil, nil, nil, err
+		}
+		stderrs = append(stderrs, w[0])
+	}
+
+	if e.config.Tee || len(stdouts) == 0 {
+		stdouts = append([]io.Writer{terminalOut}, stdouts...)
+	}
+	if e.config.Tee || len(stderrs) == 0 {
+		stderrs = append([]io.Writer{terminalErr}, stderrs...)
+	}
+	return combineWriters(stdouts), combineWriters(stderrs), closeAll, nil
+}
+
+func combineWriters(writers []io.Writer) io.Writer {
+	if len(writers) == 1 {
+		return writers[0]
+	}
+	return io.MultiWriter(writers...)
+}
+
+// logLineFormat returns the line format for log files, which optionally adds a timestamp prefix.
+func logLineFormat(timestamps bool) func([]byte) []byte {
+	return func(line []byte) []byte {
+		if !timestamps {
+			return line
+		}
+		stamp := time.Now().Format(logTimestampFormat) + " "
+		return append([]byte(stamp), line...)
+	}
+}
@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path) // #nosec G304 -- test file path
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestOpenOutputs(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "run.log")
+	errPath := filepath.Join(dir, "err.log")
+
+	tests := []struct {
+		name           string
+		config         Config
+		wantTerminal   string
+		wantLog        string
+		wantStderrFile string
+	}{
+		{
+			name:         "no files",
+			config:       Config{},
+			wantTerminal: "out s3cret\nerr\n",
+		},
+		{
+			name:           "files without tee hide file streams",
+			config:         Config{LogFile: logPath, StderrFile: errPath},
+			wantLog:        "out s3cret\nerr\n",
+			wantStderrFile: "err\n",
+		},
+		{
+			name:         "stderr file only keeps stdout on terminal",
+			config:       Config{StderrFile: errPath},
+			wantTerminal: "out s3cret\n",
+			// stderr goes only to the file
+			wantStderrFile: "err\n",
+		},
+		{
+			name:         "tee keeps terminal output",
+			config:       Config{LogFile: logPath, Tee: true},
+			wantTerminal: "out s3cret\nerr\n",
+			wantLog:      "out s3cret\nerr\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(logPath)
+			_ = os.Remove(errPath)
+			exec := &Executor{config: tt.config}
+
+			var terminal bytes.Buffer
+			stdout, stderr, closeFn, err := exec.openOutputs(&terminal, &terminal)
+			if err != nil {
+				t.Fatalf("openOutputs() error: %v", err)
+			}
+			_, _ = stdout.Write([]byte("out s3"))
+			_, _ = stdout.Write([]byte("cret\n"))
+			_, _ = stderr.Write([]byte("err\n"))
+			if err := closeFn(); err != nil {
+				t.Fatalf("close error: %v", err)
+			}
+
+			if terminal.String() != tt.wantTerminal {
+				t.Errorf("terminal = %q, want %q", terminal.String(), tt.wantTerminal)
+			}
+			if tt.wantLog != "" {
+				if got := readFile(t, logPath); got != tt.wantLog {
+					t.Errorf("log file = %q, want %q", got, tt.wantLog)
+				}
+			}
+			if tt.wantStderrFile != "" {
+				if got := readFile(t, errPath); got != tt.wantStderrFile {
+					t.Errorf("stderr file = %q, want %q", got, tt.wantStderrFile)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenOutputs_TimestampsAndAppend(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	if err := os.WriteFile(logPath, []byte("previous\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	exec := &Executor{config: Config{LogFile: logPath, Timestamps: true}}
+	stdout, _, closeFn, err := exec.openOutputs(os.Stdout, os.Stderr)
+	if err != nil {
+		t.Fatalf("openOutputs() error: %v", err)
+	}
+	_, _ = stdout.Write([]byte("hello\nunterminated"))
+	if err := closeFn(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(readFile(t, logPath), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "previous" {
+		t.Fatalf("expected previous content to be kept and two new lines, got %q", lines)
+	}
+	stamped := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}\S* `)
+	for _, line := range lines[1:] {
+		if !stamped.MatchString(line) {
+			t.Errorf("expected timestamp prefix, got %q", line)
+		}
+	}
+	if !strings.HasSuffix(lines[2], " unterminated") {
+		t.Errorf("expected partial line to be flushed on close, got %q", lines[2])
+	}
+}
+
+func TestOpenOutputs_OpenError(t *testing.T) {
+	exec := &Executor{config: Config{LogFile: filepath.Join(t.TempDir(), "missing", "run.log")}}
+	if _, _, _, err := exec.openOutputs(os.Stdout, os.Stderr); err == nil {
+		t.Fatal("expected an error for a log file in a missing directory")
+	}
+}
+
+func TestConfigValidate_TeeRequiresFile(t *testing.T) {
+	for _, cfg := range []Config{{Tee: true}, {Timestamps: true}} {
+		var validationErr *ValidationError
+		if err := cfg.Validate(); !errors.As(err, &validationErr) {
+			t.Errorf("expected ValidationError for %+v, got %v", cfg, err)
+		}
+	}
+	if err := (&Config{Tee: true, StdoutFile: "out.log"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResolvedSecrets(t *testing.T) {
+	ref := "@Microsoft.KeyVault(VaultName=v;SecretName=s)"
+	unresolved := []string{"PLAIN=value", "SECRET=" + ref, "UNRESOLVED=" + ref}
+	resolved := []string{"PLAIN=value", "SECRET=hunter2", "UNRESOLVED=" + ref}
+
+	got, tooShort := resolvedSecrets(unresolved, resolved)
+	if len(got) != 1 || got[0] != "hunter2" || len(tooShort) != 0 {
+		t.Errorf("resolvedSecrets() = %v, %v; want [hunter2]", got, tooShort)
+	}
+
+	unresolved = append(unresolved, "FLAG="+ref, "REGION="+ref)
+	resolved = append(resolved, "FLAG=1", "REGION=west")
+	got, tooShort = resolvedSecrets(unresolved, resolved)
+	if !reflect.DeepEqual(got, []string{"hunter2", "west"}) || !reflect.DeepEqual(tooShort, []string{"FLAG"}) {
+		t.Errorf("resolvedSecrets() = %v, %v; want [hunter2 west], [FLAG]", got, tooShort)
+	}
+}
+
+func TestExecute_LogFileMasksResolvedSecrets(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) {
+		return &fakeEnvResolver{values: map[string]string{"@Microsoft.KeyVault(VaultName=v;SecretName=s)": "hunter2"}}, nil
+	}
+
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	exec, err := New(Config{
+		Shell:        "bash",
+		LogFile:      logPath,
+		EnvOverrides: []string{"AZD_EXEC_TEST_SECRET=@Microsoft.KeyVault(VaultName=v;SecretName=s)"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err := exec.ExecuteInline(context.Background(), `echo "password=$AZD_EXEC_TEST_SECRET"; echo done >&2`); err != nil {
+		t.Fatalf("ExecuteInline() error: %v", err)
+	}
+	// stdout and stderr are copied concurrently, so only the set of lines is deterministic.
+	got := readFile(t, logPath)
+	for _, want := range []string{"password=***\n", "done\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log file = %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "hunter2") {
+		t.Error("resolved secret leaked into the log file")
+	}
+}
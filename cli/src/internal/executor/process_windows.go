@@ -0,0 +1,34 @@
+//go:build windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// setProcessGroup starts the command in a new console process group so that
+// a CTRL_BREAK event can be delivered to the script and its children.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// interruptProcessGroup sends CTRL_BREAK to the console process group led by p.
+func interruptProcessGroup(p *os.Process) error {
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(p.Pid)) // #nosec G115 -- PIDs fit in uint32
+}
+
+// killProcessGroup terminates p. Windows has no process-group kill without
+// job objects, so children that ignored CTRL_BREAK may outlive the script.
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+// terminatingSignal always reports false: Windows processes end with an exit
+// code, never a signal.
+func terminatingSignal(_ *os.ProcessState) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// maskText replaces secret values in masked output.
+const maskText = "***"
+
+// minSecretLength is the length below which secret values are not masked:
+// values such as "1" or "true" would mask unrelated output all over.
+const minSecretLength = 4
+
+// redactor is a streaming writer that replaces secret values with maskText
+// before passing output on to w. A secret split across writes is still masked:
+// a trailing part of the output that could be the start of a secret is held
+// back until the next write (or Flush) shows whether it is one.
+type redactor struct {
+	w       io.Writer
+	secrets [][]byte // longest first
+	pending []byte
+}
+
+// newRedactor returns a writer that masks secrets, and their base64 and
+// URL-encoded forms, in everything written to w. It returns nil if there is
+// nothing to mask.
+func newRedactor(w io.Writer, secrets []string) *redactor {
+	patterns := secretPatterns(secrets)
+	if len(patterns) == 0 {
+		return nil
+	}
+	return &redactor{w: w, secrets: patterns}
+}
+
+// secretPatterns returns the byte patterns to mask for secrets, longest first.
+// Blank and short values are ignored because masking them would garble output.
+func secretPatterns(secrets []string) [][]byte {
+	seen := map[string]bool{}
+	var patterns [][]byte
+	add := func(v string) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			patterns = append(patterns, []byte(v))
+		}
+	}
+	for _, s := range secrets {
+		if !isMaskable(s) {
+			continue
+		}
+		add(s)
+		add(base64.StdEncoding.EncodeToString([]byte(s)))
+		add(base64.RawStdEncoding.EncodeToString([]byte(s)))
+		add(base64.URLEncoding.EncodeToString([]byte(s)))
+		add(base64.RawURLEncoding.EncodeToString([]byte(s)))
+		add(url.QueryEscape(s))
+		add(url.PathEscape(s))
+	}
+	sort.SliceStable(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	return patterns
+}
+
+// isMaskable reports whether a secret value is long enough to be masked.
+func isMaskable(value string) bool {
+	return len(strings.TrimSpace(value)) >= minSecretLength
+}
+
+// Write masks secrets in b and writes everything that can no longer be part of a secret.
+func (r *redactor) Write(b []byte) (int, error) {
+	r.pending = append(r.pending, b...)
+	hold := r.heldSuffix()
+	out, rest := r.mask(r.pending, len(r.pending)-hold)
+	r.pending = append(r.pending[:0], rest...)
+	if len(out) > 0 {
+		if _, err := r.w.Write(out); err != nil {
+			return len(b), err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush masks and writes any held-back output.
+func (r *redactor) Flush() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	out, _ := r.mask(r.pending, len(r.pending))
+	r.pending = r.pending[:0]
+	_, err := r.w.Write(out)
+	return err
+}
+
+// mask replaces every secret that starts before limit and returns the masked
+// output along with the unprocessed remainder of buf.
+func (r *redactor) mask(buf []byte, limit int) (out, rest []byte) {
+	out = make([]byte, 0, len(buf))
+	i := 0
+	for i < limit {
+		start, length := r.nextMatch(buf, i)
+		if start < 0 || start >= limit {
+			out = append(out, buf[i:limit]...)
+			i = limit
+			break
+		}
+		out = append(out, buf[i:start]...)
+		out = append(out, maskText...)
+		i = start + length
+	}
+	return out, buf[i:]
+}
+
+// nextMatch returns the position and length of the earliest secret in buf at or
+// after from, preferring the longest secret at that position, or -1 if there is none.
+func (r *redactor) nextMatch(buf []byte, from int) (int, int) {
+	start, length := -1, 0
+	for _, s := range r.secrets {
+		idx := bytes.Index(buf[from:], s)
+		if idx < 0 {
+			continue
+		}
+		idx += from
+		if start < 0 || idx < start || (idx == start && len(s) > length) {
+			start, length = idx, len(s)
+		}
+	}
+	return start, length
+}
+
+// heldSuffix returns the length of the longest suffix of the pending output that
+// is a proper prefix of a secret and so must wait for more output.
+func (r *redactor) heldSuffix() int {
+	longest := 0
+	for _, s := range r.secrets {
+		for k := min(len(s)-1, len(r.pending)); k > longest; k-- {
+			if bytes.HasPrefix(s, r.pending[len(r.pending)-k:]) {
+				longest = k
+				break
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/url"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func redactAll(t *testing.T, secrets []string, chunks ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	r := newRedactor(&out, secrets)
+	if r == nil {
+		t.Fatal("expected a redactor")
+	}
+	for _, chunk := range chunks {
+		if n, err := r.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	return out.String()
+}
+
+func TestRedactor_MasksSecrets(t *testing.T) {
+	secret := "p@ss word/1"
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{name: "plain", chunks: []string{"pw=" + secret + "\n"}, want: "pw=***\n"},
+		{name: "repeated", chunks: []string{secret + secret}, want: "******"},
+		{name: "split across writes", chunks: []string{"pw=p@s", "s wo", "rd/1 done"}, want: "pw=*** done"},
+		{name: "split byte by byte", chunks: strings.Split("x"+secret+"y", ""), want: "x***y"},
+		{name: "base64", chunks: []string{base64.StdEncoding.EncodeToString([]byte(secret))}, want: "***"},
+		{name: "base64 url", chunks: []string{base64.RawURLEncoding.EncodeToString([]byte(secret))}, want: "***"},
+		{name: "query escaped", chunks: []string{"?pw=" + url.QueryEscape(secret)}, want: "?pw=***"},
+		{name: "path escaped", chunks: []string{"/" + url.PathEscape(secret)}, want: "/***"},
+		{name: "partial prefix at end is kept", chunks: []string{"p@ss"}, want: "p@ss"},
+		{name: "no secret", chunks: []string{"hello\n"}, want: "hello\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactAll(t, []string{secret}, tt.chunks...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactor_PrefersLongestSecret(t *testing.T) {
+	if got := redactAll(t, []string{"abcd", "abcdefg"}, "x abcde", "fg y abcd z"); got != "x *** y *** z" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRedactor_DoesNotHoldUnrelatedOutput(t *testing.T) {
+	var out bytes.Buffer
+	r := newRedactor(&out, []string{"secret"})
+	_, _ = r.Write([]byte("Enter value: "))
+	// A prompt without a newline must reach the terminal without waiting for Flush.
+	if out.String() != "Enter value: " {
+		t.Errorf("expected prompt to be written immediately, got %q", out.String())
+	}
+}
+
+func TestNewRedactor_IgnoresBlankSecrets(t *testing.T) {
+	if r := newRedactor(&bytes.Buffer{}, []string{"", "  "}); r != nil {
+		t.Error("expected no redactor for blank secrets")
+	}
+}
+
+func TestNewRedactor_IgnoresShortSecrets(t *testing.T) {
+	if r := newRedactor(&bytes.Buffer{}, []string{"1", "yes", " ab "}); r != nil {
+		t.Error("expected no redactor for secrets shorter than the minimum length")
+	}
+	if got := redactAll(t, []string{"true", "1"}, "1 is true"); got != "1 is ***" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestExecute_MasksSecretsInOutput(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) {
+		return &fakeEnvResolver{values: map[string]string{"@Microsoft.KeyVault(VaultName=v;SecretName=s)": "hunter2"}}, nil
+	}
+
+	for _, noMask := range []bool{false, true} {
+		exec, err := New(Config{
+			Shell:         "bash",
+			CaptureOutput: true,
+			NoMask:        noMask,
+			EnvOverrides:  []string{"AZD_EXEC_TEST_SECRET=@Microsoft.KeyVault(VaultName=v;SecretName=s)"},
+		})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+
+		result, err := exec.RunInline(context.Background(), `printf '%s' "$AZD_EXEC_TEST_SECRET"; printf '%s' "$AZD_EXEC_TEST_SECRET" | base64 >&2`)
+		if err != nil {
+			t.Fatalf("RunInline() error: %v", err)
+		}
+
+		wantStdout, wantStderr := "***", "***\n"
+		if noMask {
+			wantStdout, wantStderr = "hunter2", base64.StdEncoding.EncodeToString([]byte("hunter2"))+"\n"
+		}
+		if result.Stdout != wantStdout || result.Stderr != wantStderr {
+			t.Errorf("noMask=%v: stdout=%q stderr=%q, want %q and %q", noMask, result.Stdout, result.Stderr, wantStdout, wantStderr)
+		}
+	}
+}
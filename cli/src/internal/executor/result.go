@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Result describes a finished script run. It is the JSON schema shared by
+// `azd exec --output json` and the MCP execution tools.
+type Result struct {
+	// Shell is the shell or interpreter that ran the script.
+	Shell string `json:"shell"`
+
+	// Command is the resolved command line, starting with the executable.
+	Command []string `json:"command"`
+
+	// WorkingDir is the directory the script ran in.
+	WorkingDir string `json:"workingDir"`
+
+	// ExitCode is the script's exit code: 128+N when it was killed by signal N,
+	// and -1 when it did not start or its exit status is unknown.
+	ExitCode int `json:"exitCode"`
+
+	// TimedOut is true when the script was stopped because it exceeded its timeout.
+	TimedOut bool `json:"timedOut,omitempty"`
+
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	DurationMs int64     `json:"durationMs"`
+
+	// KeyVaultWarnings names the variables whose Key Vault references could not
+	// be resolved. Values and error details are deliberately left out.
+	KeyVaultWarnings []string `json:"keyVaultWarnings,omitempty"`
+
+	// Stdout and Stderr hold the script's output when it was captured.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+
+	// Error describes why the run failed, if it did.
+	Error string `json:"error,omitempty"`
+
+	// Attempts lists every attempt when retries are configured, the last one
+	// being the attempt the rest of the result describes.
+	Attempts []Attempt `json:"attempts,omitempty"`
+}
+
+// finish records the end of the run from the process state and the error returned by runCommand.
+func (r *Result) finish(ps *os.ProcessState, err error) {
+	r.EndTime = time.Now()
+	r.DurationMs = r.EndTime.Sub(r.StartTime).Milliseconds()
+
+	r.ExitCode = processExitCode(ps)
+
+	if err != nil {
+		r.Error = err.Error()
+		var timeoutErr *TimeoutError
+		r.TimedOut = errors.As(err, &timeoutErr)
+	}
+}
+
+// processExitCode returns the exit code of a finished process: 128+N when it
+// was killed by signal N, and -1 when ps is nil.
+func processExitCode(ps *os.ProcessState) int {
+	if ps == nil {
+		return -1
+	}
+	if sig, ok := terminatingSignal(ps); ok {
+		return 128 + sig
+	}
+	return ps.ExitCode()
+}
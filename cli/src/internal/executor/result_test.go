@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLastResult_CapturedRun(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	dir := t.TempDir()
+	exec, err := New(Config{Shell: "bash", WorkingDir: dir, CaptureOutput: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if exec.LastResult() != nil {
+		t.Fatal("expected no result before the first run")
+	}
+
+	runErr := exec.ExecuteInline(context.Background(), "echo out; echo err >&2; exit 3")
+	var execErr *ExecutionError
+	if !errors.As(runErr, &execErr) {
+		t.Fatalf("expected ExecutionError, got %v", runErr)
+	}
+
+	result := exec.LastResult()
+	if result == nil {
+		t.Fatal("expected a result")
+	}
+	if result.Shell != "bash" || result.WorkingDir != dir {
+		t.Errorf("unexpected shell/workingDir: %q, %q", result.Shell, result.WorkingDir)
+	}
+	if want := []string{"bash", "-c", "echo out; echo err >&2; exit 3"}; !reflect.DeepEqual(result.Command, want) {
+		t.Errorf("Command = %v, want %v", result.Command, want)
+	}
+	if result.ExitCode != 3 || result.Error == "" || result.TimedOut {
+		t.Errorf("unexpected outcome: exitCode=%d error=%q timedOut=%v", result.ExitCode, result.Error, result.TimedOut)
+	}
+	if result.Stdout != "out\n" || result.Stderr != "err\n" {
+		t.Errorf("unexpected captured output: stdout=%q stderr=%q", result.Stdout, result.Stderr)
+	}
+	if result.EndTime.Before(result.StartTime) || result.DurationMs < 0 {
+		t.Errorf("unexpected timestamps: start=%v end=%v", result.StartTime, result.EndTime)
+	}
+}
+
+func TestLastResult_TimeoutAndKeyVaultWarnings(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	oldResolver := newKeyVaultEnvResolver
+	defer func() { newKeyVaultEnvResolver = oldResolver }()
+	newKeyVaultEnvResolver = func() (keyVaultEnvResolver, error) { return &fakeEnvResolver{}, nil }
+
+	exec, err := New(Config{
+		Shell:        "bash",
+		Timeout:      100 * time.Millisecond,
+		GracePeriod:  100 * time.Millisecond,
+		EnvOverrides: []string{"AZD_EXEC_TEST_MISSING=@Microsoft.KeyVault(VaultName=v;SecretName=missing)"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	_ = exec.ExecuteInline(context.Background(), "sleep 5")
+
+	result := exec.LastResult()
+	if result == nil || !result.TimedOut {
+		t.Fatalf("expected a timed-out result, got %+v", result)
+	}
+	if !reflect.DeepEqual(result.KeyVaultWarnings, []string{"AZD_EXEC_TEST_MISSING"}) {
+		t.Errorf("KeyVaultWarnings = %v, want the variable name only", result.KeyVaultWarnings)
+	}
+}
+
+func TestResult_JSONSchema(t *testing.T) {
+	data, err := json.Marshal(Result{Shell: "bash", Command: []string{"bash", "-c", "true"}, WorkingDir: "/tmp"})
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	for _, key := range []string{"shell", "command", "workingDir", "exitCode", "startTime", "endTime", "durationMs"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("expected %q in JSON result", key)
+		}
+	}
+	for _, key := range []string{"stdout", "stderr", "error", "keyVaultWarnings", "timedOut"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("expected empty %q to be omitted", key)
+		}
+	}
+}
+
+func TestRunInline_ReturnsResult(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{
+		Shell:         "bash",
+		Environ:       []string{"PATH=" + os.Getenv("PATH"), "AZD_EXEC_TEST_BASE=base"},
+		EnvOverrides:  []string{"AZD_EXEC_TEST_OVERRIDE=override"},
+		CaptureOutput: true,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	result, err := exec.RunInline(context.Background(), `echo "$AZD_EXEC_TEST_BASE-$AZD_EXEC_TEST_OVERRIDE-${HOME:-unset}"`)
+	if err != nil {
+		t.Fatalf("RunInline() error: %v", err)
+	}
+	if result != exec.LastResult() {
+		t.Error("expected RunInline to return the recorded LastResult")
+	}
+	// Only the configured base environment is passed, not the process environment.
+	if result.Stdout != "base-override-unset\n" {
+		t.Errorf("Stdout = %q, want %q", result.Stdout, "base-override-unset\n")
+	}
+}
+
+func TestRunFile_NoResultWhenScriptDoesNotStart(t *testing.T) {
+	exec, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	result, err := exec.RunFile(context.Background(), filepath.Join(t.TempDir(), "missing.sh"))
+	var notFound *ScriptNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected ScriptNotFoundError, got %v", err)
+	}
+	if result != nil {
+		t.Errorf("expected no result, got %+v", result)
+	}
+}
+
+func TestWarn_UsesConfiguredStderr(t *testing.T) {
+	var stderr bytes.Buffer
+	exec, err := New(Config{Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	exec.warn("Failed to resolve Key Vault reference for %s: %v", "DB_PASSWORD", "denied")
+	if got, want := stderr.String(), "Warning: Failed to resolve Key Vault reference for DB_PASSWORD: denied\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
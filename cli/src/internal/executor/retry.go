@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// DefaultRetryDelay is the delay before the first retry when none is configured.
+// Each further retry waits twice as long as the one before, up to maxRetryDelay.
+const DefaultRetryDelay = time.Second
+
+// maxRetryDelay caps the delay between two attempts.
+const maxRetryDelay = 5 * time.Minute
+
+// retryJitter returns a random duration in [0, limit]. It is a variable so tests can make delays predictable.
+var retryJitter = func(limit time.Duration) time.Duration {
+	return rand.N(limit + 1)
+}
+
+// Attempt describes one attempt of a script run that may be retried.
+type Attempt struct {
+	// Number is the attempt number, starting at 1.
+	Number int `json:"number"`
+
+	// ExitCode is the attempt's exit code, as in Result.
+	ExitCode int `json:"exitCode"`
+
+	StartTime  time.Time `json:"startTime"`
+	DurationMs int64     `json:"durationMs"`
+
+	// Error describes why the attempt failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// runAttempts runs cmd and, while the retry policy allows it, starts it again
+// after a failed attempt. It returns the command of the last attempt, the
+// attempts when retries are configured, and the error of the last attempt.
+// beforeRetry is called before every attempt after the first.
+// The timeout and ctx cover all attempts together, including the delays.
+func (e *Executor) runAttempts(ctx context.Context, cmd *exec.Cmd, inv invocation, beforeRetry func()) (*exec.Cmd, []Attempt, error) {
+	total := e.config.Retries + 1
+	var attempts []Attempt
+	for number := 1; ; number++ {
+		start := time.Now()
+		err := e.runCommand(ctx, cmd, inv.scriptOrPath, inv.shell, inv.isInline)
+		if e.config.Retries == 0 {
+			return cmd, nil, err
+		}
+
+		attempt := Attempt{Number: number, ExitCode: processExitCode(cmd.ProcessState), StartTime: start, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		attempts = append(attempts, attempt)
+
+		if err == nil {
+			if number > 1 {
+				e.notice("Attempt %d of %d succeeded", number, total)
+			}
+			return cmd, attempts, nil
+		}
+		if number == total || !e.shouldRetry(err) {
+			e.warn("Attempt %d of %d failed with exit code %d", number, total, attempt.ExitCode)
+			return cmd, attempts, err
+		}
+
+		delay := e.retryDelay(number)
+		e.warn("Attempt %d of %d failed with exit code %d; retrying in %s", number, total, attempt.ExitCode, delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return cmd, attempts, e.cancellationError(ctx, inv.shell, inv.isInline)
+		case <-timer.C:
+		}
+
+		beforeRetry()
+
+		// An exec.Cmd can only be started once.
+		next := exec.CommandContext(ctx, cmd.Args[0], cmd.Args[1:]...) // #nosec G204 -- same command as the first attempt
+		next.Dir, next.Env = cmd.Dir, cmd.Env
+		next.Stdin, next.Stdout, next.Stderr = cmd.Stdin, cmd.Stdout, cmd.Stderr
+		cmd = next
+	}
+}
+
+// shouldRetry reports whether a failed attempt may be retried: it exited with
+// a non-zero code that is one of RetryOnExitCodes, or any code if none are set.
+// Timeouts, cancellation and scripts that could not be started are not retried.
+func (e *Executor) shouldRetry(err error) bool {
+	var execErr *ExecutionError
+	if !errors.As(err, &execErr) {
+		return false
+	}
+	return len(e.config.RetryOnExitCodes) == 0 || slices.Contains(e.config.RetryOnExitCodes, execErr.ExitCode)
+}
+
+// retryDelay returns how long to wait after the given failed attempt: the
+// configured delay doubled for every earlier retry, of which a random part
+// up to one half is taken off so that concurrent runs do not retry in lockstep.
+func (e *Executor) retryDelay(attempt int) time.Duration {
+	delay := e.config.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+	return delay - retryJitter(delay/2)
+}
@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate_Retries(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		wantField string
+	}{
+		{name: "Negative retries", config: Config{Retries: -1}, wantField: "retries"},
+		{name: "Negative retry delay", config: Config{Retries: 1, RetryDelay: -time.Second}, wantField: "retryDelay"},
+		{name: "Zero exit code", config: Config{Retries: 1, RetryOnExitCodes: []int{1, 0}}, wantField: "retryOnExitCodes"},
+		{name: "Valid policy", config: Config{Retries: 3, RetryDelay: time.Second, RetryOnExitCodes: []int{1, 75}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Fatalf("Validate() error = %v, want ValidationError for %q", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestRetryDelay_Backoff(t *testing.T) {
+	oldJitter := retryJitter
+	defer func() { retryJitter = oldJitter }()
+
+	exec, err := New(Config{RetryDelay: time.Second})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	retryJitter = func(time.Duration) time.Duration { return 0 }
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 20: maxRetryDelay} {
+		if got := exec.retryDelay(attempt); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	retryJitter = func(limit time.Duration) time.Duration { return limit }
+	if got := exec.retryDelay(2); got != time.Second {
+		t.Errorf("retryDelay(2) with full jitter = %v, want 1s", got)
+	}
+}
+
+func TestRetries_RerunUntilSuccess(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	counter := filepath.Join(t.TempDir(), "count")
+	script := `n=$(cat '` + counter + `' 2>/dev/null || echo 0); n=$((n+1)); echo $n > '` + counter + `'; [ $n -ge 3 ] || exit 75`
+
+	var stderr bytes.Buffer
+	exec, err := New(Config{Shell: "bash", Retries: 3, RetryDelay: time.Millisecond, RetryOnExitCodes: []int{75}, Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := exec.ExecuteInline(context.Background(), script); err != nil {
+		t.Fatalf("ExecuteInline() error: %v", err)
+	}
+
+	result := exec.LastResult()
+	if result.ExitCode != 0 || len(result.Attempts) != 3 {
+		t.Fatalf("expected success after 3 attempts, got exit code %d and %+v", result.ExitCode, result.Attempts)
+	}
+	for i, want := range []int{75, 75, 0} {
+		if a := result.Attempts[i]; a.Number != i+1 || a.ExitCode != want {
+			t.Errorf("attempt %d = %+v, want exit code %d", i+1, a, want)
+		}
+	}
+	for _, want := range []string{"Attempt 1 of 4 failed with exit code 75; retrying in", "Attempt 3 of 4 succeeded"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("expected %q in stderr, got:\n%s", want, stderr.String())
+		}
+	}
+}
+
+func TestRetries_CaptureOnlyLastAttempt(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	dir := t.TempDir()
+	counter, logFile := filepath.Join(dir, "count"), filepath.Join(dir, "run.log")
+	script := `n=$(cat '` + counter + `' 2>/dev/null || echo 0); n=$((n+1)); echo $n > '` + counter + `'; echo "out $n"; echo "err $n" >&2; [ $n -ge 2 ] || exit 75`
+
+	exec, err := New(Config{Shell: "bash", Retries: 2, RetryDelay: time.Millisecond, CaptureOutput: true, LogFile: logFile, Tee: true, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := exec.ExecuteInline(context.Background(), script); err != nil {
+		t.Fatalf("ExecuteInline() error: %v", err)
+	}
+
+	result := exec.LastResult()
+	if result.Stdout != "out 2\n" || result.Stderr != "err 2\n" {
+		t.Errorf("captured stdout %q and stderr %q, want only the last attempt's output", result.Stdout, result.Stderr)
+	}
+	log, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"out 1", "err 1", "out 2", "err 2"} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("expected %q in the log file, got:\n%s", want, log)
+		}
+	}
+}
+
+func TestRetries_OnlyMatchingExitCodes(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{Shell: "bash", Retries: 3, RetryDelay: time.Millisecond, RetryOnExitCodes: []int{75}, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	err = exec.ExecuteInline(context.Background(), "exit 2")
+
+	var execErr *ExecutionError
+	if !errors.As(err, &execErr) || execErr.ExitCode != 2 {
+		t.Fatalf("expected ExecutionError with exit code 2, got %v", err)
+	}
+	if attempts := exec.LastResult().Attempts; len(attempts) != 1 {
+		t.Errorf("expected a single attempt, got %+v", attempts)
+	}
+}
+
+func TestRetries_NotConfiguredOmitsAttempts(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	exec, err := New(Config{Shell: "bash", Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	_ = exec.ExecuteInline(context.Background(), "exit 1")
+	if attempts := exec.LastResult().Attempts; attempts != nil {
+		t.Errorf("expected no attempts without retries, got %+v", attempts)
+	}
+}
@@ -0,0 +1,186 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// Error classes reported in SecretCheck.ErrorClass.
+const (
+	// SecretErrorNotFound means the secret, its version or its file does not exist.
+	SecretErrorNotFound = "not_found"
+	// SecretErrorForbidden means the identity may not read the secret.
+	SecretErrorForbidden = "forbidden"
+	// SecretErrorAuthentication means no credential could be obtained or it was rejected.
+	SecretErrorAuthentication = "authentication"
+	// SecretErrorTimeout means the check was cancelled or timed out.
+	SecretErrorTimeout = "timeout"
+	// SecretErrorOther is any other failure, such as an invalid vault name or a network error.
+	SecretErrorOther = "error"
+)
+
+// SecretReference is an environment variable whose value is a Key Vault or
+// other secret reference.
+type SecretReference struct {
+	// Name is the environment variable's name.
+	Name string `json:"name"`
+
+	// Provider is the scheme of the provider that resolves the reference, e.g.
+	// "akv" for Key Vault or "env".
+	Provider string `json:"provider"`
+
+	// Reference is the reference itself, never the secret value.
+	Reference string `json:"reference"`
+
+	// Vault, Secret and Version describe Key Vault references; Version is empty
+	// for the latest version.
+	Vault   string `json:"vault,omitempty"`
+	Secret  string `json:"secret,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// SecretCheck is the outcome of resolving one secret reference with
+// CheckSecretReferences. It never contains the secret value.
+type SecretCheck struct {
+	SecretReference
+
+	// Resolved reports whether the secret could be read.
+	Resolved bool `json:"resolved"`
+
+	// ErrorClass classifies the failure when Resolved is false, e.g. SecretErrorNotFound.
+	ErrorClass string `json:"errorClass,omitempty"`
+
+	// Error describes the failure when Resolved is false.
+	Error string `json:"error,omitempty"`
+}
+
+// SecretReferences returns the variables of the script environment that hold
+// secret references a run would resolve, sorted by name, after EnvFiles,
+// EnvOverrides and Unset are applied. Nothing is resolved.
+func (e *Executor) SecretReferences() ([]SecretReference, error) {
+	envVars, scoped, err := e.baseEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	return secretReferences(envVars, scoped), nil
+}
+
+// CheckSecretReferences resolves every secret reference in the script
+// environment, without running a script, and reports for each variable whether
+// it resolved. Cached values are neither used nor updated, so every reference is
+// checked against its source. Failures are reported in the results, not as an
+// error; the error is only set if the environment could not be prepared or ctx is done.
+func (e *Executor) CheckSecretReferences(ctx context.Context) ([]SecretCheck, error) {
+	envVars, scoped, err := e.baseEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	refs := secretReferences(envVars, scoped)
+	checks := make([]SecretCheck, 0, len(refs))
+	if len(refs) == 0 {
+		return checks, nil
+	}
+
+	// Resolve only the reference variables, continuing past failures.
+	refVars := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		refVars = append(refVars, envVars[indexEnv(envVars, ref.Name)])
+	}
+	checker := &Executor{config: e.config}
+	checker.config.NoCache = true
+	checker.config.StopOnKeyVaultError = false
+	_, warnings, err := checker.resolveSecretReferences(ctx, refVars, scoped)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	failures := map[string]error{}
+	providerFailures := map[string]error{}
+	for _, w := range warnings {
+		var failed *providerError
+		if w.Key == "" && errors.As(w.Err, &failed) {
+			// The provider could not resolve anything, e.g. without credentials.
+			providerFailures[failed.scheme] = failed.err
+			continue
+		}
+		failures[w.Key] = w.Err
+	}
+
+	for _, ref := range refs {
+		check := SecretCheck{SecretReference: ref, Resolved: true}
+		if err, failed := providerFailures[ref.Provider]; failed {
+			check.Resolved = false
+			check.ErrorClass = ClassifySecretError(err)
+			if ref.Provider == keyVaultScheme {
+				// Key Vault fails as a whole only when no resolver can be created.
+				check.ErrorClass = SecretErrorAuthentication
+			}
+			check.Error = err.Error()
+		} else if err, failed := failures[ref.Name]; failed {
+			check.Resolved = false
+			check.ErrorClass = ClassifySecretError(err)
+			check.Error = err.Error()
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// secretReferences returns the variables in envVars that hold secret
+// references resolved under scoped, sorted by name.
+func secretReferences(envVars, scoped []string) []SecretReference {
+	var refs []SecretReference
+	for _, kv := range envVars {
+		p, ok := scopedProviderFor(kv, scoped)
+		if !ok {
+			continue
+		}
+		name, value, _ := strings.Cut(kv, "=")
+		ref := SecretReference{Name: name, Provider: p.Scheme(), Reference: unquoteReference(value)}
+		if ref.Provider == keyVaultScheme {
+			ref.Vault, ref.Secret, ref.Version = keyVaultTarget(value)
+		}
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs
+}
+
+// ClassifySecretError returns the error class of a secret resolution failure,
+// such as the Err of a keyvault.KeyVaultResolutionWarning.
+func ClassifySecretError(err error) string {
+	var respErr *azcore.ResponseError
+	var authFailed *azidentity.AuthenticationFailedError
+	var authRequired *azidentity.AuthenticationRequiredError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		return SecretErrorTimeout
+	case errors.Is(err, fs.ErrNotExist):
+		return SecretErrorNotFound
+	case errors.As(err, &respErr):
+		switch respErr.StatusCode {
+		case http.StatusNotFound:
+			return SecretErrorNotFound
+		case http.StatusForbidden:
+			return SecretErrorForbidden
+		case http.StatusUnauthorized:
+			return SecretErrorAuthentication
+		}
+	case errors.As(err, &authFailed), errors.As(err, &authRequired):
+		return SecretErrorAuthentication
+	case strings.Contains(strings.ToLower(err.Error()), "credential"):
+		// Credentials that are not configured at all fail with an unexported error type.
+		return SecretErrorAuthentication
+	}
+	return SecretErrorOther
+}
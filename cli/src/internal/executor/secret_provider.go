@@ -0,0 +1,324 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jongio/azd-core/keyvault"
+)
+
+// secretConcurrency is the maximum number of references a provider resolves at the same time.
+const secretConcurrency = 8
+
+// secretSchemePattern matches valid URI schemes.
+var secretSchemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// SecretProvider resolves the secret references of one URI scheme, such as
+// env://NAME or file://path#KEY, found in the script environment.
+type SecretProvider interface {
+	// Scheme is the URI scheme of the provider's references without "://", e.g. "file".
+	Scheme() string
+
+	// Matches reports whether value is a reference the provider resolves. It lets
+	// a provider ignore values that merely look like its URIs, such as file URLs
+	// without a key.
+	Matches(value string) bool
+
+	// Resolve resolves references, each accepted by Matches, and returns one
+	// result per reference in the same order. An error means no reference could
+	// be resolved, for example because the provider has no credentials.
+	Resolve(ctx context.Context, references []string, opts SecretResolveOptions) ([]SecretResult, error)
+}
+
+// SecretResolveOptions are passed to SecretProvider.Resolve.
+type SecretResolveOptions struct {
+	// NoCache disables any cache the provider keeps of resolved values.
+	NoCache bool
+
+	// CacheTTL is how long the provider may reuse resolved values.
+	// Zero means values are not cached.
+	CacheTTL time.Duration
+}
+
+// SecretResult is the value of one resolved reference, or why it could not be resolved.
+type SecretResult struct {
+	Value string
+	Err   error
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	// secretProviders maps schemes to registered providers.
+	secretProviders = map[string]SecretProvider{}
+)
+
+func init() {
+	for _, p := range []SecretProvider{keyVaultProvider{}, envProvider{}, fileProvider{}, sopsProvider{}, passProvider{}, appConfigProvider{}} {
+		if err := RegisterSecretProvider(p); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterSecretProvider adds a secret provider, or replaces the one registered for its scheme.
+// Returns a *ValidationError if the scheme is not a valid lowercase URI scheme.
+func RegisterSecretProvider(p SecretProvider) error {
+	scheme := p.Scheme()
+	if !secretSchemePattern.MatchString(scheme) {
+		return &ValidationError{Field: "scheme", Reason: fmt.Sprintf("%q is not a valid lowercase URI scheme", scheme)}
+	}
+
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[scheme] = p
+	return nil
+}
+
+// LookupSecretProvider returns the provider registered for scheme.
+func LookupSecretProvider(scheme string) (SecretProvider, bool) {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	p, ok := secretProviders[strings.ToLower(scheme)]
+	return p, ok
+}
+
+// SecretProviderSchemes returns the schemes of all registered providers, sorted.
+func SecretProviderSchemes() []string {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	schemes := make([]string, 0, len(secretProviders))
+	for scheme := range secretProviders {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// secretProviderFor returns the provider that resolves value, if value is a secret reference.
+// Key Vault references in the @Microsoft.KeyVault(...) and akvs:// formats belong to
+// the akv provider.
+func secretProviderFor(value string) (SecretProvider, bool) {
+	scheme := keyVaultScheme
+	if !keyvault.IsKeyVaultReference(value) {
+		var ok bool
+		scheme, _, ok = strings.Cut(unquoteReference(value), "://")
+		if !ok {
+			return nil, false
+		}
+	}
+	p, ok := LookupSecretProvider(scheme)
+	if !ok || !p.Matches(value) {
+		return nil, false
+	}
+	return p, true
+}
+
+// scopedProviderFor returns the provider that resolves the KEY=VALUE pair kv.
+// Key Vault references are resolved in any variable, references of other
+// providers only in the pairs listed in scoped.
+func scopedProviderFor(kv string, scoped []string) (SecretProvider, bool) {
+	_, value, ok := strings.Cut(kv, "=")
+	if !ok {
+		return nil, false
+	}
+	p, ok := secretProviderFor(value)
+	if !ok || (p.Scheme() != keyVaultScheme && !slices.Contains(scoped, kv)) {
+		return nil, false
+	}
+	return p, true
+}
+
+// IsSecretReference reports whether value is a Key Vault or other secret reference
+// resolved by a registered secret provider.
+func IsSecretReference(value string) bool {
+	_, ok := secretProviderFor(value)
+	return ok
+}
+
+// NeedsAzdEnvironment reports whether environ holds references of providers
+// other than Key Vault, which are resolved only in the values listed in
+// Config.AzdEnvironment, EnvFiles and EnvOverrides.
+func NeedsAzdEnvironment(environ []string) bool {
+	for _, kv := range environ {
+		_, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if p, ok := secretProviderFor(value); ok && p.Scheme() != keyVaultScheme {
+			return true
+		}
+	}
+	return false
+}
+
+// referenceKind describes the kind of a secret reference in messages.
+func referenceKind(scheme string) string {
+	if scheme == keyVaultScheme {
+		return "Key Vault reference"
+	}
+	return scheme + ":// reference"
+}
+
+// referenceKindOf describes the kind of secret reference that key holds in envVars.
+func referenceKindOf(envVars []string, key string) string {
+	if i := indexEnv(envVars, key); i >= 0 {
+		_, value, _ := strings.Cut(envVars[i], "=")
+		if p, ok := secretProviderFor(value); ok {
+			return referenceKind(p.Scheme())
+		}
+	}
+	return "secret reference"
+}
+
+// unquoteReference trims value and removes one pair of matching surrounding
+// quotes, which dotenv files and azd environments often keep.
+func unquoteReference(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+	return value
+}
+
+// secretBatch is the references in one environment resolved by one provider.
+type secretBatch struct {
+	provider SecretProvider
+	refs     []string
+	index    map[string]int
+	results  []SecretResult
+	err      error
+}
+
+// resolveSecretReferences replaces the secret references in envVars with their
+// values, those of providers other than Key Vault only in the pairs listed in
+// scoped. Each provider resolves all of its references in one call, and providers
+// run concurrently. Unresolved references are reported as warnings and left in
+// place, unless StopOnKeyVaultError is set, in which case the first failure in
+// envVars order is returned as an error.
+func (e *Executor) resolveSecretReferences(ctx context.Context, envVars, scoped []string) ([]string, []keyvault.KeyVaultResolutionWarning, error) {
+	if !e.hasSecretReferences(envVars, scoped) {
+		return envVars, nil, nil
+	}
+
+	batches := map[string]*secretBatch{}
+	var ordered []*secretBatch
+	refBatch := make([]*secretBatch, len(envVars))
+	for i, kv := range envVars {
+		p, ok := scopedProviderFor(kv, scoped)
+		if !ok {
+			continue
+		}
+		_, value, _ := strings.Cut(kv, "=")
+		b := batches[p.Scheme()]
+		if b == nil {
+			b = &secretBatch{provider: p, index: map[string]int{}}
+			batches[p.Scheme()] = b
+			ordered = append(ordered, b)
+		}
+		if _, seen := b.index[value]; !seen {
+			b.index[value] = len(b.refs)
+			b.refs = append(b.refs, value)
+		}
+		refBatch[i] = b
+	}
+
+	opts := SecretResolveOptions{NoCache: e.config.NoCache, CacheTTL: e.config.CacheTTL}
+	var wg sync.WaitGroup
+	for _, b := range ordered {
+		wg.Add(1)
+		go func(b *secretBatch) {
+			defer wg.Done()
+			b.results, b.err = b.provider.Resolve(ctx, b.refs, opts)
+			if b.err == nil && len(b.results) != len(b.refs) {
+				b.err = fmt.Errorf("%s:// provider returned %d results for %d references", b.provider.Scheme(), len(b.results), len(b.refs))
+			}
+		}(b)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []keyvault.KeyVaultResolutionWarning
+	for _, b := range ordered {
+		if b.err == nil {
+			continue
+		}
+		err := &providerError{scheme: b.provider.Scheme(), err: b.err}
+		if e.config.StopOnKeyVaultError {
+			return nil, warnings, err
+		}
+		warnings = append(warnings, keyvault.KeyVaultResolutionWarning{Err: err})
+	}
+
+	resolved := make([]string, 0, len(envVars))
+	for i, kv := range envVars {
+		b := refBatch[i]
+		if b == nil || b.err != nil {
+			resolved = append(resolved, kv)
+			continue
+		}
+		key, value, _ := strings.Cut(kv, "=")
+		r := b.results[b.index[value]]
+		if r.Err != nil {
+			warnings = append(warnings, keyvault.KeyVaultResolutionWarning{Key: key, Err: r.Err})
+			if e.config.StopOnKeyVaultError {
+				return nil, warnings, fmt.Errorf("failed to resolve %s for %s: %w", referenceKind(b.provider.Scheme()), key, r.Err)
+			}
+			resolved = append(resolved, kv)
+			continue
+		}
+		resolved = append(resolved, key+"="+r.Value)
+	}
+	return resolved, warnings, nil
+}
+
+// providerError is a failure of a whole provider to resolve its references.
+type providerError struct {
+	scheme string
+	err    error
+}
+
+func (e *providerError) Error() string { return e.err.Error() }
+func (e *providerError) Unwrap() error { return e.err }
+
+// hasSecretReferences checks if any environment variables contain secret
+// references resolved under scoped.
+func (e *Executor) hasSecretReferences(envVars, scoped []string) bool {
+	for _, envVar := range envVars {
+		if _, ok := scopedProviderFor(envVar, scoped); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveEach resolves references one at a time with resolve, up to
+// secretConcurrency at once, for providers without a batch API.
+func resolveEach(ctx context.Context, references []string, resolve func(ctx context.Context, reference string) (string, error)) []SecretResult {
+	results := make([]SecretResult, len(references))
+	sem := make(chan struct{}, secretConcurrency)
+	var wg sync.WaitGroup
+	for i, ref := range references {
+		wg.Add(1)
+		go func(i int, ref string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			results[i].Value, results[i].Err = resolve(ctx, ref)
+		}(i, ref)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,433 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// staticProvider resolves references of a test scheme from a map.
+type staticProvider struct {
+	scheme string
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (p *staticProvider) Scheme() string { return p.scheme }
+
+func (p *staticProvider) Matches(value string) bool {
+	return strings.HasPrefix(value, p.scheme+"://")
+}
+
+func (p *staticProvider) Resolve(_ context.Context, references []string, _ SecretResolveOptions) ([]SecretResult, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	results := make([]SecretResult, len(references))
+	for i, ref := range references {
+		value, ok := p.values[ref]
+		if !ok {
+			results[i].Err = errors.New("no such secret")
+			continue
+		}
+		results[i].Value = value
+	}
+	return results, nil
+}
+
+// useSecretProvider registers p for the duration of the test.
+func useSecretProvider(t *testing.T, p SecretProvider) {
+	t.Helper()
+	old, had := LookupSecretProvider(p.Scheme())
+	if err := RegisterSecretProvider(p); err != nil {
+		t.Fatalf("RegisterSecretProvider() error: %v", err)
+	}
+	t.Cleanup(func() {
+		secretProvidersMu.Lock()
+		defer secretProvidersMu.Unlock()
+		if had {
+			secretProviders[p.Scheme()] = old
+		} else {
+			delete(secretProviders, p.Scheme())
+		}
+	})
+}
+
+func TestSecretProviderSchemes_Builtins(t *testing.T) {
+	schemes := SecretProviderSchemes()
+	for _, want := range []string{"akv", "appconfig", "env", "file", "pass", "sops"} {
+		if !slices.Contains(schemes, want) {
+			t.Errorf("SecretProviderSchemes() = %v, missing %s", schemes, want)
+		}
+	}
+}
+
+func TestRegisterSecretProvider_InvalidScheme(t *testing.T) {
+	for _, scheme := range []string{"", "Vault", "1pass", "my vault"} {
+		var validationErr *ValidationError
+		if err := RegisterSecretProvider(&staticProvider{scheme: scheme}); !errors.As(err, &validationErr) {
+			t.Errorf("RegisterSecretProvider(%q) error = %v, want ValidationError", scheme, err)
+		}
+	}
+}
+
+func TestIsSecretReference(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"@Microsoft.KeyVault(VaultName=v;SecretName=s)", true},
+		{"akvs://00000000-0000-0000-0000-000000000000/v/s", true},
+		{"akv://v/s", true},
+		{"akv://v/s/version", true},
+		{"'env://HOME'", true},
+		{"file://secrets/dev.env#DB_PASSWORD", true},
+		{"sops://secrets.enc.yaml#db.password", true},
+		{"pass://dev/db", true},
+		{"appconfig://store/app:db?label=dev", true},
+		{"file:///tmp/app.db", false},
+		{"sops://secrets.enc.yaml", false},
+		{"appconfig://store", false},
+		{"env://", false},
+		{"https://example.com", false},
+		{"plain", false},
+	}
+	for _, tt := range tests {
+		if got := IsSecretReference(tt.value); got != tt.want {
+			t.Errorf("IsSecretReference(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestResolveEnvironment_LocalProviders(t *testing.T) {
+	dir := t.TempDir()
+	secretsFile := filepath.Join(dir, "dev.env")
+	if err := os.WriteFile(secretsFile, []byte("DB_PASSWORD=from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CI_TOKEN", "from-env")
+
+	// Only the azd environment, EnvFiles and EnvOverrides may use providers other than Key Vault.
+	azdEnv := []string{"TOKEN=env://CI_TOKEN"}
+	exec, err := New(Config{
+		Environ:        append([]string{"PATH=/usr/bin", "INHERITED=env://CI_TOKEN"}, azdEnv...),
+		AzdEnvironment: azdEnv,
+		EnvOverrides: []string{
+			"DB_PASSWORD=file://" + filepath.ToSlash(secretsFile) + "#DB_PASSWORD",
+			"DATABASE_URL=file:///tmp/app.db",
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil || len(resolved.warningKeys) != 0 {
+		t.Fatalf("resolveEnvironment() = %v, %v", resolved.warningKeys, err)
+	}
+	want := []string{"PATH=/usr/bin", "INHERITED=env://CI_TOKEN", "TOKEN=from-env", "DB_PASSWORD=from-file", "DATABASE_URL=file:///tmp/app.db"}
+	if !reflect.DeepEqual(resolved.vars, want) {
+		t.Errorf("resolveEnvironment() = %v, want %v", resolved.vars, want)
+	}
+}
+
+func TestResolveEnvironment_ProviderFailures(t *testing.T) {
+	useSecretProvider(t, &staticProvider{scheme: "test", values: map[string]string{"test://ok": "resolved"}})
+	environ := []string{"OK=test://ok", "MISSING=test://missing", "UNSET=env://AZD_EXEC_TEST_UNSET"}
+
+	exec, err := New(Config{Environ: environ, AzdEnvironment: environ})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil {
+		t.Fatalf("resolveEnvironment() error: %v", err)
+	}
+	if want := []string{"OK=resolved", "MISSING=test://missing", "UNSET=env://AZD_EXEC_TEST_UNSET"}; !reflect.DeepEqual(resolved.vars, want) {
+		t.Errorf("resolveEnvironment() = %v, want %v", resolved.vars, want)
+	}
+	if len(resolved.warningKeys) != 2 || resolved.warningKeys[0] != "MISSING" || resolved.warningKeys[1] != "UNSET" {
+		t.Errorf("warning keys = %v, want MISSING and UNSET", resolved.warningKeys)
+	}
+
+	exec, err = New(Config{Environ: environ, AzdEnvironment: environ, StopOnKeyVaultError: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if _, err := exec.resolveEnvironment(context.Background()); err == nil || !strings.Contains(err.Error(), "test:// reference for MISSING") {
+		t.Errorf("resolveEnvironment() error = %v, want failure for MISSING", err)
+	}
+}
+
+func TestResolveEnvironment_WarnsAboutShortSecrets(t *testing.T) {
+	useSecretProvider(t, &staticProvider{scheme: "test", values: map[string]string{"test://flag": "1", "test://token": "hunter2"}})
+	environ := []string{"FLAG=test://flag", "TOKEN=test://token"}
+
+	var stderr bytes.Buffer
+	exec, err := New(Config{Environ: environ, AzdEnvironment: environ, Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil {
+		t.Fatalf("resolveEnvironment() error: %v", err)
+	}
+	if !reflect.DeepEqual(resolved.secrets, []string{"hunter2"}) {
+		t.Errorf("secrets = %v, want only the value long enough to mask", resolved.secrets)
+	}
+	if !strings.Contains(stderr.String(), "FLAG is shorter than") || strings.Contains(stderr.String(), "TOKEN") {
+		t.Errorf("expected a warning naming FLAG only, got %q", stderr.String())
+	}
+
+	stderr.Reset()
+	exec, err = New(Config{Environ: environ, AzdEnvironment: environ, Stderr: &stderr, NoMask: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if _, err := exec.resolveEnvironment(context.Background()); err != nil || stderr.Len() != 0 {
+		t.Errorf("with NoMask: error %v, warnings %q; want neither", err, stderr.String())
+	}
+}
+
+func TestCheckSecretReferences_AllProviders(t *testing.T) {
+	useSecretProvider(t, &staticProvider{scheme: "test", values: map[string]string{"test://ok": "resolved"}})
+	useSecretProvider(t, &staticProvider{scheme: "down", err: errors.New("not signed in")})
+
+	exec, err := New(Config{EnvOverrides: []string{"OK=test://ok", "MISSING=test://missing", "OFFLINE=down://token", "PLAIN=value"}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	refs, err := exec.SecretReferences()
+	if err != nil || len(refs) != 3 || refs[0].Name != "MISSING" || refs[1].Provider != "down" || refs[2].Reference != "test://ok" {
+		t.Fatalf("SecretReferences() = %+v, %v", refs, err)
+	}
+
+	checks, err := exec.CheckSecretReferences(context.Background())
+	if err != nil || len(checks) != 3 {
+		t.Fatalf("CheckSecretReferences() = %+v, %v", checks, err)
+	}
+	if checks[0].Resolved || checks[0].ErrorClass != SecretErrorOther {
+		t.Errorf("MISSING check = %+v, want a failure", checks[0])
+	}
+	if checks[1].Resolved || checks[1].Error != "not signed in" {
+		t.Errorf("OFFLINE check = %+v, want the provider's failure", checks[1])
+	}
+	if !checks[2].Resolved {
+		t.Errorf("OK check = %+v, want it resolved", checks[2])
+	}
+}
+
+func TestResolveEnvironment_ProviderError(t *testing.T) {
+	provider := &staticProvider{scheme: "test", err: errors.New("not signed in")}
+	useSecretProvider(t, provider)
+
+	var stderr bytes.Buffer
+	exec, err := New(Config{Environ: []string{}, EnvOverrides: []string{"A=test://a", "B=test://b", "C=test://a"}, Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil {
+		t.Fatalf("resolveEnvironment() error: %v", err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want 1", provider.calls)
+	}
+	if len(resolved.vars) != 3 || resolved.vars[0] != "A=test://a" {
+		t.Errorf("expected references to stay unresolved, got %v", resolved.vars)
+	}
+	if len(resolved.warningKeys) != 0 || strings.Count(stderr.String(), "not signed in") != 1 {
+		t.Errorf("warning keys = %v, stderr = %q; want one provider warning for no variable", resolved.warningKeys, stderr.String())
+	}
+}
+
+func TestResolveEnvironment_AkvReference(t *testing.T) {
+	fake := &fakeReferenceResolver{values: map[string]string{
+		"@Microsoft.KeyVault(VaultName=kv;SecretName=db)":                  "latest",
+		"@Microsoft.KeyVault(VaultName=kv;SecretName=db;SecretVersion=v1)": "pinned",
+	}}
+	useFakeReferenceResolver(t, fake)
+
+	exec, err := New(Config{Environ: []string{"LATEST=akv://kv/db", "PINNED=\"akv://kv/db/v1\""}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	resolved, err := exec.resolveEnvironment(context.Background())
+	if err != nil || len(resolved.warningKeys) != 0 {
+		t.Fatalf("resolveEnvironment() = %v, %v", resolved.warningKeys, err)
+	}
+	if want := []string{"LATEST=latest", "PINNED=pinned"}; !reflect.DeepEqual(resolved.vars, want) {
+		t.Errorf("resolveEnvironment() = %v, want %v", resolved.vars, want)
+	}
+}
+
+func TestResolvedSecrets_ProviderReferences(t *testing.T) {
+	unresolved := []string{"TOKEN=env://CI_TOKEN", "URL=file:///tmp/app.db"}
+	resolved := []string{"TOKEN=hunter2", "URL=file:///tmp/app.db"}
+	if got, _ := resolvedSecrets(unresolved, resolved); len(got) != 1 || got[0] != "hunter2" {
+		t.Errorf("resolvedSecrets() = %v, want [hunter2]", got)
+	}
+}
+
+func TestFileProvider_Errors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dev.env")
+	if err := os.WriteFile(path, []byte("A=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	refs := []string{
+		"file://" + filepath.ToSlash(path) + "#A",
+		"file://" + filepath.ToSlash(path) + "#B",
+		"file://" + filepath.ToSlash(filepath.Join(dir, "missing.env")) + "#A",
+	}
+	results, err := fileProvider{}.Resolve(context.Background(), refs, SecretResolveOptions{})
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if results[0].Value != "1" || results[0].Err != nil {
+		t.Errorf("results[0] = %+v, want 1", results[0])
+	}
+	if results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "has no key B") {
+		t.Errorf("results[1] = %+v, want missing key error", results[1])
+	}
+	if results[2].Err == nil || !strings.Contains(results[2].Err.Error(), "missing.env does not exist") {
+		t.Errorf("results[2] = %+v, want missing file error", results[2])
+	}
+}
+
+func TestCheckSecretReferences_MissingFileIsNotFound(t *testing.T) {
+	missing := "file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "missing.env")) + "#A"
+	exec, err := New(Config{Environ: []string{}, EnvOverrides: []string{"A=" + missing}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	checks, err := exec.CheckSecretReferences(context.Background())
+	if err != nil || len(checks) != 1 {
+		t.Fatalf("CheckSecretReferences() = %+v, %v", checks, err)
+	}
+	if checks[0].Resolved || checks[0].ErrorClass != SecretErrorNotFound {
+		t.Errorf("check = %+v, want %s", checks[0], SecretErrorNotFound)
+	}
+}
+
+func TestSopsProvider_DecryptsEachFileOnce(t *testing.T) {
+	oldRun := runSecretCommand
+	t.Cleanup(func() { runSecretCommand = oldRun })
+	var calls [][]string
+	runSecretCommand = func(_ context.Context, name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		return []byte(`{"db":{"password":"s3cret","port":5432},"hosts":["a","b"]}`), nil
+	}
+
+	refs := []string{"sops://secrets.enc.yaml#db.password", "sops://secrets.enc.yaml#db.port", "sops://secrets.enc.yaml#hosts.1", "sops://secrets.enc.yaml#db"}
+	results, err := sopsProvider{}.Resolve(context.Background(), refs, SecretResolveOptions{})
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0], []string{"sops", "--decrypt", "--output-type", "json", "secrets.enc.yaml"}) {
+		t.Errorf("sops calls = %v", calls)
+	}
+	for i, want := range []string{"s3cret", "5432", "b"} {
+		if results[i].Err != nil || results[i].Value != want {
+			t.Errorf("results[%d] = %+v, want %s", i, results[i], want)
+		}
+	}
+	if results[3].Err == nil {
+		t.Error("expected an error for a non-scalar key")
+	}
+}
+
+func TestPassProvider_FirstLine(t *testing.T) {
+	oldRun := runSecretCommand
+	t.Cleanup(func() { runSecretCommand = oldRun })
+	runSecretCommand = func(_ context.Context, name string, args ...string) ([]byte, error) {
+		if name != "pass" || !reflect.DeepEqual(args, []string{"show", "dev/db"}) {
+			return nil, errors.New("unexpected command")
+		}
+		return []byte("hunter2\nuser: admin\n"), nil
+	}
+
+	results, err := passProvider{}.Resolve(context.Background(), []string{"pass://dev/db"}, SecretResolveOptions{})
+	if err != nil || results[0].Err != nil || results[0].Value != "hunter2" {
+		t.Errorf("Resolve() = %+v, %v, want hunter2", results, err)
+	}
+}
+
+// fakeCredential returns a fixed token and records the requested scopes.
+type fakeCredential struct {
+	scopes []string
+}
+
+func (c *fakeCredential) GetToken(_ context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.scopes = append(c.scopes, opts.Scopes...)
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAppConfigProvider_Resolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.Query().Get("label") {
+		case "/kv/app:db?dev":
+			_, _ = w.Write([]byte(`{"key":"app:db","label":"dev","value":"dev-connection"}`))
+		case "/kv/app:secret?":
+			_, _ = w.Write([]byte(`{"key":"app:secret","content_type":"` + appConfigKeyVaultRefContentType + `;charset=utf-8","value":"{\"uri\":\"https://kv.vault.azure.net/secrets/secret\"}"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	oldCred, oldEndpoint := newAzureCredential, appConfigEndpoint
+	t.Cleanup(func() { newAzureCredential, appConfigEndpoint = oldCred, oldEndpoint })
+	cred := &fakeCredential{}
+	newAzureCredential = func() (azcore.TokenCredential, error) { return cred, nil }
+	var stores []string
+	appConfigEndpoint = func(store string) string {
+		stores = append(stores, store)
+		return server.URL
+	}
+	useFakeReferenceResolver(t, &fakeReferenceResolver{values: map[string]string{
+		"@Microsoft.KeyVault(SecretUri=https://kv.vault.azure.net/secrets/secret)": "from-key-vault",
+	}})
+
+	refs := []string{"appconfig://store/app:db?label=dev", "appconfig://store/app:secret", "appconfig://store/missing", "appconfig://evil.example#/key"}
+	results, err := appConfigProvider{}.Resolve(context.Background(), refs, SecretResolveOptions{NoCache: true})
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if results[0].Err != nil || results[0].Value != "dev-connection" {
+		t.Errorf("results[0] = %+v, want dev-connection", results[0])
+	}
+	if results[1].Err != nil || results[1].Value != "from-key-vault" {
+		t.Errorf("results[1] = %+v, want from-key-vault", results[1])
+	}
+	if results[2].Err == nil || ClassifySecretError(results[2].Err) != SecretErrorNotFound {
+		t.Errorf("results[2] = %+v, want not found", results[2])
+	}
+	if results[3].Err == nil || !strings.Contains(results[3].Err.Error(), "not a valid App Configuration store name") {
+		t.Errorf("results[3] = %+v, want an invalid store name", results[3])
+	}
+	if slices.Contains(stores, "evil.example#") {
+		t.Error("an invalid store name was used to build an endpoint")
+	}
+	if len(cred.scopes) != 1 || cred.scopes[0] != server.URL+"/.default" {
+		t.Errorf("token scopes = %v, want one token for the store", cred.scopes)
+	}
+}
@@ -0,0 +1,400 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	azruntime "github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/joho/godotenv"
+)
+
+// appConfigKeyVaultRefContentType is the content type of App Configuration
+// values that reference a Key Vault secret.
+const appConfigKeyVaultRefContentType = "application/vnd.microsoft.appconfig.keyvaultref+json"
+
+// runSecretCommand runs a local secret store's CLI, such as sops or pass, and returns its stdout.
+var runSecretCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s is not installed or not on PATH", name)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// newAzureCredential returns the credential used for App Configuration.
+var newAzureCredential = func() (azcore.TokenCredential, error) {
+	return azidentity.NewDefaultAzureCredential(nil)
+}
+
+// appConfigStorePattern matches valid App Configuration store names. Only names
+// that match are put into a host name, so a reference cannot send the Azure
+// token to another host.
+var appConfigStorePattern = regexp.MustCompile(`^[a-zA-Z0-9-]{5,50}$`)
+
+// appConfigEndpoint returns the endpoint of an App Configuration store in the
+// Azure public cloud.
+var appConfigEndpoint = func(store string) string {
+	return "https://" + store + ".azconfig.io"
+}
+
+// schemeRest returns value without quotes and its "scheme://" prefix.
+func schemeRest(value, scheme string) (string, bool) {
+	return strings.CutPrefix(unquoteReference(value), scheme+"://")
+}
+
+// splitPathReference splits path#key references. Paths may be relative to the
+// current directory or absolute, as in file:///etc/app/secrets.env#KEY.
+func splitPathReference(rest string) (path, key string, ok bool) {
+	i := strings.LastIndex(rest, "#")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", false
+	}
+	path, key = rest[:i], rest[i+1:]
+	// file:///C:/secrets.env has an extra slash before the drive letter.
+	if runtime.GOOS == osWindows && len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), key, true
+}
+
+// envProvider resolves env://NAME references to the value of a variable in
+// the azd exec process environment, e.g. to rename secrets a CI system injects.
+type envProvider struct{}
+
+func (envProvider) Scheme() string { return "env" }
+
+func (envProvider) Matches(value string) bool {
+	name, ok := schemeRest(value, "env")
+	return ok && name != "" && !strings.ContainsAny(name, "=/ \t")
+}
+
+func (envProvider) Resolve(_ context.Context, references []string, _ SecretResolveOptions) ([]SecretResult, error) {
+	results := make([]SecretResult, len(references))
+	for i, ref := range references {
+		name, _ := schemeRest(ref, "env")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			results[i].Err = fmt.Errorf("environment variable %s is not set", name)
+			continue
+		}
+		results[i].Value = value
+	}
+	return results, nil
+}
+
+// fileProvider resolves file://path#KEY references to the value of KEY in a
+// dotenv file, such as an environment-scoped secrets file kept out of source control.
+// Values without a #KEY, such as SQLite file URLs, are not references.
+type fileProvider struct{}
+
+func (fileProvider) Scheme() string { return "file" }
+
+func (fileProvider) Matches(value string) bool {
+	rest, ok := schemeRest(value, "file")
+	if !ok {
+		return false
+	}
+	_, _, ok = splitPathReference(rest)
+	return ok
+}
+
+func (fileProvider) Resolve(_ context.Context, references []string, _ SecretResolveOptions) ([]SecretResult, error) {
+	type file struct {
+		values map[string]string
+		err    error
+	}
+	files := map[string]file{}
+	results := make([]SecretResult, len(references))
+	for i, ref := range references {
+		rest, _ := schemeRest(ref, "file")
+		path, key, _ := splitPathReference(rest)
+		f, ok := files[path]
+		if !ok {
+			f.values, f.err = godotenv.Read(path)
+			if os.IsNotExist(f.err) {
+				f.err = fmt.Errorf("%s does not exist: %w", filepath.Base(path), f.err)
+			} else if f.err != nil {
+				f.err = fmt.Errorf("cannot read %s: %w", filepath.Base(path), f.err)
+			}
+			files[path] = f
+		}
+		if f.err != nil {
+			results[i].Err = f.err
+			continue
+		}
+		value, ok := f.values[key]
+		if !ok {
+			results[i].Err = fmt.Errorf("%s has no key %s", filepath.Base(path), key)
+			continue
+		}
+		results[i].Value = value
+	}
+	return results, nil
+}
+
+// sopsProvider resolves sops://path#key.path references by decrypting a
+// sops-encrypted YAML, JSON or dotenv file with the sops CLI. The key path
+// selects a value in nested documents, e.g. #database.password or #servers.0.
+type sopsProvider struct{}
+
+func (sopsProvider) Scheme() string { return "sops" }
+
+func (sopsProvider) Matches(value string) bool {
+	rest, ok := schemeRest(value, "sops")
+	if !ok {
+		return false
+	}
+	_, _, ok = splitPathReference(rest)
+	return ok
+}
+
+func (sopsProvider) Resolve(ctx context.Context, references []string, _ SecretResolveOptions) ([]SecretResult, error) {
+	type file struct {
+		doc any
+		err error
+	}
+	// Decrypt each file once, since decryption may call out to a KMS.
+	files := map[string]file{}
+	results := make([]SecretResult, len(references))
+	for i, ref := range references {
+		rest, _ := schemeRest(ref, "sops")
+		path, key, _ := splitPathReference(rest)
+		f, ok := files[path]
+		if !ok {
+			var out []byte
+			out, f.err = runSecretCommand(ctx, "sops", "--decrypt", "--output-type", "json", path)
+			if f.err == nil {
+				f.err = json.Unmarshal(out, &f.doc)
+			}
+			files[path] = f
+		}
+		if f.err != nil {
+			results[i].Err = f.err
+			continue
+		}
+		results[i].Value, results[i].Err = lookupKeyPath(f.doc, key)
+	}
+	return results, nil
+}
+
+// lookupKeyPath returns the scalar at a dot-separated key path in a decoded JSON document.
+func lookupKeyPath(doc any, keyPath string) (string, error) {
+	node := doc
+	for _, part := range strings.Split(keyPath, ".") {
+		switch n := node.(type) {
+		case map[string]any:
+			next, ok := n[part]
+			if !ok {
+				return "", fmt.Errorf("key %s not found", keyPath)
+			}
+			node = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(n) {
+				return "", fmt.Errorf("key %s not found", keyPath)
+			}
+			node = n[i]
+		default:
+			return "", fmt.Errorf("key %s not found", keyPath)
+		}
+	}
+	switch v := node.(type) {
+	case string:
+		return v, nil
+	case nil, map[string]any, []any:
+		return "", fmt.Errorf("key %s is not a scalar value", keyPath)
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// passProvider resolves pass://path/in/store references with the pass password
+// manager. By pass convention, the password is the first line of the entry.
+type passProvider struct{}
+
+func (passProvider) Scheme() string { return "pass" }
+
+func (passProvider) Matches(value string) bool {
+	name, ok := schemeRest(value, "pass")
+	return ok && strings.Trim(name, "/") != ""
+}
+
+func (passProvider) Resolve(ctx context.Context, references []string, _ SecretResolveOptions) ([]SecretResult, error) {
+	results := make([]SecretResult, len(references))
+	for i, ref := range references {
+		name, _ := schemeRest(ref, "pass")
+		out, err := runSecretCommand(ctx, "pass", "show", strings.Trim(name, "/"))
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		line, _, _ := strings.Cut(string(out), "\n")
+		results[i].Value = strings.TrimSuffix(line, "\r")
+	}
+	return results, nil
+}
+
+// appConfigProvider resolves appconfig://store/key[?label=name] references to
+// key-values in Azure App Configuration, authenticating like azd with
+// DefaultAzureCredential. Key-values that are Key Vault references resolve to
+// the referenced secret.
+type appConfigProvider struct{}
+
+func (appConfigProvider) Scheme() string { return "appconfig" }
+
+func (appConfigProvider) Matches(value string) bool {
+	_, _, _, ok := parseAppConfigReference(value)
+	return ok
+}
+
+// parseAppConfigReference returns the store, key and label of an App Configuration reference.
+func parseAppConfigReference(value string) (store, key, label string, ok bool) {
+	rest, ok := schemeRest(value, "appconfig")
+	if !ok {
+		return "", "", "", false
+	}
+	rest, query, _ := strings.Cut(rest, "?")
+	store, key, _ = strings.Cut(rest, "/")
+	if store == "" || key == "" {
+		return "", "", "", false
+	}
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return "", "", "", false
+		}
+		label = values.Get("label")
+	}
+	return store, key, label, true
+}
+
+func (appConfigProvider) Resolve(ctx context.Context, references []string, opts SecretResolveOptions) ([]SecretResult, error) {
+	cred, err := newAzureCredential()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure credential for App Configuration: %w", err)
+	}
+
+	var mu sync.Mutex
+	tokens := map[string]string{}
+	token := func(ctx context.Context, endpoint string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if t, ok := tokens[endpoint]; ok {
+			return t, nil
+		}
+		t, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{endpoint + "/.default"}})
+		if err != nil {
+			return "", err
+		}
+		tokens[endpoint] = t.Token
+		return t.Token, nil
+	}
+
+	// Key-values that reference Key Vault are resolved afterwards in one batch.
+	var kvIndexes []int
+	results := resolveEach(ctx, references, func(ctx context.Context, ref string) (string, error) {
+		store, key, label, _ := parseAppConfigReference(ref)
+		if !appConfigStorePattern.MatchString(store) {
+			return "", fmt.Errorf("%q is not a valid App Configuration store name", store)
+		}
+		endpoint := appConfigEndpoint(store)
+		t, err := token(ctx, endpoint)
+		if err != nil {
+			return "", err
+		}
+		kv, err := getAppConfigKeyValue(ctx, endpoint, t, key, label)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(kv.ContentType, appConfigKeyVaultRefContentType) {
+			return kv.Value, nil
+		}
+		var target struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal([]byte(kv.Value), &target); err != nil || target.URI == "" {
+			return "", fmt.Errorf("key %s has an invalid Key Vault reference", key)
+		}
+		mu.Lock()
+		kvIndexes = append(kvIndexes, slices.Index(references, ref))
+		mu.Unlock()
+		return "@Microsoft.KeyVault(SecretUri=" + target.URI + ")", nil
+	})
+
+	if len(kvIndexes) > 0 {
+		kvRefs := make([]string, len(kvIndexes))
+		for i, j := range kvIndexes {
+			kvRefs[i] = results[j].Value
+		}
+		kvResults, err := keyVaultProvider{}.Resolve(ctx, kvRefs, opts)
+		for i, j := range kvIndexes {
+			if err != nil {
+				results[j] = SecretResult{Err: err}
+				continue
+			}
+			results[j] = kvResults[i]
+		}
+	}
+	return results, nil
+}
+
+// appConfigKeyValue is the part of an App Configuration key-value azd exec uses.
+type appConfigKeyValue struct {
+	Value       string `json:"value"`
+	ContentType string `json:"content_type"`
+}
+
+// getAppConfigKeyValue reads one key-value from an App Configuration store.
+func getAppConfigKeyValue(ctx context.Context, endpoint, token, key, label string) (*appConfigKeyValue, error) {
+	query := url.Values{"api-version": {"1.0"}}
+	if label != "" {
+		query.Set("label", label)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/kv/"+url.PathEscape(key)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.microsoft.appconfig.kv+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, azruntime.NewResponseError(resp)
+	}
+
+	var kv appConfigKeyValue
+	if err := json.NewDecoder(resp.Body).Decode(&kv); err != nil {
+		return nil, fmt.Errorf("invalid App Configuration response for key %s: %w", key, err)
+	}
+	return &kv, nil
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jongio/azd-core/shellutil"
+)
+
+// maxStdinScriptSize caps how much script content is buffered from stdin (10 MiB).
+const maxStdinScriptSize = 10 << 20
+
+// ExecuteReader reads a script body from r and runs it with azd context.
+// The body is written to a private temporary file whose extension matches the
+// chosen shell, so interpreters that key off the extension (pwsh, cmd) accept it.
+// The temporary file is removed once execution finishes.
+// The shell is taken from config, then from a shebang line, then the OS default.
+// Returns an error if:
+//   - Interactive mode is enabled (stdin is already consumed by the script body)
+//   - Config.Trust is TrustRequire, since stdin content cannot be pinned
+//   - the script body is empty, only whitespace, or larger than 10 MiB
+//   - the temporary file cannot be written
+//   - script execution fails
+func (e *Executor) ExecuteReader(ctx context.Context, r io.Reader) error {
+	if e.config.Interactive {
+		return &ValidationError{Field: "interactive", Reason: "cannot be used when the script is read from stdin"}
+	}
+	if e.config.Trust == TrustRequire {
+		return &ValidationError{Field: "trust", Reason: "scripts read from stdin cannot be trusted; run a trusted script file instead"}
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, maxStdinScriptSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read script from stdin: %w", err)
+	}
+	if len(content) > maxStdinScriptSize {
+		return &ValidationError{Field: "scriptContent", Reason: "exceeds the 10 MiB stdin limit"}
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return &ValidationError{Field: "scriptContent", Reason: "cannot be empty or whitespace"}
+	}
+
+	shell := e.config.Shell
+	if shell == "" {
+		shell = shebangShell(content)
+	}
+	if shell == "" {
+		shell = getDefaultShellForOS()
+	}
+
+	tmpPath, err := writeTempScript(content, scriptExtension(shell))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if removeErr := os.Remove(tmpPath); removeErr != nil && os.Getenv(shellutil.EnvVarDebug) == "true" {
+			fmt.Fprintf(os.Stderr, "warning: failed to remove temporary script %s: %v\n", filepath.Base(tmpPath), removeErr)
+		}
+	}()
+
+	// Stdin scripts behave like inline scripts: run from the current directory.
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	workingDir, err := e.resolveWorkingDir(cwd)
+	if err != nil {
+		return err
+	}
+
+	return e.executeCommand(ctx, e.newInvocation(shell, workingDir, tmpPath, false))
+}
+
+// writeTempScript writes content to a new temporary file with the given extension.
+// os.CreateTemp creates the file with 0600 permissions, keeping it private to the user.
+func writeTempScript(content []byte, ext string) (string, error) {
+	f, err := os.CreateTemp("", "azd-exec-stdin-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary script: %w", err)
+	}
+	tmpPath := f.Name()
+
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write temporary script: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write temporary script: %w", err)
+	}
+
+	return tmpPath, nil
+}
+
+// scriptExtension returns the file extension a script for the given shell should use.
+// Unknown shells get no extension and rely on the interpreter accepting any file name.
+func scriptExtension(shell string) string {
+	if interp, ok := LookupInterpreter(shell); ok {
+		if len(interp.Extensions) > 0 {
+			return interp.Extensions[0]
+		}
+		return ""
+	}
+	switch strings.ToLower(shell) {
+	case shellutil.ShellBash, shellutil.ShellSh:
+		return ".sh"
+	case shellutil.ShellZsh:
+		return ".zsh"
+	case shellutil.ShellPwsh, shellutil.ShellPowerShell:
+		return ".ps1"
+	case shellutil.ShellCmd:
+		return ".cmd"
+	default:
+		return ""
+	}
+}
+
+// shebangShell extracts the interpreter name from a shebang line at the start of content.
+// It mirrors shellutil.ReadShebang for in-memory scripts and returns "" when there is none.
+func shebangShell(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+
+	line, _ := bufio.NewReader(bytes.NewReader(content[2:])).ReadString('\n')
+	parts := strings.Fields(line)
+	if len(parts) == 0 {
+		return ""
+	}
+
+	// Handle "#!/usr/bin/env bash" style shebangs
+	if filepath.Base(parts[0]) == "env" && len(parts) > 1 {
+		return filepath.Base(parts[1])
+	}
+
+	return filepath.Base(parts[0])
+}
@@ -0,0 +1,145 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestScriptExtension(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{"bash", ".sh"},
+		{"sh", ".sh"},
+		{"zsh", ".zsh"},
+		{"pwsh", ".ps1"},
+		{"PowerShell", ".ps1"},
+		{"cmd", ".cmd"},
+		{"node", ".js"},
+		{"go", ".go"},
+		{"bun", ""},
+		{"ruby", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			if got := scriptExtension(tt.shell); got != tt.want {
+				t.Errorf("scriptExtension(%q) = %q, want %q", tt.shell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShebangShell(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"bash shebang", "#!/bin/bash\necho hi\n", "bash"},
+		{"env shebang", "#!/usr/bin/env zsh\necho hi\n", "zsh"},
+		{"shebang with space", "#! /bin/sh\n", "sh"},
+		{"shebang only", "#!/usr/bin/env pwsh", "pwsh"},
+		{"no shebang", "echo hi\n", ""},
+		{"empty shebang", "#!\necho hi\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shebangShell([]byte(tt.content)); got != tt.want {
+				t.Errorf("shebangShell(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTempScript(t *testing.T) {
+	path, err := writeTempScript([]byte("echo hi\n"), ".sh")
+	if err != nil {
+		t.Fatalf("writeTempScript() error: %v", err)
+	}
+	defer os.Remove(path)
+
+	if filepath.Ext(path) != ".sh" {
+		t.Errorf("expected .sh extension, got %q", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+}
+
+func TestExecuteReader_Validation(t *testing.T) {
+	t.Run("Empty content", func(t *testing.T) {
+		exec, err := New(Config{})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		err = exec.ExecuteReader(context.Background(), strings.NewReader("  \n\t"))
+		if err == nil || !strings.Contains(err.Error(), "cannot be empty") {
+			t.Errorf("expected empty content error, got %v", err)
+		}
+	})
+
+	t.Run("Interactive mode", func(t *testing.T) {
+		exec, err := New(Config{Interactive: true})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		err = exec.ExecuteReader(context.Background(), strings.NewReader("echo hi"))
+		if err == nil || !strings.Contains(err.Error(), "interactive") {
+			t.Errorf("expected interactive validation error, got %v", err)
+		}
+	})
+
+	t.Run("Oversized content", func(t *testing.T) {
+		exec, err := New(Config{})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		big := strings.NewReader(strings.Repeat("#", maxStdinScriptSize+1))
+		err = exec.ExecuteReader(context.Background(), big)
+		if err == nil || !strings.Contains(err.Error(), "10 MiB") {
+			t.Errorf("expected size limit error, got %v", err)
+		}
+	})
+}
+
+func TestExecuteReader_RunsScriptAndCleansUp(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	t.Setenv("AZD_EXEC_TEST_MARKER", marker)
+
+	exec, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	script := "#!/bin/bash\necho \"$0\" > \"$AZD_EXEC_TEST_MARKER\"\n"
+	if err := exec.ExecuteReader(context.Background(), strings.NewReader(script)); err != nil {
+		t.Fatalf("ExecuteReader() error: %v", err)
+	}
+
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatalf("expected script to write marker file: %v", err)
+	}
+	tmpScript := strings.TrimSpace(string(data))
+	if filepath.Ext(tmpScript) != ".sh" {
+		t.Errorf("expected temporary script with .sh extension, got %q", tmpScript)
+	}
+	if _, statErr := os.Stat(tmpScript); !os.IsNotExist(statErr) {
+		t.Errorf("expected temporary script %q to be removed, stat error: %v", tmpScript, statErr)
+	}
+}
@@ -0,0 +1,230 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jongio/azd-exec/cli/src/internal/trust"
+	"golang.org/x/term"
+)
+
+// TrustMode controls whether script files must be trusted before they run.
+type TrustMode string
+
+const (
+	// TrustOff runs script files without checking the trust store.
+	TrustOff TrustMode = ""
+
+	// TrustPrompt asks for confirmation before running a script file that is
+	// not trusted or has changed, and trusts it when confirmed. Without a
+	// terminal, or when AZD_NO_PROMPT is set, such scripts run with a warning,
+	// as they did before trust was checked, without being trusted.
+	TrustPrompt TrustMode = "prompt"
+
+	// TrustRequire refuses to run script files that are not trusted, and
+	// scripts read from stdin, without asking.
+	TrustRequire TrustMode = "require"
+)
+
+// envVarNoPrompt is set by azd when prompts must not be shown.
+const envVarNoPrompt = "AZD_NO_PROMPT"
+
+// Limits for what is shown when asking to trust a script.
+const (
+	trustPreviewLines = 20
+	trustDiffLines    = 80
+)
+
+// trustMu serializes trust checks, so runs started in parallel never prompt at
+// the same time and each sees scripts the others were just trusted with.
+var trustMu sync.Mutex
+
+// stdinIsTerminal reports whether confirmation can be asked for on stdin.
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) // #nosec G115 -- file descriptors fit in an int
+}
+
+// confirmTrust asks the user to confirm on stdin and reports the answer.
+var confirmTrust = func(prompt string) (bool, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	answer, err := readLine(os.Stdin)
+	if err != nil {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// readLine reads up to and including the next newline one byte at a time, so
+// nothing after the answer is taken from input meant for the script.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// checkTrust verifies the script file of inv. Inline scripts are always allowed,
+// including inline tasks and hooks from the project configuration; the threat
+// model lists them as not covered by the trust store.
+func (e *Executor) checkTrust(inv invocation) error {
+	if inv.isInline {
+		return nil
+	}
+	return e.verifyTrust(inv.scriptOrPath)
+}
+
+// retrust checks the script file of a watch rerun. Its first version was
+// confirmed when the session started and later versions are the user's own
+// edits, so under TrustPrompt they are trusted without asking, which would
+// also compete with the script for stdin. TrustRequire still fails, and
+// without a terminal nothing was confirmed, so every version runs with a warning.
+func (e *Executor) retrust(inv invocation) error {
+	if inv.isInline || e.config.Trust != TrustPrompt || noPrompt() || !stdinIsTerminal() {
+		return e.checkTrust(inv)
+	}
+	trustMu.Lock()
+	defer trustMu.Unlock()
+
+	content, err := os.ReadFile(inv.scriptOrPath) // #nosec G304 -- path is the script about to run
+	if err != nil {
+		return &ValidationError{Field: "scriptPath", Reason: fmt.Sprintf("cannot read: %v", err)}
+	}
+	store, err := trust.Open()
+	if err != nil {
+		return err
+	}
+	if status, _ := store.Check(inv.scriptOrPath, content); status == trust.Trusted {
+		return nil
+	}
+	_, err = store.Trust(inv.scriptOrPath, content)
+	return err
+}
+
+// verifyTrust checks that the script file at path may run under the configured
+// trust mode, asking for confirmation and trusting it when TrustPrompt allows.
+func (e *Executor) verifyTrust(path string) error {
+	if e.config.Trust == TrustOff {
+		return nil
+	}
+	trustMu.Lock()
+	defer trustMu.Unlock()
+
+	content, err := os.ReadFile(path) // #nosec G304 -- path is the script about to run
+	if err != nil {
+		return &ValidationError{Field: "scriptPath", Reason: fmt.Sprintf("cannot read: %v", err)}
+	}
+	store, err := trust.Open()
+	if err != nil {
+		return err
+	}
+	status, entry := store.Check(path, content)
+	if status == trust.Trusted {
+		return nil
+	}
+
+	untrusted := &UntrustedScriptError{Path: displayPath(path), Changed: status == trust.Changed}
+	if e.config.Trust == TrustRequire {
+		untrusted.Reason = "trusted scripts are required"
+		return untrusted
+	}
+	if noPrompt() || !stdinIsTerminal() {
+		e.warn("Running %s, which is not trusted, without confirmation; use --require-trusted to refuse untrusted scripts", untrusted.Path)
+		return nil
+	}
+
+	var summary bytes.Buffer
+	writeTrustSummary(&summary, store, path, content, status, entry)
+	fmt.Fprint(os.Stderr, summary.String())
+	ok, err := confirmTrust("Trust this script and run it? [y/N] ")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		untrusted.Reason = "not confirmed"
+		return untrusted
+	}
+	_, err = store.Trust(path, content)
+	return err
+}
+
+// writeTrustSummary describes a script about to be trusted: what changed since
+// it was last trusted, or the start of a script that was never trusted.
+func writeTrustSummary(w io.Writer, store *trust.Store, path string, content []byte, status trust.Status, entry trust.Entry) {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		lines++
+	}
+	name := displayPath(path)
+
+	if status == trust.Changed {
+		fmt.Fprintf(w, "%s has changed since it was trusted on %s.\n", name, entry.TrustedAt.Local().Format("2006-01-02 15:04"))
+	} else {
+		fmt.Fprintf(w, "azd exec has not run %s before.\n", name)
+	}
+	fmt.Fprintf(w, "  Path:    %s\n  SHA-256: %s\n  Size:    %d bytes, %d lines\n", path, trust.Hash(content), len(content), lines)
+
+	if status == trust.Changed {
+		if previous, ok := store.Snapshot(entry.SHA256); ok {
+			if diff := trust.Diff(previous, content); diff != nil {
+				fmt.Fprintf(w, "Changes since it was trusted:\n")
+				writeLimited(w, diff, trustDiffLines)
+				return
+			}
+		}
+		fmt.Fprintf(w, "The changes cannot be shown line by line; review the whole script.\n")
+	}
+	fmt.Fprintf(w, "First lines:\n")
+	preview := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	for i := range preview {
+		preview[i] = "  " + preview[i]
+	}
+	writeLimited(w, preview, trustPreviewLines)
+}
+
+// writeLimited writes up to limit lines and says how many were left out.
+func writeLimited(w io.Writer, lines []string, limit int) {
+	for i, line := range lines {
+		if i == limit {
+			fmt.Fprintf(w, "  ... %d more lines\n", len(lines)-limit)
+			return
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// noPrompt reports whether AZD_NO_PROMPT asks for no prompts.
+func noPrompt() bool {
+	value, err := strconv.ParseBool(os.Getenv(envVarNoPrompt))
+	return err == nil && value
+}
+
+// displayPath returns path relative to the current directory when it is below
+// it, so messages suggest a command that can be copied, or else path itself.
+func displayPath(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
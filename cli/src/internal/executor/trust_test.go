@@ -0,0 +1,343 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jongio/azd-exec/cli/src/internal/trust"
+)
+
+// useTrustPrompt stands in for the terminal: confirmation can be asked for, and
+// is answered with answer. It returns how many times it was asked.
+func useTrustPrompt(t *testing.T, answer bool) *int {
+	t.Helper()
+	oldTerminal, oldConfirm := stdinIsTerminal, confirmTrust
+	t.Cleanup(func() { stdinIsTerminal, confirmTrust = oldTerminal, oldConfirm })
+	asked := 0
+	stdinIsTerminal = func() bool { return true }
+	confirmTrust = func(string) (bool, error) {
+		asked++
+		return answer, nil
+	}
+	return &asked
+}
+
+// trustTestScript writes a bash script and points the trust store at a temporary file.
+func trustTestScript(t *testing.T, content string) string {
+	t.Helper()
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+	dir := t.TempDir()
+	t.Setenv(trust.EnvVarTrustFile, filepath.Join(dir, "trust.json"))
+	t.Setenv(envVarNoPrompt, "")
+	script := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(script, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestConfigValidate_Trust(t *testing.T) {
+	for _, mode := range []TrustMode{TrustOff, TrustPrompt, TrustRequire} {
+		if err := (&Config{Trust: mode}).Validate(); err != nil {
+			t.Errorf("Validate() with %q: %v", mode, err)
+		}
+	}
+	var validationErr *ValidationError
+	if err := (&Config{Trust: "always"}).Validate(); !errors.As(err, &validationErr) || validationErr.Field != "trust" {
+		t.Errorf("Validate() error = %v, want ValidationError for trust", err)
+	}
+}
+
+func TestTrust_PromptTrustsConfirmedScript(t *testing.T) {
+	script := trustTestScript(t, "#!/bin/bash\necho deployed\n")
+	asked := useTrustPrompt(t, true)
+
+	var stdout bytes.Buffer
+	exec, err := New(Config{Shell: "bash", Trust: TrustPrompt, Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	for range 2 {
+		if err := exec.Execute(context.Background(), script); err != nil {
+			t.Fatalf("Execute() error: %v", err)
+		}
+	}
+	if *asked != 1 {
+		t.Errorf("asked %d times, want once", *asked)
+	}
+	if strings.Count(stdout.String(), "deployed") != 2 {
+		t.Errorf("expected the script to run twice, got %q", stdout.String())
+	}
+
+	// A change has to be confirmed again.
+	if err := os.WriteFile(script, []byte("#!/bin/bash\necho changed\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Execute(context.Background(), script); err != nil {
+		t.Fatalf("Execute() after change error: %v", err)
+	}
+	if *asked != 2 {
+		t.Errorf("asked %d times, want twice", *asked)
+	}
+}
+
+func TestTrust_RefusesUntrustedScript(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       TrustMode
+		noPrompt   string
+		answer     bool
+		wantReason string
+	}{
+		{name: "Require", mode: TrustRequire, answer: true, wantReason: "trusted scripts are required"},
+		{name: "Declined", mode: TrustPrompt, wantReason: "not confirmed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := trustTestScript(t, "#!/bin/bash\necho deployed\n")
+			useTrustPrompt(t, tt.answer)
+			t.Setenv(envVarNoPrompt, tt.noPrompt)
+
+			var stdout bytes.Buffer
+			exec, err := New(Config{Shell: "bash", Trust: tt.mode, Stdout: &stdout})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			_, err = exec.RunFile(context.Background(), script)
+
+			var untrusted *UntrustedScriptError
+			if !errors.As(err, &untrusted) || !strings.Contains(err.Error(), tt.wantReason) {
+				t.Fatalf("RunFile() error = %v, want UntrustedScriptError mentioning %q", err, tt.wantReason)
+			}
+			if stdout.Len() != 0 {
+				t.Errorf("expected the script not to run, got %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestTrust_PromptWarnsWhenNobodyCanConfirm(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		noPrompt string
+		terminal bool
+	}{
+		{name: "No prompt", noPrompt: "true", terminal: true},
+		{name: "No terminal"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			script := trustTestScript(t, "#!/bin/bash\necho deployed\n")
+			asked := useTrustPrompt(t, false)
+			stdinIsTerminal = func() bool { return tt.terminal }
+			t.Setenv(envVarNoPrompt, tt.noPrompt)
+
+			var stdout, stderr bytes.Buffer
+			exec, err := New(Config{Shell: "bash", Trust: TrustPrompt, Stdout: &stdout, Stderr: &stderr})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			if err := exec.Execute(context.Background(), script); err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if *asked != 0 || !strings.Contains(stdout.String(), "deployed") || !strings.Contains(stderr.String(), "not trusted") {
+				t.Errorf("asked %d times, stdout %q, stderr %q; want the script run with a warning", *asked, stdout.String(), stderr.String())
+			}
+
+			store, err := trust.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status, _ := store.Check(script, []byte("#!/bin/bash\necho deployed\n")); status == trust.Trusted {
+				t.Error("a script run without confirmation must not become trusted")
+			}
+		})
+	}
+}
+
+func TestTrust_RequireRunsTrustedScriptAndInline(t *testing.T) {
+	script := trustTestScript(t, "#!/bin/bash\necho deployed\n")
+	store, err := trust.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Pin(script, trust.Hash([]byte("#!/bin/bash\necho deployed\n"))); err != nil {
+		t.Fatal(err)
+	}
+
+	exec, err := New(Config{Shell: "bash", Trust: TrustRequire, Stdout: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := exec.Execute(context.Background(), script); err != nil {
+		t.Errorf("Execute() of a pinned script error: %v", err)
+	}
+	if err := exec.ExecuteInline(context.Background(), "echo inline"); err != nil {
+		t.Errorf("ExecuteInline() error: %v", err)
+	}
+
+	var validationErr *ValidationError
+	if err := exec.ExecuteReader(context.Background(), strings.NewReader("echo hi")); !errors.As(err, &validationErr) || validationErr.Field != "trust" {
+		t.Errorf("ExecuteReader() error = %v, want ValidationError for trust", err)
+	}
+}
+
+func TestTrust_ExecuteEachChecksEveryScriptFirst(t *testing.T) {
+	first := trustTestScript(t, "#!/bin/bash\necho first\n")
+	second := filepath.Join(filepath.Dir(first), "second.sh")
+	if err := os.WriteFile(second, []byte("#!/bin/bash\necho second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := trust.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Trust(first, []byte("#!/bin/bash\necho first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	exec, err := New(Config{Shell: "bash", Trust: TrustRequire, Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	_, err = exec.ExecuteEach(context.Background(), []string{first, second}, EachOptions{})
+
+	var untrusted *UntrustedScriptError
+	if !errors.As(err, &untrusted) || !strings.HasSuffix(untrusted.Path, "second.sh") {
+		t.Fatalf("ExecuteEach() error = %v, want UntrustedScriptError for second.sh", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected no script to run, got %q", stdout.String())
+	}
+}
+
+func TestWriteTrustSummary(t *testing.T) {
+	dir := t.TempDir()
+	store, err := trust.OpenFile(filepath.Join(dir, "trust.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "deploy.sh")
+	before := []byte("echo one\necho two\n")
+	after := []byte("echo one\necho 2\n")
+
+	var untrusted bytes.Buffer
+	writeTrustSummary(&untrusted, store, script, before, trust.Untrusted, trust.Entry{})
+	for _, want := range []string{"has not run", trust.Hash(before), "2 lines", "  echo two"} {
+		if !strings.Contains(untrusted.String(), want) {
+			t.Errorf("expected %q in summary:\n%s", want, untrusted.String())
+		}
+	}
+
+	entry, err := store.Trust(script, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed bytes.Buffer
+	writeTrustSummary(&changed, store, script, after, trust.Changed, entry)
+	for _, want := range []string{"has changed since it was trusted", "-echo two", "+echo 2"} {
+		if !strings.Contains(changed.String(), want) {
+			t.Errorf("expected %q in summary:\n%s", want, changed.String())
+		}
+	}
+}
+
+func TestTrust_WatchTrustsEditsAfterConfirmation(t *testing.T) {
+	for _, mode := range []TrustMode{TrustPrompt, TrustRequire} {
+		t.Run(string(mode), func(t *testing.T) {
+			script := trustTestScript(t, "#!/bin/bash\necho run >> \"$0.log\"\n")
+			asked := useTrustPrompt(t, true)
+			if mode == TrustRequire {
+				store, err := trust.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := store.Trust(script, []byte("#!/bin/bash\necho run >> \"$0.log\"\n")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			exec, err := New(Config{Shell: "bash", Trust: mode, Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				done <- exec.Watch(ctx, script, WatchOptions{Interval: 20 * time.Millisecond, Debounce: 20 * time.Millisecond})
+			}()
+
+			waitForLines := func(n int) {
+				t.Helper()
+				deadline := time.Now().Add(10 * time.Second)
+				for time.Now().Before(deadline) {
+					if content, _ := os.ReadFile(script + ".log"); strings.Count(string(content), "\n") >= n {
+						return
+					}
+					time.Sleep(20 * time.Millisecond)
+				}
+				t.Fatalf("timed out waiting for run %d", n)
+			}
+			waitForLines(1)
+			edited := []byte("#!/bin/bash\necho run >> \"$0.log\"\necho edited >> \"$0.log\"\n")
+			if err := os.WriteFile(script, edited, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if mode == TrustRequire {
+				var untrusted *UntrustedScriptError
+				select {
+				case err := <-done:
+					if !errors.As(err, &untrusted) {
+						t.Fatalf("Watch() error = %v, want UntrustedScriptError", err)
+					}
+				case <-time.After(10 * time.Second):
+					t.Fatal("Watch() did not stop on an untrusted change")
+				}
+				return
+			}
+
+			waitForLines(3)
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Watch() error: %v", err)
+			}
+			if *asked != 1 {
+				t.Errorf("asked %d times, want only when the session started", *asked)
+			}
+			store, err := trust.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status, _ := store.Check(script, edited); status != trust.Trusted {
+				t.Errorf("expected the edited version to be trusted, got %v", status)
+			}
+		})
+	}
+}
+
+func TestReadLine_LeavesTheRestOfTheInput(t *testing.T) {
+	r := strings.NewReader("yes\nscript input\n")
+	line, err := readLine(r)
+	if err != nil || line != "yes" {
+		t.Fatalf("readLine() = %q, %v; want yes", line, err)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != "script input\n" {
+		t.Errorf("remaining input = %q, want the script's input", rest)
+	}
+	if line, err := readLine(strings.NewReader("y")); err != nil || line != "y" {
+		t.Errorf("readLine() at EOF = %q, %v; want y", line, err)
+	}
+}
@@ -0,0 +1,309 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watch defaults, used when WatchOptions leaves them unset.
+const (
+	DefaultWatchInterval = 500 * time.Millisecond
+	DefaultWatchDebounce = 300 * time.Millisecond
+)
+
+// watchSkipDirs are directories never searched for files matching ** patterns.
+var watchSkipDirs = map[string]bool{".git": true, "node_modules": true}
+
+// WatchOptions controls how Watch reruns a script.
+type WatchOptions struct {
+	// Patterns are glob patterns of further files to watch, relative to the current
+	// directory. A ** path segment matches any number of directories.
+	Patterns []string
+
+	// Interval is how often watched files are checked for changes.
+	Interval time.Duration
+
+	// Debounce is how long files must stay unchanged after a change before the script reruns.
+	Debounce time.Duration
+}
+
+// fileStamp identifies a version of a watched file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchRun is a script run started by Watch.
+type watchRun struct {
+	cancel context.CancelFunc
+	done   chan error
+}
+
+// Watch runs script, a file if it exists and an inline script otherwise, and
+// reruns it whenever the script file or a file matching opts.Patterns changes,
+// until ctx is done. Files are polled, which works on every platform and file
+// system. A run still going when files change is interrupted, and killed after
+// the grace period, before the next one starts. The environment, including
+// Key Vault references, is resolved once for the whole session.
+// A script file is checked against the trust store once, before the first run;
+// the versions saved while watching are trusted as they change, except under
+// TrustRequire, where a change that is not trusted ends the session.
+// Returns nil when ctx is done, or an error if the first run cannot be prepared.
+func (e *Executor) Watch(ctx context.Context, script string, opts WatchOptions) error {
+	inv, err := e.scriptInvocation(script)
+	if err != nil {
+		return err
+	}
+	var files []string
+	if !inv.isInline {
+		files = append(files, inv.scriptOrPath)
+	}
+	for _, pattern := range opts.Patterns {
+		if _, err := filepath.Match(filepath.ToSlash(pattern), ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return &ValidationError{Field: "watch", Reason: fmt.Sprintf("%q is not a valid glob pattern", pattern)}
+		}
+	}
+	if len(files) == 0 && len(opts.Patterns) == 0 {
+		return &ValidationError{Field: "watch", Reason: "inline scripts need at least one glob pattern to watch"}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+
+	if err := e.checkTrust(inv); err != nil {
+		return err
+	}
+	env, err := e.resolveEnvironment(ctx)
+	if err != nil {
+		return err
+	}
+
+	notices := e.config.Stderr
+	if notices == nil {
+		notices = os.Stderr
+	}
+
+	start := func(inv invocation) *watchRun {
+		runCtx, cancel := context.WithCancel(ctx)
+		r := &watchRun{cancel: cancel, done: make(chan error, 1)}
+		go func() {
+			_, err := e.run(runCtx, inv, env)
+			r.done <- err
+		}()
+		return r
+	}
+
+	// Notices are only written while no run is writing to the same stream.
+	fmt.Fprintf(notices, "[watch] watching %s; press Ctrl+C to stop\n", describeWatched(files, opts.Patterns))
+	snapshot := watchSnapshot(files, opts.Patterns)
+	current := start(inv)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	debounceTimer := time.NewTimer(debounce)
+	debounceTimer.Stop()
+	var changed string
+	lastExit := ""
+
+	for {
+		var done <-chan error
+		if current != nil {
+			done = current.done
+		}
+
+		select {
+		case <-ctx.Done():
+			if current != nil {
+				current.cancel()
+				<-current.done
+			}
+			return nil
+
+		case err := <-done:
+			current.cancel()
+			current = nil
+			lastExit = fmt.Sprintf("exited with code %d", exitCodeOf(err))
+			fmt.Fprintf(notices, "[watch] %s; waiting for changes\n", lastExit)
+
+		case <-ticker.C:
+			next := watchSnapshot(files, opts.Patterns)
+			if path, ok := firstChange(snapshot, next); ok {
+				snapshot, changed = next, path
+				debounceTimer.Reset(debounce)
+			}
+
+		case <-debounceTimer.C:
+			previous := lastExit
+			if current != nil {
+				current.cancel()
+				err := <-current.done
+				previous = fmt.Sprintf("stopped, exit code %d", exitCodeOf(err))
+				current = nil
+			}
+			writeWatchSeparator(notices, changed, previous)
+
+			inv, err := e.scriptInvocation(script)
+			if err == nil {
+				err = e.retrust(inv)
+			}
+			var untrusted *UntrustedScriptError
+			if errors.As(err, &untrusted) {
+				return err
+			}
+			if err != nil {
+				fmt.Fprintf(notices, "[watch] cannot run %s: %v; waiting for changes\n", filepath.Base(script), err)
+				continue
+			}
+			current = start(inv)
+		}
+	}
+}
+
+// writeWatchSeparator marks the start of a rerun in the output.
+func writeWatchSeparator(w io.Writer, changed, previous string) {
+	fmt.Fprintf(w, "\n%s\n[watch] %s changed; rerunning (previous run %s)\n%s\n", strings.Repeat("-", 60), changed, previous, strings.Repeat("-", 60))
+}
+
+// describeWatched summarizes the watched files and patterns for the start message.
+func describeWatched(files, patterns []string) string {
+	parts := make([]string, 0, len(files)+len(patterns))
+	for _, f := range files {
+		parts = append(parts, filepath.Base(f))
+	}
+	parts = append(parts, patterns...)
+	return strings.Join(parts, ", ")
+}
+
+// watchSnapshot returns the stamps of files and of the files matching patterns.
+// Files that do not exist are left out, so that deleting one counts as a change.
+func watchSnapshot(files, patterns []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	add := func(path string) {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	for _, f := range files {
+		add(f)
+	}
+	for _, pattern := range patterns {
+		for _, path := range globFiles(pattern) {
+			add(path)
+		}
+	}
+	return stamps
+}
+
+// firstChange returns the first path, in sorted order, that was added, removed or modified.
+func firstChange(before, after map[string]fileStamp) (string, bool) {
+	var changed []string
+	for path, stamp := range after {
+		if old, ok := before[path]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return "", false
+	}
+	sort.Strings(changed)
+	return changed[0], true
+}
+
+// globFiles returns the paths matching pattern. Unlike filepath.Glob, a **
+// segment matches any number of directories, e.g. migrations/**/*.sql.
+func globFiles(pattern string) []string {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	if !strings.Contains(pattern, "**") {
+		matches, _ := filepath.Glob(filepath.FromSlash(pattern))
+		return matches
+	}
+
+	// Walk from the longest leading part of the pattern without wildcards.
+	segments := strings.Split(pattern, "/")
+	base := ""
+	for len(segments) > 0 && !strings.ContainsAny(segments[0], "*?[") {
+		base = pathJoin(base, segments[0])
+		segments = segments[1:]
+	}
+	root := base
+	if root == "" {
+		root = "."
+	}
+
+	var matches []string
+	_ = filepath.WalkDir(filepath.FromSlash(root), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != filepath.FromSlash(root) && watchSkipDirs[d.Name()] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), path)
+		if err == nil && matchSegments(segments, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches
+}
+
+// pathJoin joins slash-separated path parts, keeping a leading "/" of absolute patterns.
+func pathJoin(base, segment string) string {
+	if base == "" {
+		if segment == "" {
+			return "/"
+		}
+		return segment
+	}
+	if strings.HasSuffix(base, "/") {
+		return base + segment
+	}
+	return base + "/" + segment
+}
+
+// matchSegments reports whether the path segments match the pattern segments,
+// where a ** segment matches zero or more path segments.
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
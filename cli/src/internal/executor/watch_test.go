@@ -0,0 +1,169 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.sql", "db/b.sql", "db/nested/c.sql", "db/readme.md", "node_modules/pkg/d.sql"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "*.sql", want: []string{"a.sql"}},
+		{pattern: "db/*.sql", want: []string{"db/b.sql"}},
+		{pattern: "db/**/*.sql", want: []string{"db/b.sql", "db/nested/c.sql"}},
+		{pattern: "**/*.sql", want: []string{"a.sql", "db/b.sql", "db/nested/c.sql"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			var got []string
+			for _, path := range globFiles(filepath.Join(dir, tt.pattern)) {
+				rel, _ := filepath.Rel(dir, path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("globFiles(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstChange(t *testing.T) {
+	now := time.Now()
+	before := map[string]fileStamp{"a": {modTime: now, size: 1}, "b": {modTime: now, size: 1}}
+
+	if _, ok := firstChange(before, before); ok {
+		t.Error("expected no change for identical snapshots")
+	}
+	modified := map[string]fileStamp{"a": {modTime: now, size: 1}, "b": {modTime: now.Add(time.Second), size: 1}}
+	if path, ok := firstChange(before, modified); !ok || path != "b" {
+		t.Errorf("firstChange() = %q, %v; want b", path, ok)
+	}
+	removed := map[string]fileStamp{"b": {modTime: now, size: 1}}
+	if path, ok := firstChange(before, removed); !ok || path != "a" {
+		t.Errorf("firstChange() = %q, %v; want a", path, ok)
+	}
+}
+
+func TestWatch_Validation(t *testing.T) {
+	exec, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	for name, opts := range map[string]WatchOptions{
+		"inline without globs": {},
+		"invalid glob":         {Patterns: []string{"[z-a"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := exec.Watch(context.Background(), "echo hi", opts)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != "watch" {
+				t.Fatalf("expected ValidationError for watch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWatch_RerunsOnChange(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("Skipping bash test on Windows")
+	}
+
+	provider := &staticProvider{scheme: "watchtest", values: map[string]string{"watchtest://token": "s3cret"}}
+	useSecretProvider(t, provider)
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "runs.log")
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("one"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// The script never finishes on its own, so the rerun has to stop it.
+	script := filepath.Join(dir, "loop.sh")
+	body := "#!/bin/bash\necho \"$TOKEN\" >> '" + log + "'\nexec sleep 30\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	exec, err := New(Config{
+		Shell:        "bash",
+		GracePeriod:  100 * time.Millisecond,
+		EnvOverrides: []string{"TOKEN=watchtest://token"},
+		Stdout:       &bytes.Buffer{},
+		Stderr:       &stderr,
+		NoMask:       true,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Watch(ctx, script, WatchOptions{Patterns: []string{filepath.Join(dir, "*.txt")}, Interval: 20 * time.Millisecond, Debounce: 20 * time.Millisecond})
+	}()
+
+	waitForRuns := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if content, _ := os.ReadFile(log); strings.Count(string(content), "\n") >= n {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for run %d", n)
+	}
+
+	waitForRuns(1)
+	if err := os.WriteFile(input, []byte("two, longer"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitForRuns(2)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Watch() error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Watch() did not return after cancellation")
+	}
+
+	content, _ := os.ReadFile(log)
+	if string(content) != "s3cret\ns3cret\n" {
+		t.Errorf("runs.log = %q, want the resolved secret from two runs", content)
+	}
+	if provider.calls != 1 {
+		t.Errorf("secret provider called %d times, want once per watch session", provider.calls)
+	}
+	if !strings.Contains(stderr.String(), "input.txt changed; rerunning (previous run stopped") {
+		t.Errorf("expected a rerun separator, got:\n%s", stderr.String())
+	}
+}
@@ -0,0 +1,485 @@
+// Package history records the scripts azd exec runs in a project, so they can
+// be listed with `azd exec history` and run again with `azd exec rerun`.
+// Records are stored as JSON lines in the project's .azure directory. They hold
+// what is needed to run a script again, but never secret values: inline scripts
+// are recorded by hash, script arguments by hash unless the user asks to keep
+// them, and --env values only when they are secret references. Hashes are keyed
+// with a random per-install key, so a short secret cannot be found by hashing
+// guesses.
+package history
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+	"github.com/jongio/azd-exec/cli/src/internal/project"
+)
+
+// FileName is the history file, stored in the project's .azure directory.
+const FileName = "exec-history.jsonl"
+
+// MaxRecords is how many records are kept; older ones are dropped.
+const MaxRecords = 500
+
+// StdinScript is the script recorded for scripts read from stdin.
+const StdinScript = "-"
+
+// Run statuses.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrNotFound indicates that no record has the requested ID.
+var ErrNotFound = errors.New("no run with this ID in the execution history")
+
+// hashKeyFileName is the file, in the azd-exec configuration directory, that
+// holds the key of recorded hashes.
+const hashKeyFileName = "history.key"
+
+// mu serializes writes to history files within the process. Across processes
+// they are serialized by a lock on the history's lock file, so that runs
+// finishing at the same time get distinct IDs.
+var mu sync.Mutex
+
+// hashKeyPath returns the file that holds the key of recorded hashes.
+var hashKeyPath = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "azd-exec", hashKeyFileName), nil
+}
+
+var (
+	hashKeyOnce sync.Once
+	hashKey     []byte
+)
+
+// Record is one recorded script run.
+type Record struct {
+	// ID identifies the run within the project's history.
+	ID int `json:"id"`
+
+	// Time is when the run started.
+	Time time.Time `json:"time"`
+
+	// Environment is the azd environment the script ran against, if any.
+	Environment string `json:"environment,omitempty"`
+
+	// Script is the absolute path of a script file, or StdinScript.
+	// InlineHash is set instead for inline scripts.
+	Script     string `json:"script,omitempty"`
+	InlineHash string `json:"inlineHash,omitempty"`
+
+	// Shell is the shell or interpreter that ran the script.
+	Shell string `json:"shell,omitempty"`
+
+	// Args are the script's arguments, recorded only when the user asks for it
+	// because they may hold secrets. ArgsHash is always set for a run that had
+	// arguments, so a run whose arguments were not recorded is recognized.
+	Args     []string `json:"args,omitempty"`
+	ArgsHash string   `json:"argsHash,omitempty"`
+
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	ExitCode   int    `json:"exitCode"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"durationMs"`
+
+	// Options are the settings the run was started with.
+	Options Options `json:"options"`
+}
+
+// Options are the executor settings a recorded run is repeated with.
+type Options struct {
+	// Shell is the shell that was asked for; empty means it was detected.
+	Shell string `json:"shell,omitempty"`
+
+	Interactive         bool  `json:"interactive,omitempty"`
+	StopOnKeyVaultError bool  `json:"stopOnKeyVaultError,omitempty"`
+	NoCache             bool  `json:"noCache,omitempty"`
+	CacheTTLMs          int64 `json:"cacheTtlMs,omitempty"`
+	NoMask              bool  `json:"noMask,omitempty"`
+	TimeoutMs           int64 `json:"timeoutMs,omitempty"`
+	GracePeriodMs       int64 `json:"gracePeriodMs,omitempty"`
+	Retries             int   `json:"retries,omitempty"`
+	RetryDelayMs        int64 `json:"retryDelayMs,omitempty"`
+	RetryOnExitCodes    []int `json:"retryOnExitCodes,omitempty"`
+
+	// WorkingDir is the working directory option: an absolute path, "project" or "script".
+	WorkingDir string `json:"workingDirOption,omitempty"`
+
+	// CaptureOutput puts the script's output in the JSON result; it applies
+	// again only when the rerun also uses --output json.
+	CaptureOutput bool `json:"captureOutput,omitempty"`
+
+	// LogFile, StdoutFile and StderrFile are absolute paths.
+	LogFile    string `json:"logFile,omitempty"`
+	StdoutFile string `json:"stdoutFile,omitempty"`
+	StderrFile string `json:"stderrFile,omitempty"`
+	Tee        bool   `json:"tee,omitempty"`
+	Timestamps bool   `json:"timestamps,omitempty"`
+
+	// CleanEnv started the script from only the azd environment's values, the
+	// essential variables and those matching PassEnv. It is not part of
+	// executor.Config, so callers record it themselves.
+	CleanEnv bool     `json:"cleanEnv,omitempty"`
+	PassEnv  []string `json:"passEnv,omitempty"`
+
+	EnvFiles []string `json:"envFiles,omitempty"`
+	Unset    []string `json:"unset,omitempty"`
+
+	// Env holds the --env overrides whose values are secret references.
+	// OmittedEnv names the other overrides, whose values are not recorded.
+	Env        []string `json:"env,omitempty"`
+	OmittedEnv []string `json:"omittedEnv,omitempty"`
+}
+
+// NewRecord describes a finished run of script, started with config against
+// the azd environment named environment. Its ID is assigned by Append. The
+// script's arguments are recorded only by hash; callers set Args to keep them.
+func NewRecord(config executor.Config, script, environment string, result *executor.Result) Record {
+	rec := Record{
+		Time:        result.StartTime,
+		Environment: environment,
+		Shell:       result.Shell,
+		WorkingDir:  result.WorkingDir,
+		ExitCode:    result.ExitCode,
+		Status:      StatusSucceeded,
+		DurationMs:  result.DurationMs,
+		Options: Options{
+			Shell:               config.Shell,
+			Interactive:         config.Interactive,
+			StopOnKeyVaultError: config.StopOnKeyVaultError,
+			NoCache:             config.NoCache,
+			CacheTTLMs:          config.CacheTTL.Milliseconds(),
+			NoMask:              config.NoMask,
+			TimeoutMs:           config.Timeout.Milliseconds(),
+			GracePeriodMs:       config.GracePeriod.Milliseconds(),
+			Retries:             config.Retries,
+			RetryDelayMs:        config.RetryDelay.Milliseconds(),
+			RetryOnExitCodes:    config.RetryOnExitCodes,
+			WorkingDir:          config.WorkingDir,
+			CaptureOutput:       config.CaptureOutput,
+			LogFile:             optionalAbsPath(config.LogFile),
+			StdoutFile:          optionalAbsPath(config.StdoutFile),
+			StderrFile:          optionalAbsPath(config.StderrFile),
+			Tee:                 config.Tee,
+			Timestamps:          config.Timestamps,
+			Unset:               config.Unset,
+		},
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if result.ExitCode != 0 || result.Error != "" {
+		rec.Status = StatusFailed
+	}
+
+	if len(config.Args) > 0 {
+		rec.ArgsHash = hashArgs(config.Args)
+	}
+
+	switch {
+	case script == StdinScript:
+		rec.Script = StdinScript
+	case isFile(script):
+		rec.Script = absPath(script)
+	default:
+		rec.InlineHash = hash([]byte(script))
+	}
+
+	if wd := config.WorkingDir; wd != "" && wd != executor.WorkingDirProject && wd != executor.WorkingDirScript {
+		rec.Options.WorkingDir = absPath(wd)
+	}
+	for _, path := range config.EnvFiles {
+		rec.Options.EnvFiles = append(rec.Options.EnvFiles, absPath(path))
+	}
+	for _, kv := range config.EnvOverrides {
+		key, value, _ := strings.Cut(kv, "=")
+		if executor.IsSecretReference(value) {
+			rec.Options.Env = append(rec.Options.Env, kv)
+		} else {
+			rec.Options.OmittedEnv = append(rec.Options.OmittedEnv, key)
+		}
+	}
+	return rec
+}
+
+// Rerunnable returns an error explaining why the run cannot be repeated, or nil.
+func (r Record) Rerunnable() error {
+	switch {
+	case r.InlineHash != "":
+		return fmt.Errorf("run %d was an inline script, which is recorded only by its hash and cannot be rerun", r.ID)
+	case r.Script == StdinScript:
+		return fmt.Errorf("run %d read its script from stdin, which is not recorded and cannot be rerun", r.ID)
+	case r.ArgsHash != "" && len(r.Args) == 0:
+		return fmt.Errorf("run %d had arguments, which are recorded only with --record-args, and cannot be rerun", r.ID)
+	}
+	return nil
+}
+
+// Config rebuilds the executor configuration the run was started with.
+// Overrides named in Options.OmittedEnv are not included, and neither is the
+// starting environment of an Options.CleanEnv run, which callers build.
+func (r Record) Config() executor.Config {
+	o := r.Options
+	return executor.Config{
+		Shell:               o.Shell,
+		Interactive:         o.Interactive,
+		StopOnKeyVaultError: o.StopOnKeyVaultError,
+		NoCache:             o.NoCache,
+		CacheTTL:            time.Duration(o.CacheTTLMs) * time.Millisecond,
+		NoMask:              o.NoMask,
+		Args:                r.Args,
+		Timeout:             time.Duration(o.TimeoutMs) * time.Millisecond,
+		GracePeriod:         time.Duration(o.GracePeriodMs) * time.Millisecond,
+		Retries:             o.Retries,
+		RetryDelay:          time.Duration(o.RetryDelayMs) * time.Millisecond,
+		RetryOnExitCodes:    o.RetryOnExitCodes,
+		WorkingDir:          o.WorkingDir,
+		CaptureOutput:       o.CaptureOutput,
+		LogFile:             o.LogFile,
+		StdoutFile:          o.StdoutFile,
+		StderrFile:          o.StderrFile,
+		Tee:                 o.Tee,
+		Timestamps:          o.Timestamps,
+		EnvFiles:            o.EnvFiles,
+		EnvOverrides:        o.Env,
+		Unset:               o.Unset,
+	}
+}
+
+// Path returns the history file of the project at root.
+func Path(root string) string {
+	return filepath.Join(root, ".azure", FileName)
+}
+
+// RecordRun appends rec to the history of the project containing startDir and
+// returns it with its ID. Runs outside a project are not recorded.
+func RecordRun(startDir string, rec Record) (Record, error) {
+	root, err := project.FindRoot(startDir)
+	if errors.Is(err, project.ErrNoProject) {
+		return rec, nil
+	}
+	if err != nil {
+		return rec, err
+	}
+	return Append(root, rec)
+}
+
+// Append adds rec to the history of the project at root with the next free ID
+// and returns it. The oldest records are dropped beyond MaxRecords.
+func Append(root string, rec Record) (Record, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := Path(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return rec, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	unlock, err := lock(path)
+	if err != nil {
+		return rec, err
+	}
+	defer unlock()
+
+	records, err := Load(root)
+	if err != nil {
+		return rec, err
+	}
+	rec.ID = 1
+	if len(records) > 0 {
+		rec.ID = records[len(records)-1].ID + 1
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+
+	if len(records) >= MaxRecords {
+		records = append(records[len(records)-MaxRecords+1:], rec)
+		return rec, write(path, records)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) // #nosec G304 -- path is inside the project's .azure directory
+	if err != nil {
+		return rec, fmt.Errorf("failed to open history file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return rec, fmt.Errorf("failed to write history file: %w", err)
+	}
+	return rec, f.Close()
+}
+
+// Load returns the recorded runs of the project at root, oldest first.
+// Lines that cannot be parsed are skipped.
+func Load(root string) ([]Record, error) {
+	f, err := os.Open(Path(root)) // #nosec G304 -- path is inside the project's .azure directory
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil && rec.ID > 0 {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return records, nil
+}
+
+// Find returns the record with the given ID from the history of the project at root.
+func Find(root string, id int) (Record, error) {
+	records, err := Load(root)
+	if err != nil {
+		return Record{}, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].ID == id {
+			return records[i], nil
+		}
+	}
+	return Record{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+}
+
+// lock waits for an exclusive lock on the lock file of the history file at path
+// and returns the function that releases it.
+func lock(path string) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600) // #nosec G304 -- path is inside the project's .azure directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock history file: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to lock history file: %w", err)
+	}
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
+
+// write replaces the history file with records.
+func write(path string, records []Record) error {
+	var b strings.Builder
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	// Write to a temporary file and rename it so readers never see a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), FileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// isFile reports whether path names an existing regular file.
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// hashArgs returns the hash of args, each ending in a NUL so that different
+// ways of splitting the same text hash differently.
+func hashArgs(args []string) string {
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString(arg)
+		b.WriteByte(0)
+	}
+	return hash([]byte(b.String()))
+}
+
+// hash returns the HMAC-SHA256 of data with the per-install key as "hmac-sha256:<hex>".
+func hash(data []byte) string {
+	mac := hmac.New(sha256.New, installHashKey())
+	mac.Write(data)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// installHashKey returns the key of recorded hashes, creating a random one in
+// the file named by hashKeyPath if there is none. If the file cannot be read
+// or created, a random key for this process is used, so hashes are never unkeyed.
+func installHashKey() []byte {
+	hashKeyOnce.Do(func() {
+		key := make([]byte, 32)
+		_, _ = rand.Read(key) // never fails; it crashes the program instead
+		hashKey = key
+
+		path, err := hashKeyPath()
+		if err != nil || os.MkdirAll(filepath.Dir(path), 0o700) != nil {
+			return
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) // #nosec G304 -- path is within the user configuration directory
+		if err == nil {
+			_, werr := f.Write(key)
+			if cerr := f.Close(); werr != nil || cerr != nil {
+				_ = os.Remove(path)
+			}
+			return
+		}
+		// Another run created the key first.
+		if existing, err := os.ReadFile(path); err == nil && len(existing) == len(key) { // #nosec G304 -- path is within the user configuration directory
+			hashKey = existing
+		}
+	})
+	return hashKey
+}
+
+// optionalAbsPath returns path made absolute, or "" when path is empty.
+func optionalAbsPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return absPath(path)
+}
+
+// absPath returns path made absolute, or path itself if that fails.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
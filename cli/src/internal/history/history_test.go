@@ -0,0 +1,235 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jongio/azd-exec/cli/src/internal/executor"
+)
+
+// useHashKeyFile keeps the key of recorded hashes in path for the rest of the test.
+func useHashKeyFile(t *testing.T, path string) {
+	t.Helper()
+	oldPath := hashKeyPath
+	hashKeyPath = func() (string, error) { return path, nil }
+	hashKeyOnce, hashKey = sync.Once{}, nil
+	t.Cleanup(func() {
+		hashKeyPath = oldPath
+		hashKeyOnce, hashKey = sync.Once{}, nil
+	})
+}
+
+func TestNewRecord(t *testing.T) {
+	dir := t.TempDir()
+	useHashKeyFile(t, filepath.Join(dir, "history.key"))
+	script := filepath.Join(dir, "deploy.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/bash\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := executor.Config{
+		Shell:            "bash",
+		Args:             []string{"--target", "prod"},
+		Timeout:          time.Minute,
+		Retries:          2,
+		RetryOnExitCodes: []int{75},
+		WorkingDir:       executor.WorkingDirProject,
+		NoCache:          true,
+		CacheTTL:         time.Hour,
+		LogFile:          filepath.Join(dir, "run.log"),
+		Tee:              true,
+		Timestamps:       true,
+		EnvOverrides:     []string{"DB_PASSWORD=akv://kv/db-password", "API_TOKEN=plain-text-secret"},
+	}
+	result := &executor.Result{Shell: "bash", WorkingDir: dir, ExitCode: 3, DurationMs: 1500, StartTime: time.Now()}
+
+	rec := NewRecord(config, script, "dev", result)
+	if rec.Script != script || rec.InlineHash != "" || rec.Environment != "dev" {
+		t.Errorf("unexpected script or environment: %+v", rec)
+	}
+	if rec.Status != StatusFailed || rec.ExitCode != 3 || rec.DurationMs != 1500 {
+		t.Errorf("unexpected outcome: status %s, exit code %d, duration %d", rec.Status, rec.ExitCode, rec.DurationMs)
+	}
+	if !reflect.DeepEqual(rec.Options.Env, []string{"DB_PASSWORD=akv://kv/db-password"}) || !reflect.DeepEqual(rec.Options.OmittedEnv, []string{"API_TOKEN"}) {
+		t.Errorf("Env = %v, OmittedEnv = %v", rec.Options.Env, rec.Options.OmittedEnv)
+	}
+
+	if len(rec.Args) != 0 || !strings.HasPrefix(rec.ArgsHash, "hmac-sha256:") {
+		t.Errorf("expected the arguments to be recorded only by hash, got Args %v, ArgsHash %q", rec.Args, rec.ArgsHash)
+	}
+	if err := rec.Rerunnable(); err == nil || !strings.Contains(err.Error(), "--record-args") {
+		t.Errorf("Rerunnable() without recorded arguments = %v, want a --record-args error", err)
+	}
+	if split := NewRecord(executor.Config{Args: []string{"--target prod"}}, script, "", result); split.ArgsHash == rec.ArgsHash {
+		t.Error("expected differently split arguments to hash differently")
+	}
+
+	rec.Args = config.Args
+	if err := rec.Rerunnable(); err != nil {
+		t.Errorf("Rerunnable() with recorded arguments: %v", err)
+	}
+	got := rec.Config()
+	want := config
+	want.EnvOverrides = []string{"DB_PASSWORD=akv://kv/db-password"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Config() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewRecord_InlineScriptIsHashed(t *testing.T) {
+	useHashKeyFile(t, filepath.Join(t.TempDir(), "history.key"))
+	rec := NewRecord(executor.Config{}, "echo $TOKEN && curl -H 'x: secret'", "", &executor.Result{})
+	if rec.Script != "" || !strings.HasPrefix(rec.InlineHash, "hmac-sha256:") || len(rec.InlineHash) != len("hmac-sha256:")+64 {
+		t.Fatalf("expected only a hash of the inline script, got %+v", rec)
+	}
+	if rec.Status != StatusSucceeded {
+		t.Errorf("Status = %s, want %s", rec.Status, StatusSucceeded)
+	}
+	if err := rec.Rerunnable(); err == nil {
+		t.Error("expected inline records not to be rerunnable")
+	}
+	if err := NewRecord(executor.Config{}, StdinScript, "", &executor.Result{}).Rerunnable(); err == nil {
+		t.Error("expected stdin records not to be rerunnable")
+	}
+}
+
+func TestHash_KeyedPerInstall(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "config", "history.key")
+	useHashKeyFile(t, keyFile)
+
+	first := hash([]byte("hunter2"))
+	plain := sha256.Sum256([]byte("hunter2"))
+	if first == "hmac-sha256:"+hex.EncodeToString(plain[:]) {
+		t.Error("expected the hash to be keyed")
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Size() != 32 {
+		t.Fatalf("expected a 32-byte key file, got %v, %v", info, err)
+	}
+
+	// A later run reads the same key.
+	hashKeyOnce, hashKey = sync.Once{}, nil
+	if again := hash([]byte("hunter2")); again != first {
+		t.Errorf("hash after reloading the key = %q, want %q", again, first)
+	}
+
+	// Another install has another key.
+	useHashKeyFile(t, filepath.Join(dir, "other", "history.key"))
+	if other := hash([]byte("hunter2")); other == first {
+		t.Error("expected a different key to give a different hash")
+	}
+}
+
+func TestAppend_WaitsForLock(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Dir(Path(root)), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	// Hold the lock as another process would.
+	unlock, err := lock(Path(root))
+	if err != nil {
+		t.Fatalf("lock() error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Append(root, Record{Script: "/app/run.sh", Status: StatusSucceeded})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Append() returned %v while the history was locked", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+}
+
+func TestAppend_DropsOldestRecords(t *testing.T) {
+	root := t.TempDir()
+	for range MaxRecords + 2 {
+		if _, err := Append(root, Record{Script: "/app/run.sh", Status: StatusSucceeded}); err != nil {
+			t.Fatalf("Append() error: %v", err)
+		}
+	}
+
+	records, err := Load(root)
+	if err != nil || len(records) != MaxRecords {
+		t.Fatalf("Load() = %d records, %v; want %d", len(records), err, MaxRecords)
+	}
+	if records[0].ID != 3 || records[len(records)-1].ID != MaxRecords+2 {
+		t.Errorf("IDs run from %d to %d, want 3 to %d", records[0].ID, records[len(records)-1].ID, MaxRecords+2)
+	}
+	entries, err := os.ReadDir(filepath.Dir(Path(root)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != FileName && name != FileName+".lock" {
+			t.Errorf("unexpected file %s left in the history directory", name)
+		}
+	}
+}
+
+func TestAppendLoadFind(t *testing.T) {
+	root := t.TempDir()
+
+	for i, env := range []string{"dev", "test", "dev"} {
+		rec, err := Append(root, Record{Environment: env, Script: "/app/run.sh", Status: StatusSucceeded})
+		if err != nil {
+			t.Fatalf("Append() error: %v", err)
+		}
+		if rec.ID != i+1 {
+			t.Errorf("ID = %d, want %d", rec.ID, i+1)
+		}
+	}
+
+	records, err := Load(root)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Load() = %d records, %v; want 3", len(records), err)
+	}
+	rec, err := Find(root, 2)
+	if err != nil || rec.Environment != "test" {
+		t.Errorf("Find(2) = %+v, %v; want the test run", rec, err)
+	}
+	if _, err := Find(root, 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find(9) error = %v, want ErrNotFound", err)
+	}
+
+	info, err := os.Stat(Path(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o077 != 0 && os.PathSeparator == '/' {
+		t.Errorf("history file permissions = %v, want owner-only", info.Mode().Perm())
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	records, err := Load(t.TempDir())
+	if err != nil || records != nil {
+		t.Errorf("Load() = %v, %v; want no records", records, err)
+	}
+}
+
+func TestRecordRun_OutsideProject(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := RecordRun(dir, Record{Script: "/app/run.sh"})
+	if err != nil || rec.ID != 0 {
+		t.Errorf("RecordRun() = %+v, %v; want nothing recorded", rec, err)
+	}
+	if _, err := os.Stat(Path(dir)); !os.IsNotExist(err) {
+		t.Errorf("expected no history file outside a project, stat error: %v", err)
+	}
+}
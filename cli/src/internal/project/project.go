@@ -0,0 +1,425 @@
+// Package project loads azd exec project configuration.
+// Configuration lives in a .azdexec.yaml file or in the exec: section of azure.yaml
+// at the azd project root, and declares named tasks that can be run with `azd exec run`,
+// custom interpreter profiles that scripts can run with, and scripts to run on azd
+// lifecycle events.
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the dedicated azd exec configuration file.
+const ConfigFileName = ".azdexec.yaml"
+
+// azureYamlNames are the azd project file names, in lookup order.
+var azureYamlNames = []string{"azure.yaml", "azure.yml"}
+
+// ErrNoProject indicates that no azd project root was found.
+var ErrNoProject = errors.New("no azd project found (looked for azure.yaml or " + ConfigFileName + ")")
+
+// ErrNoAzdProject indicates that no directory containing azure.yaml was found.
+var ErrNoAzdProject = errors.New("no azd project found (looked for azure.yaml)")
+
+// Config is the azd exec configuration for a project.
+type Config struct {
+	// Root is the absolute path of the project root directory.
+	Root string `yaml:"-"`
+
+	// Tasks maps task names to their definitions.
+	Tasks map[string]Task `yaml:"tasks"`
+
+	// Interpreters maps interpreter names to custom interpreter profiles.
+	Interpreters map[string]Interpreter `yaml:"interpreters"`
+
+	// Hooks maps azd lifecycle event names to the scripts run for them, in order.
+	Hooks map[string][]Hook `yaml:"hooks"`
+}
+
+// Lifecycle events hooks can be declared for. Project events are raised once per
+// azd command; service events once per service.
+var (
+	ProjectHookEvents = []string{"preprovision", "postprovision", "predeploy", "postdeploy"}
+	ServiceHookEvents = []string{"prepackage"}
+)
+
+// Task is a named script declared in project configuration.
+// Exactly one of Script or Run must be set.
+type Task struct {
+	// Description is a short human-readable summary shown by `azd exec list`.
+	Description string `yaml:"description"`
+
+	// Script is the path of a script file, relative to the project root.
+	Script string `yaml:"script"`
+
+	// Run is an inline script body.
+	Run string `yaml:"run"`
+
+	// Shell overrides shell auto-detection.
+	Shell string `yaml:"shell"`
+
+	// Args are passed to the script before any arguments given on the command line.
+	Args []string `yaml:"args"`
+
+	// WorkingDir is the working directory, relative to the project root.
+	WorkingDir string `yaml:"cwd"`
+
+	// Env holds environment overrides. Values may be Key Vault references.
+	Env map[string]string `yaml:"env"`
+}
+
+// Interpreter is a custom interpreter profile, such as `uv run` or a container
+// wrapper, that can be selected like a shell. The argument templates use the
+// {script} placeholder for the script path or inline script body.
+type Interpreter struct {
+	// Executable is the program to run. Relative paths containing a separator
+	// are resolved against the project root.
+	Executable string `yaml:"executable"`
+
+	// Args are passed to the executable before the templated arguments, e.g. ["run", "python"].
+	Args []string `yaml:"args"`
+
+	// Inline is the argument template for inline scripts, e.g. ["-c", "{script}"].
+	// Empty means the interpreter cannot run inline scripts.
+	Inline []string `yaml:"inline"`
+
+	// File is the argument template for script files. Defaults to ["{script}"].
+	File []string `yaml:"file"`
+
+	// ArgsSeparator, if set, is inserted before script arguments, e.g. "--".
+	ArgsSeparator string `yaml:"argsSeparator"`
+
+	// Extensions are the file extensions that select this interpreter during auto-detection.
+	Extensions []string `yaml:"extensions"`
+}
+
+// Hook is a script run when azd raises a lifecycle event. It has the same fields
+// as a task, without a description.
+type Hook struct {
+	Task `yaml:",inline"`
+
+	// Services limits a service event hook to the named services. Empty means every service.
+	Services []string `yaml:"services"`
+
+	// ContinueOnError lets the azd command go on when the hook fails.
+	ContinueOnError bool `yaml:"continueOnError"`
+}
+
+// azureYaml is the subset of azure.yaml read by azd exec.
+type azureYaml struct {
+	Exec *Config `yaml:"exec"`
+
+	// Azd holds azd's own settings, which are left for azd to check.
+	Azd map[string]any `yaml:",inline"`
+}
+
+// FindRoot walks up from startDir and returns the first directory that
+// contains azure.yaml or .azdexec.yaml. Returns ErrNoProject if none is found.
+func FindRoot(startDir string) (string, error) {
+	return findUp(startDir, append([]string{ConfigFileName}, azureYamlNames...), ErrNoProject)
+}
+
+// FindAzdRoot walks up from startDir and returns the first directory that
+// contains azure.yaml, the azd project root. Returns ErrNoAzdProject if none is found.
+func FindAzdRoot(startDir string) (string, error) {
+	return findUp(startDir, azureYamlNames, ErrNoAzdProject)
+}
+
+// findUp walks up from startDir and returns the first directory that contains
+// one of the named files, or notFound if there is none.
+func findUp(startDir string, names []string, notFound error) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory %q: %w", startDir, err)
+	}
+
+	for {
+		for _, name := range names {
+			if info, statErr := os.Stat(filepath.Join(dir, name)); statErr == nil && !info.IsDir() {
+				return dir, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", notFound
+		}
+		dir = parent
+	}
+}
+
+// Environments returns the names of the azd environments stored under the
+// project's .azure directory, in sorted order. A directory counts as an
+// environment when it contains a .env file.
+func Environments(root string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, ".azure"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read azd environments: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if info, statErr := os.Stat(filepath.Join(root, ".azure", entry.Name(), ".env")); statErr == nil && !info.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load finds the project root from startDir and reads its configuration.
+// Tasks from the exec: section of azure.yaml are loaded first; tasks in
+// .azdexec.yaml override azure.yaml tasks with the same name.
+// Relative script and working directory paths are resolved against the project root.
+func Load(startDir string) (*Config, error) {
+	root, err := FindRoot(startDir)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Root: root, Tasks: map[string]Task{}, Interpreters: map[string]Interpreter{}, Hooks: map[string][]Hook{}}
+
+	for _, name := range azureYamlNames {
+		path := filepath.Join(root, name)
+		data, readErr := os.ReadFile(path) // #nosec G304 -- path is the azd project file
+		if errors.Is(readErr, os.ErrNotExist) {
+			continue
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, readErr)
+		}
+
+		var doc azureYaml
+		if err := decodeStrict(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if doc.Exec != nil {
+			cfg.merge(doc.Exec)
+		}
+		break
+	}
+
+	path := filepath.Join(root, ConfigFileName)
+	data, err := os.ReadFile(path) // #nosec G304 -- path is the project configuration file
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read %s: %w", ConfigFileName, err)
+	default:
+		var file Config
+		if err := decodeStrict(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", ConfigFileName, err)
+		}
+		cfg.merge(&file)
+	}
+
+	for name, task := range cfg.Tasks {
+		if err := task.validate(name); err != nil {
+			return nil, err
+		}
+		cfg.Tasks[name] = task.resolvePaths(root)
+	}
+	if err := cfg.validateInterpreters(); err != nil {
+		return nil, err
+	}
+	for name, interp := range cfg.Interpreters {
+		cfg.Interpreters[name] = interp.resolvePaths(root)
+	}
+	for event, hooks := range cfg.Hooks {
+		for i, hook := range hooks {
+			if err := hook.validate(event, i); err != nil {
+				return nil, err
+			}
+			hooks[i].Task = hook.resolvePaths(root)
+		}
+	}
+
+	return cfg, nil
+}
+
+// decodeStrict decodes a YAML document into v, rejecting fields v does not
+// declare so that misspelled settings are reported instead of ignored.
+// An empty document leaves v unchanged.
+func decodeStrict(data []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// merge copies tasks, interpreters and hooks from other into c, replacing those
+// with the same name and the hooks of the same event.
+func (c *Config) merge(other *Config) {
+	for name, task := range other.Tasks {
+		c.Tasks[name] = task
+	}
+	for name, interp := range other.Interpreters {
+		c.Interpreters[name] = interp
+	}
+	for event, hooks := range other.Hooks {
+		c.Hooks[event] = hooks
+	}
+}
+
+// Task returns the named task.
+func (c *Config) Task(name string) (Task, error) {
+	task, ok := c.Tasks[name]
+	if !ok {
+		return Task{}, fmt.Errorf("task %q not found; run 'azd exec list' to see available tasks", name)
+	}
+	return task, nil
+}
+
+// TaskNames returns task names in sorted order.
+func (c *Config) TaskNames() []string {
+	names := make([]string, 0, len(c.Tasks))
+	for name := range c.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InterpreterNames returns interpreter names in sorted order.
+func (c *Config) InterpreterNames() []string {
+	names := make([]string, 0, len(c.Interpreters))
+	for name := range c.Interpreters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateInterpreters checks that every interpreter names an executable and
+// that no two interpreters claim the same extension.
+func (c *Config) validateInterpreters() error {
+	owners := map[string]string{}
+	for _, name := range c.InterpreterNames() {
+		interp := c.Interpreters[name]
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("interpreter names cannot be empty")
+		}
+		if strings.TrimSpace(interp.Executable) == "" {
+			return fmt.Errorf("interpreter %q must set 'executable'", name)
+		}
+		for _, ext := range interp.Extensions {
+			key := strings.ToLower(ext)
+			if owner, ok := owners[key]; ok {
+				return fmt.Errorf("interpreters %q and %q both claim extension %q", owner, name, ext)
+			}
+			owners[key] = name
+		}
+	}
+	return nil
+}
+
+func (i Interpreter) resolvePaths(root string) Interpreter {
+	if !filepath.IsAbs(i.Executable) && strings.ContainsAny(i.Executable, `/\`) {
+		i.Executable = filepath.Join(root, i.Executable)
+	}
+	return i
+}
+
+// IsInline reports whether the task runs an inline script body.
+func (t Task) IsInline() bool {
+	return t.Script == ""
+}
+
+// EnvOverrides returns Env as KEY=VALUE pairs sorted by key.
+func (t Task) EnvOverrides() []string {
+	keys := make([]string, 0, len(t.Env))
+	for key := range t.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	overrides := make([]string, 0, len(keys))
+	for _, key := range keys {
+		overrides = append(overrides, key+"="+t.Env[key])
+	}
+	return overrides
+}
+
+func (t Task) validate(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("task names cannot be empty")
+	}
+	return t.validateAs(fmt.Sprintf("task %q", name))
+}
+
+// validateAs checks the script and environment of a task or hook described by label.
+func (t Task) validateAs(label string) error {
+	hasScript := strings.TrimSpace(t.Script) != ""
+	hasRun := strings.TrimSpace(t.Run) != ""
+	if hasScript == hasRun {
+		return fmt.Errorf("%s must set exactly one of 'script' or 'run'", label)
+	}
+	for key := range t.Env {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("%s has invalid environment variable name %q", label, key)
+		}
+	}
+	return nil
+}
+
+// HookEvents returns the names of the events that have hooks, sorted.
+func (c *Config) HookEvents() []string {
+	events := make([]string, 0, len(c.Hooks))
+	for event, hooks := range c.Hooks {
+		if len(hooks) > 0 {
+			events = append(events, event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// IsServiceHookEvent reports whether event is raised once per service.
+func IsServiceHookEvent(event string) bool {
+	return slices.Contains(ServiceHookEvents, event)
+}
+
+// AppliesTo reports whether a service event hook runs for the named service.
+func (h Hook) AppliesTo(service string) bool {
+	return len(h.Services) == 0 || slices.Contains(h.Services, service)
+}
+
+func (h Hook) validate(event string, index int) error {
+	if !slices.Contains(ProjectHookEvents, event) && !IsServiceHookEvent(event) {
+		supported := append(append([]string{}, ProjectHookEvents...), ServiceHookEvents...)
+		return fmt.Errorf("unknown hook event %q; supported events are %s", event, strings.Join(supported, ", "))
+	}
+	label := fmt.Sprintf("%s hook #%d", event, index+1)
+	if len(h.Services) > 0 && !IsServiceHookEvent(event) {
+		return fmt.Errorf("%s sets 'services', but %s is not a service event", label, event)
+	}
+	return h.validateAs(label)
+}
+
+func (t Task) resolvePaths(root string) Task {
+	if t.Script != "" && !filepath.IsAbs(t.Script) {
+		t.Script = filepath.Join(root, t.Script)
+	}
+	if t.WorkingDir != "" && !filepath.IsAbs(t.WorkingDir) {
+		t.WorkingDir = filepath.Join(root, t.WorkingDir)
+	}
+	return t
+}
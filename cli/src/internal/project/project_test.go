@@ -0,0 +1,373 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+func TestFindRoot(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "azure.yaml"), "name: demo\n")
+	nested := filepath.Join(root, "src", "api")
+	if err := os.MkdirAll(nested, 0o750); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	got, err := FindRoot(nested)
+	if err != nil {
+		t.Fatalf("FindRoot() error: %v", err)
+	}
+	if got != root {
+		t.Errorf("FindRoot() = %q, want %q", got, root)
+	}
+}
+
+func TestFindRoot_NoProject(t *testing.T) {
+	_, err := FindRoot(t.TempDir())
+	if !errors.Is(err, ErrNoProject) {
+		t.Fatalf("FindRoot() error = %v, want ErrNoProject", err)
+	}
+}
+
+func TestFindAzdRoot_SkipsExecConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "azure.yaml"), "name: demo\n")
+	writeFile(t, filepath.Join(root, "tools", ConfigFileName), "tasks: {}\n")
+
+	got, err := FindAzdRoot(filepath.Join(root, "tools"))
+	if err != nil {
+		t.Fatalf("FindAzdRoot() error: %v", err)
+	}
+	if got != root {
+		t.Errorf("FindAzdRoot() = %q, want %q", got, root)
+	}
+
+	if _, err := FindAzdRoot(t.TempDir()); !errors.Is(err, ErrNoAzdProject) {
+		t.Errorf("FindAzdRoot() error = %v, want ErrNoAzdProject", err)
+	}
+}
+
+func TestLoad_AzureYamlExecSection(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "azure.yaml"), `name: demo
+services:
+  api:
+    project: ./src/api
+exec:
+  tasks:
+    migrate:
+      description: Apply migrations
+      script: scripts/migrate.sh
+      shell: bash
+      args: ["--target", "latest"]
+      cwd: db
+      env:
+        LOG_LEVEL: debug
+        DB_PASSWORD: "@Microsoft.KeyVault(VaultName=v;SecretName=db)"
+`)
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Root != root {
+		t.Errorf("Root = %q, want %q", cfg.Root, root)
+	}
+
+	task, err := cfg.Task("migrate")
+	if err != nil {
+		t.Fatalf("Task() error: %v", err)
+	}
+	if task.Script != filepath.Join(root, "scripts", "migrate.sh") {
+		t.Errorf("Script = %q, want path resolved against root", task.Script)
+	}
+	if task.WorkingDir != filepath.Join(root, "db") {
+		t.Errorf("WorkingDir = %q, want path resolved against root", task.WorkingDir)
+	}
+	if task.IsInline() {
+		t.Error("expected script task not to be inline")
+	}
+	if !reflect.DeepEqual(task.Args, []string{"--target", "latest"}) {
+		t.Errorf("Args = %v", task.Args)
+	}
+	wantEnv := []string{
+		"DB_PASSWORD=@Microsoft.KeyVault(VaultName=v;SecretName=db)",
+		"LOG_LEVEL=debug",
+	}
+	if got := task.EnvOverrides(); !reflect.DeepEqual(got, wantEnv) {
+		t.Errorf("EnvOverrides() = %v, want %v", got, wantEnv)
+	}
+}
+
+func TestLoad_ConfigFileOverridesAzureYaml(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "azure.yaml"), `exec:
+  tasks:
+    hello:
+      run: echo from-azure-yaml
+    seed:
+      script: seed.sh
+`)
+	writeFile(t, filepath.Join(root, ConfigFileName), `tasks:
+  hello:
+    description: Say hello
+    run: echo from-azdexec
+`)
+
+	cfg, err := Load(filepath.Join(root))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	if got := cfg.TaskNames(); !reflect.DeepEqual(got, []string{"hello", "seed"}) {
+		t.Errorf("TaskNames() = %v, want [hello seed]", got)
+	}
+	hello := cfg.Tasks["hello"]
+	if hello.Run != "echo from-azdexec" || !hello.IsInline() {
+		t.Errorf("expected .azdexec.yaml task to win, got %+v", hello)
+	}
+}
+
+func TestLoad_Interpreters(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "azure.yaml"), `exec:
+  interpreters:
+    uv:
+      executable: uv
+      args: [run, python]
+    tsx:
+      executable: npx
+      args: [tsx]
+      extensions: [.ts]
+`)
+	writeFile(t, filepath.Join(root, ConfigFileName), `interpreters:
+  uv:
+    executable: uv
+    args: [run, --frozen, python]
+    inline: ["-c", "{script}"]
+    extensions: [.py]
+  box:
+    executable: ./tools/run-in-container.sh
+    file: ["--script", "{script}"]
+    argsSeparator: "--"
+`)
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	if got := cfg.InterpreterNames(); !reflect.DeepEqual(got, []string{"box", "tsx", "uv"}) {
+		t.Errorf("InterpreterNames() = %v, want [box tsx uv]", got)
+	}
+	want := Interpreter{
+		Executable: "uv",
+		Args:       []string{"run", "--frozen", "python"},
+		Inline:     []string{"-c", "{script}"},
+		Extensions: []string{".py"},
+	}
+	if got := cfg.Interpreters["uv"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected .azdexec.yaml interpreter to win, got %+v", got)
+	}
+	if got, want := cfg.Interpreters["box"].Executable, filepath.Join(root, "tools", "run-in-container.sh"); got != want {
+		t.Errorf("relative executable = %q, want %q", got, want)
+	}
+	if got := cfg.Interpreters["tsx"].Executable; got != "npx" {
+		t.Errorf("executable on PATH = %q, want npx", got)
+	}
+}
+
+func TestLoad_Hooks(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "azure.yaml"), `exec:
+  hooks:
+    preprovision:
+      - run: echo from azure.yaml
+    postdeploy:
+      - run: echo replaced
+`)
+	writeFile(t, filepath.Join(root, ConfigFileName), `hooks:
+  postdeploy:
+    - script: ./scripts/smoke.sh
+      env:
+        API_KEY: akv://myvault/api-key
+    - run: echo done
+      continueOnError: true
+  prepackage:
+    - run: npm run build
+      services: [web]
+`)
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	if got := cfg.HookEvents(); !reflect.DeepEqual(got, []string{"postdeploy", "prepackage", "preprovision"}) {
+		t.Errorf("HookEvents() = %v", got)
+	}
+	postdeploy := cfg.Hooks["postdeploy"]
+	if len(postdeploy) != 2 {
+		t.Fatalf("expected .azdexec.yaml postdeploy hooks to win, got %+v", postdeploy)
+	}
+	if got, want := postdeploy[0].Script, filepath.Join(root, "scripts", "smoke.sh"); got != want {
+		t.Errorf("hook script = %q, want %q", got, want)
+	}
+	if got := postdeploy[0].EnvOverrides(); !reflect.DeepEqual(got, []string{"API_KEY=akv://myvault/api-key"}) {
+		t.Errorf("hook env = %v", got)
+	}
+	if !postdeploy[1].ContinueOnError || !postdeploy[1].IsInline() {
+		t.Errorf("unexpected second hook: %+v", postdeploy[1])
+	}
+
+	build := cfg.Hooks["prepackage"][0]
+	if !IsServiceHookEvent("prepackage") || IsServiceHookEvent("postdeploy") {
+		t.Error("IsServiceHookEvent() misclassifies events")
+	}
+	if !build.AppliesTo("web") || build.AppliesTo("api") {
+		t.Errorf("AppliesTo() does not honor services %v", build.Services)
+	}
+	if !cfg.Hooks["preprovision"][0].AppliesTo("any") {
+		t.Error("hooks without services should apply to every service")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "script and run",
+			file:    ConfigFileName,
+			content: "tasks:\n  both:\n    script: a.sh\n    run: echo hi\n",
+			wantErr: "exactly one of",
+		},
+		{
+			name:    "neither script nor run",
+			file:    ConfigFileName,
+			content: "tasks:\n  empty:\n    description: nothing\n",
+			wantErr: "exactly one of",
+		},
+		{
+			name:    "unknown field",
+			file:    ConfigFileName,
+			content: "tasks:\n  typo:\n    scritp: a.sh\n",
+			wantErr: "failed to parse",
+		},
+		{
+			name:    "interpreter without executable",
+			file:    ConfigFileName,
+			content: "interpreters:\n  uv:\n    args: [run]\n",
+			wantErr: "must set 'executable'",
+		},
+		{
+			name:    "interpreters sharing an extension",
+			file:    ConfigFileName,
+			content: "interpreters:\n  a:\n    executable: a\n    extensions: [.py]\n  b:\n    executable: b\n    extensions: [.PY]\n",
+			wantErr: "both claim extension",
+		},
+		{
+			name:    "unknown hook event",
+			file:    ConfigFileName,
+			content: "hooks:\n  preup:\n    - run: echo hi\n",
+			wantErr: "unknown hook event \"preup\"",
+		},
+		{
+			name:    "hook without script",
+			file:    ConfigFileName,
+			content: "hooks:\n  postdeploy:\n    - shell: bash\n",
+			wantErr: "postdeploy hook #1 must set exactly one of",
+		},
+		{
+			name:    "services on a project event",
+			file:    ConfigFileName,
+			content: "hooks:\n  preprovision:\n    - run: echo hi\n      services: [api]\n",
+			wantErr: "not a service event",
+		},
+		{
+			name:    "unknown field in azure.yaml",
+			file:    "azure.yaml",
+			content: "name: app\nservices:\n  web:\n    host: containerapp\nexec:\n  tasks:\n    typo:\n      scritp: a.sh\n",
+			wantErr: "line 8: field scritp not found",
+		},
+		{
+			name:    "invalid azure.yaml",
+			file:    "azure.yaml",
+			content: "exec: [\n",
+			wantErr: "failed to parse azure.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFile(t, filepath.Join(root, tt.file), tt.content)
+
+			_, err := Load(root)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_EmptyConfigFile(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ConfigFileName), "")
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(cfg.Tasks) != 0 {
+		t.Errorf("expected no tasks, got %v", cfg.Tasks)
+	}
+}
+
+func TestConfigTask_NotFound(t *testing.T) {
+	cfg := &Config{Tasks: map[string]Task{}}
+	_, err := cfg.Task("missing")
+	if err == nil || !strings.Contains(err.Error(), "azd exec list") {
+		t.Fatalf("Task() error = %v, want not found error", err)
+	}
+}
+
+func TestEnvironments(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".azure", "test", ".env"), "A=1\n")
+	writeFile(t, filepath.Join(root, ".azure", "dev", ".env"), "A=2\n")
+	writeFile(t, filepath.Join(root, ".azure", "config.json"), "{}\n")
+	if err := os.MkdirAll(filepath.Join(root, ".azure", "empty"), 0o750); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	got, err := Environments(root)
+	if err != nil {
+		t.Fatalf("Environments() error: %v", err)
+	}
+	if want := []string{"dev", "test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Environments() = %v, want %v", got, want)
+	}
+
+	none, err := Environments(t.TempDir())
+	if err != nil || len(none) != 0 {
+		t.Errorf("expected no environments without .azure, got %v, %v", none, err)
+	}
+}
@@ -0,0 +1,79 @@
+package trust
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffLines is the largest script, in lines, Diff compares line by line.
+const maxDiffLines = 2000
+
+// Diff returns the lines that differ between before and after, as unified-diff-like
+// hunks without context: a "@@ -a,n +b,m @@" header followed by "-" lines from
+// before and "+" lines from after. It returns nil if either side is too long to compare.
+func Diff(before, after []byte) []string {
+	a, b := splitLines(before), splitLines(after)
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return nil
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out, removed, added []string
+	hunkA, hunkB := 0, 0
+	flush := func() {
+		if len(removed)+len(added) == 0 {
+			return
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunkA+1, len(removed), hunkB+1, len(added)))
+		out = append(out, removed...)
+		out = append(out, added...)
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			if len(removed)+len(added) == 0 {
+				hunkA, hunkB = i, j
+			}
+			added = append(added, "+"+b[j])
+			j++
+		default:
+			if len(removed)+len(added) == 0 {
+				hunkA, hunkB = i, j
+			}
+			removed = append(removed, "-"+a[i])
+			i++
+		}
+	}
+	flush()
+	return out
+}
+
+// splitLines splits content into lines without their line endings.
+func splitLines(content []byte) []string {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
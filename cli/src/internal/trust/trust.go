@@ -0,0 +1,248 @@
+// Package trust keeps the script files a user has trusted azd exec to run,
+// keyed by absolute path, with symlinks resolved, and the SHA-256 of their
+// content, much like SSH's known_hosts. A script is trusted only while its
+// content matches the pinned hash, so a script that changes after it was
+// reviewed has to be trusted again.
+//
+// The store is a JSON file in the user's configuration directory, or the file
+// named by AZD_EXEC_TRUST_FILE. A copy of each trusted version is kept next to
+// it, so changes can be shown as a diff when a script is trusted again.
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EnvVarTrustFile names an alternative trust store file.
+const EnvVarTrustFile = "AZD_EXEC_TRUST_FILE"
+
+// maxSnapshotSize is the largest script a copy is kept of for diffs.
+const maxSnapshotSize = 1 << 20
+
+// Status is the trust state of a script file.
+type Status int
+
+const (
+	// Untrusted scripts have never been trusted.
+	Untrusted Status = iota
+	// Changed scripts were trusted with different content.
+	Changed
+	// Trusted scripts match their pinned hash.
+	Trusted
+)
+
+// Entry is a trusted script.
+type Entry struct {
+	Path      string    `json:"path"`
+	SHA256    string    `json:"sha256"`
+	TrustedAt time.Time `json:"trustedAt"`
+}
+
+// Store is the set of trusted scripts.
+type Store struct {
+	path    string
+	entries map[string]Entry
+}
+
+// DefaultPath returns the trust store file: AZD_EXEC_TRUST_FILE if set, or
+// trust.json in the azd-exec directory of the user's configuration directory.
+func DefaultPath() (string, error) {
+	if path := os.Getenv(EnvVarTrustFile); path != "" {
+		return filepath.Abs(path)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "azd-exec", "trust.json"), nil
+}
+
+// Open loads the trust store at DefaultPath.
+func Open() (*Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return OpenFile(path)
+}
+
+// OpenFile loads the trust store kept in path. A missing file is an empty store.
+func OpenFile(path string) (*Store, error) {
+	s := &Store{path: path, entries: map[string]Entry{}}
+	data, err := os.ReadFile(path) // #nosec G304 -- the trust store location is chosen by the user
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust store: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse trust store %s: %w", path, err)
+	}
+	for _, entry := range entries {
+		s.entries[entry.Path] = entry
+	}
+	return s, nil
+}
+
+// Hash returns the SHA-256 of content as a hex string.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Check returns the trust state of the script at path with the given content,
+// and the entry it was last trusted with, if any.
+func (s *Store) Check(path string, content []byte) (Status, Entry) {
+	entry, ok := s.entries[absPath(path)]
+	switch {
+	case !ok:
+		return Untrusted, Entry{}
+	case entry.SHA256 != Hash(content):
+		return Changed, entry
+	}
+	return Trusted, entry
+}
+
+// Trust pins the script at path to content, keeps a copy of content for later
+// diffs and saves the store.
+func (s *Store) Trust(path string, content []byte) (Entry, error) {
+	entry, err := s.pin(path, Hash(content))
+	if err != nil {
+		return entry, err
+	}
+	if len(content) <= maxSnapshotSize {
+		dir := s.snapshotDir()
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return entry, fmt.Errorf("failed to create trust store directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.SHA256), content, 0o600); err != nil {
+			return entry, fmt.Errorf("failed to save trusted script copy: %w", err)
+		}
+	}
+	return entry, nil
+}
+
+// Pin trusts the script at path only while its content has the given SHA-256,
+// without reading it, and saves the store.
+func (s *Store) Pin(path, sha string) (Entry, error) {
+	sha = strings.ToLower(strings.TrimSpace(sha))
+	if len(sha) != sha256.Size*2 {
+		return Entry{}, fmt.Errorf("%q is not a SHA-256 hash", sha)
+	}
+	if _, err := hex.DecodeString(sha); err != nil {
+		return Entry{}, fmt.Errorf("%q is not a SHA-256 hash", sha)
+	}
+	return s.pin(path, sha)
+}
+
+// pin records the entry for path and saves the store.
+func (s *Store) pin(path, sha string) (Entry, error) {
+	entry := Entry{Path: absPath(path), SHA256: sha, TrustedAt: time.Now().UTC()}
+	s.entries[entry.Path] = entry
+	return entry, s.save()
+}
+
+// Remove stops trusting the script at path and saves the store. It reports
+// whether the script was trusted.
+func (s *Store) Remove(path string) (bool, error) {
+	path = absPath(path)
+	entry, ok := s.entries[path]
+	if !ok {
+		return false, nil
+	}
+	delete(s.entries, path)
+	if !s.hashInUse(entry.SHA256) {
+		_ = os.Remove(filepath.Join(s.snapshotDir(), entry.SHA256))
+	}
+	return true, s.save()
+}
+
+// List returns the trusted scripts sorted by path.
+func (s *Store) List() []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// Snapshot returns the copy kept of the content with the given hash, if any.
+func (s *Store) Snapshot(sha string) ([]byte, bool) {
+	content, err := os.ReadFile(filepath.Join(s.snapshotDir(), filepath.Base(sha))) // #nosec G304 -- file name is a hash inside the trust store directory
+	return content, err == nil
+}
+
+// Path returns the file the store is kept in.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// hashInUse reports whether any entry is pinned to sha.
+func (s *Store) hashInUse(sha string) bool {
+	for _, entry := range s.entries {
+		if entry.SHA256 == sha {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotDir is the directory that holds copies of trusted scripts.
+func (s *Store) snapshotDir() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".d"
+}
+
+// save writes the store with owner-only permissions.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.List(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create trust store directory: %w", err)
+	}
+	// A temporary file of its own keeps concurrent azd exec processes from
+	// writing over each other's half-written store before it is renamed into place.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write trust store: %w", err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write trust store: %w", err)
+	}
+	return nil
+}
+
+// absPath returns path made absolute and cleaned with symlinks resolved, so a
+// script is trusted as the file it really is whichever link runs it. A path that
+// cannot be resolved, such as a script pinned before it exists, is only made absolute.
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
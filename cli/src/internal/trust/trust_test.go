@@ -0,0 +1,186 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestStore_TrustCheckRemove(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storePath := filepath.Join(dir, "trust.json")
+	script := filepath.Join(dir, "deploy.sh")
+	content := []byte("echo deploy\n")
+
+	store, err := OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("OpenFile() error: %v", err)
+	}
+	if err := os.WriteFile(script, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := store.Check(script, content); status != Untrusted {
+		t.Errorf("Check() before trusting = %v, want Untrusted", status)
+	}
+	entry, err := store.Trust(script, content)
+	if err != nil {
+		t.Fatalf("Trust() error: %v", err)
+	}
+	if entry.Path != script || entry.SHA256 != Hash(content) {
+		t.Errorf("Trust() = %+v", entry)
+	}
+
+	// The store is saved and reloaded, and remembers the trusted content.
+	store, err = OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("OpenFile() error: %v", err)
+	}
+	if status, _ := store.Check(script, content); status != Trusted {
+		t.Errorf("Check() after trusting = %v, want Trusted", status)
+	}
+	if status, got := store.Check(script, []byte("echo changed\n")); status != Changed || got.SHA256 != entry.SHA256 {
+		t.Errorf("Check() with changed content = %v, %+v; want Changed with the trusted entry", status, got)
+	}
+	if snapshot, ok := store.Snapshot(entry.SHA256); !ok || string(snapshot) != string(content) {
+		t.Errorf("Snapshot() = %q, %v; want the trusted content", snapshot, ok)
+	}
+
+	info, err := os.Stat(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o077 != 0 && os.PathSeparator == '/' {
+		t.Errorf("trust store permissions = %v, want owner-only", info.Mode().Perm())
+	}
+
+	if removed, err := store.Remove(script); err != nil || !removed {
+		t.Fatalf("Remove() = %v, %v; want true", removed, err)
+	}
+	if removed, err := store.Remove(script); err != nil || removed {
+		t.Errorf("second Remove() = %v, %v; want false", removed, err)
+	}
+	if _, ok := store.Snapshot(entry.SHA256); ok {
+		t.Error("expected the copy of a removed script to be deleted")
+	}
+	if entries := store.List(); len(entries) != 0 {
+		t.Errorf("List() after Remove() = %+v, want none", entries)
+	}
+}
+
+func TestStore_Pin(t *testing.T) {
+	t.Setenv(EnvVarTrustFile, filepath.Join(t.TempDir(), "trust.json"))
+	store, err := Open()
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	content := []byte("echo ci\n")
+	if _, err := store.Pin("ci.sh", "  "+Hash(content)+"\n"); err != nil {
+		t.Fatalf("Pin() error: %v", err)
+	}
+	if status, _ := store.Check("ci.sh", content); status != Trusted {
+		t.Errorf("Check() of pinned content = %v, want Trusted", status)
+	}
+	for _, sha := range []string{"abc", Hash(content)[:63] + "z"} {
+		if _, err := store.Pin("ci.sh", sha); err == nil {
+			t.Errorf("Pin(%q) succeeded, want an error", sha)
+		}
+	}
+}
+
+func TestOpenFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trust.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(path); err == nil {
+		t.Error("expected an error for a corrupt trust store")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []string
+	}{
+		{name: "Same", before: "a\nb\n", after: "a\nb\n", want: nil},
+		{name: "Changed line", before: "a\nb\nc\n", after: "a\nB\nc\n", want: []string{"@@ -2,1 +2,1 @@", "-b", "+B"}},
+		{name: "Added lines", before: "a\n", after: "a\nb\nc\n", want: []string{"@@ -2,0 +2,2 @@", "+b", "+c"}},
+		{name: "Removed line", before: "a\nb\nc", after: "a\r\nc\r\n", want: []string{"@@ -2,1 +2,0 @@", "-b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff([]byte(tt.before), []byte(tt.after)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_ConcurrentSaves(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "trust.json")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store, err := OpenFile(storePath)
+			if err == nil {
+				_, err = store.Pin(filepath.Join(dir, "s.sh"), Hash([]byte{byte(i)}))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Pin() error: %v", err)
+		}
+	}
+
+	if _, err := OpenFile(storePath); err != nil {
+		t.Errorf("store is not valid after concurrent saves: %v", err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestStore_ResolvesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "deploy.sh")
+	link := filepath.Join(dir, "link.sh")
+	content := []byte("echo deploy\n")
+	if err := os.WriteFile(script, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(script, link); err != nil {
+		t.Skipf("symlinks not available: %v", err)
+	}
+
+	store, err := OpenFile(filepath.Join(dir, "trust.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := store.Trust(link, content)
+	if err != nil {
+		t.Fatalf("Trust() error: %v", err)
+	}
+	if filepath.Base(entry.Path) != "deploy.sh" {
+		t.Errorf("entry path = %s, want the link's target", entry.Path)
+	}
+	if status, _ := store.Check(script, content); status != Trusted {
+		t.Errorf("Check() of the target = %v, want Trusted", status)
+	}
+}